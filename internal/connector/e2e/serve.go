@@ -2,6 +2,7 @@ package e2e
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector"
@@ -23,8 +25,10 @@ type TestServer struct {
 	GRPCServer *grpc.Server
 	Port       int
 	Logger     zerolog.Logger
-	stopChan   chan struct{}
-	errChan    chan error
+	// ClientCredentials are the transport credentials of the clients of NewClient and WaitForReady
+	ClientCredentials credentials.TransportCredentials
+	stopChan          chan struct{}
+	errChan           chan error
 }
 
 // StartTestServerOnPort starts a connector server on the specified port
@@ -48,6 +52,22 @@ type TestServer struct {
 func StartTestServerOnPort(t *testing.T, port int) *TestServer {
 	t.Helper()
 
+	return startTestServer(t, port, nil, insecure.NewCredentials())
+}
+
+// StartTLSTestServerOnPort starts a connector server serving TLS with the certificates of tlsConfig
+// on the specified port, whose clients connect with clientTLS, like the one of TestCertificates.ClientTLSConfig.
+func StartTLSTestServerOnPort(t *testing.T, port int, tlsConfig connector.TLSConfig, clientTLS *tls.Config) *TestServer {
+	t.Helper()
+
+	return startTestServer(t, port, &tlsConfig, credentials.NewTLS(clientTLS))
+}
+
+// startTestServer starts a connector server on the port, serving TLS if tlsConfig is set,
+// whose clients connect with clientCreds.
+func startTestServer(t *testing.T, port int, tlsConfig *connector.TLSConfig, clientCreds credentials.TransportCredentials) *TestServer {
+	t.Helper()
+
 	// Require explicit port number
 	require.NotEqual(t, 0, port, "port must be explicitly specified (non-zero)")
 
@@ -64,18 +84,26 @@ func StartTestServerOnPort(t *testing.T, port int) *TestServer {
 	lis, err := net.Listen("tcp", addr)
 	require.NoError(t, err, "failed to create listener on %s", addr)
 
+	var opts []connector.ServeOption
+	if tlsConfig != nil {
+		serverTLS, err := tlsConfig.ServerConfig(logger)
+		require.NoError(t, err, "failed to configure TLS")
+		opts = append(opts, connector.WithTLS(serverTLS))
+	}
+
 	ts := &TestServer{
-		Listener: lis,
-		Port:     port,
-		Logger:   logger,
-		stopChan: make(chan struct{}),
-		errChan:  make(chan error, 1),
+		Listener:          lis,
+		Port:              port,
+		Logger:            logger,
+		ClientCredentials: clientCreds,
+		stopChan:          make(chan struct{}),
+		errChan:           make(chan error, 1),
 	}
 
 	// Start server in background
 	go func() {
 		logger.Info().Int("port", port).Msg("Starting SurrealDB destination connector for e2e test")
-		if err := connector.Serve(lis, logger); err != nil {
+		if err := connector.Serve(lis, logger, opts...); err != nil {
 			select {
 			case ts.errChan <- err:
 			case <-ts.stopChan:
@@ -125,11 +153,19 @@ func (ts *TestServer) Stop(t *testing.T) {
 func (ts *TestServer) NewClient(t *testing.T) pb.DestinationConnectorClient {
 	t.Helper()
 
+	return ts.NewClientWithCredentials(t, ts.ClientCredentials)
+}
+
+// NewClientWithCredentials creates a gRPC client connected to this test server with the transport credentials,
+// like a TLS client without a client certificate
+func (ts *TestServer) NewClientWithCredentials(t *testing.T, creds credentials.TransportCredentials) pb.DestinationConnectorClient {
+	t.Helper()
+
 	addr := fmt.Sprintf("127.0.0.1:%d", ts.Port)
 
 	conn, err := grpc.NewClient(
 		addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 	)
 	require.NoError(t, err, "failed to create gRPC client")
 
@@ -164,7 +200,7 @@ func (ts *TestServer) WaitForReady(ctx context.Context, timeout time.Duration) e
 			// Try to connect
 			conn, err := grpc.NewClient(
 				addr,
-				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithTransportCredentials(ts.ClientCredentials),
 			)
 			if err != nil {
 				continue
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 )
@@ -101,3 +103,63 @@ func TestServerParallelTests(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+// TestServerTLS tests that a TLS server only accepts TLS clients trusting its CA
+func TestServerTLS(t *testing.T) {
+	certs := GenerateTestCertificates(t, t.TempDir())
+	server := StartTLSTestServerOnPort(t, 50021, certs.ServerTLSConfig(false), certs.ClientTLSConfig(t, false))
+	defer server.Stop(t)
+
+	ctx := context.Background()
+	err := server.WaitForReady(ctx, 5*time.Second)
+	require.NoError(t, err, "server should become ready")
+
+	_, err = server.NewClient(t).ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.NoError(t, err, "a TLS client should succeed")
+
+	plaintext := server.NewClientWithCredentials(t, insecure.NewCredentials())
+	_, err = plaintext.ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.Error(t, err, "a plaintext client should fail")
+}
+
+// TestServerMutualTLS tests that a mutual TLS server rejects clients without a client certificate
+func TestServerMutualTLS(t *testing.T) {
+	certs := GenerateTestCertificates(t, t.TempDir())
+	server := StartTLSTestServerOnPort(t, 50022, certs.ServerTLSConfig(true), certs.ClientTLSConfig(t, true))
+	defer server.Stop(t)
+
+	ctx := context.Background()
+	err := server.WaitForReady(ctx, 5*time.Second)
+	require.NoError(t, err, "server should become ready")
+
+	_, err = server.NewClient(t).ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.NoError(t, err, "a client with a client certificate should succeed")
+
+	withoutCert := server.NewClientWithCredentials(t, credentials.NewTLS(certs.ClientTLSConfig(t, false)))
+	_, err = withoutCert.ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.Error(t, err, "a client without a client certificate should fail")
+}
+
+// TestServerTLSReload tests that a TLS server serves the certificates written over its certificate files
+func TestServerTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	certs := GenerateTestCertificates(t, dir)
+	oldClientTLS := certs.ClientTLSConfig(t, true)
+	server := StartTLSTestServerOnPort(t, 50023, certs.ServerTLSConfig(true), oldClientTLS)
+	defer server.Stop(t)
+
+	ctx := context.Background()
+	err := server.WaitForReady(ctx, 5*time.Second)
+	require.NoError(t, err, "server should become ready")
+
+	// Rotate to certificates issued by a new CA, in the same files
+	GenerateTestCertificates(t, dir)
+
+	renewed := server.NewClientWithCredentials(t, credentials.NewTLS(certs.ClientTLSConfig(t, true)))
+	_, err = renewed.ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.NoError(t, err, "a client of the new CA should succeed without restarting the server")
+
+	stale := server.NewClientWithCredentials(t, credentials.NewTLS(oldClientTLS))
+	_, err = stale.ConfigurationForm(ctx, &pb.ConfigurationFormRequest{})
+	require.Error(t, err, "a client of the old CA should fail")
+}
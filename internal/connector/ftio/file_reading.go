@@ -2,6 +2,7 @@ package ftio
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/aes"
 	"crypto/cipher"
 	"errors"
@@ -11,25 +12,76 @@ import (
 	"os"
 
 	"github.com/klauspost/compress/zstd"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 )
 
 type FivetranFileReader struct {
 	io.ReadCloser
 }
 
+// NewFivetranFileReader returns a reader for the AES-encrypted Zstd-compressed file,
+// which is what Fivetran sends by default.
 func NewFivetranFileReader(file string, key []byte) (*FivetranFileReader, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
+	return NewFileReader(file, pb.Encryption_AES, pb.Compression_ZSTD, key)
+}
 
+// NewFileReader returns a reader that decrypts and decompresses the file
+// according to the encryption and compression used by Fivetran.
+//
+// The reader is built as a pipeline of stages:
+// the file itself, followed by the decryption stage, followed by the decompression stage.
+// Stages are skipped when the file is not encrypted (pb.Encryption_NONE)
+// or not compressed (pb.Compression_OFF).
+// key is required only when the file is encrypted.
+//
+// Closing the returned reader closes every stage, including the underlying file.
+func NewFileReader(file string, encryption pb.Encryption, compression pb.Compression, key []byte) (*FivetranFileReader, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
+	decrypted, err := newDecryptingStage(f, f, encryption, key)
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+
+	decompressed, err := newDecompressingStage(decrypted, compression)
+	if err != nil {
+		return nil, errors.Join(err, decrypted.Close())
+	}
+
+	return &FivetranFileReader{
+		ReadCloser: decompressed,
+	}, nil
+}
+
+// newDecryptingStage wraps in with a reader that decrypts the content of f.
+func newDecryptingStage(in io.ReadCloser, f *os.File, encryption pb.Encryption, key []byte) (io.ReadCloser, error) {
+	switch encryption {
+	case pb.Encryption_NONE:
+		return in, nil
+	case pb.Encryption_AES:
+		return newAESDecryptingReadCloser(in, f, key)
+	default:
+		return nil, fmt.Errorf("unsupported encryption: %s", encryption)
+	}
+}
+
+// newAESDecryptingReadCloser returns a reader that decrypts the AES-CBC encrypted content of f.
+// The iv is prepended to the ciphertext within the file.
+func newAESDecryptingReadCloser(in io.ReadCloser, f *os.File, key []byte) (io.ReadCloser, error) {
+	if len(key) == 0 {
+		return nil, errors.New("key is required for decrypting AES-encrypted files")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
 	iv := make([]byte, aes.BlockSize)
-	_, err = f.Read(iv)
+	_, err = io.ReadFull(in, iv)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read iv: %w", err)
 	}
@@ -40,20 +92,28 @@ func NewFivetranFileReader(file string, key []byte) (*FivetranFileReader, error)
 	}
 
 	fileSize := fileInfo.Size() - int64(aes.BlockSize)
+	if fileSize%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted file size %d is not a multiple of the block size %d", fileSize, aes.BlockSize)
+	}
 
 	blockMode := cipher.NewCBCDecrypter(block, iv)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create aesgcm: %w", err)
-	}
 
 	// TODO For now, we use block size * megabytes as the read buffer size.
-	decryptingReader := NewBlockModeDecryptingReadCloser(blockMode, f, fileSize, aes.BlockSize*1024*1024)
-
-	decompressingReader := NewZstdReadCloser(decryptingReader)
+	return NewBlockModeDecryptingReadCloser(blockMode, in, fileSize, aes.BlockSize*1024*1024), nil
+}
 
-	return &FivetranFileReader{
-		ReadCloser: decompressingReader,
-	}, nil
+// newDecompressingStage wraps in with a reader that decompresses the content.
+func newDecompressingStage(in io.ReadCloser, compression pb.Compression) (io.ReadCloser, error) {
+	switch compression {
+	case pb.Compression_OFF:
+		return in, nil
+	case pb.Compression_ZSTD:
+		return NewZstdReadCloser(in), nil
+	case pb.Compression_GZIP:
+		return NewGzipReadCloser(in), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
 }
 
 // BlockReadCloser is a reader that decrypts the input data in blocks.
@@ -241,7 +301,45 @@ func (z *ZstdReadCloser) Read(p []byte) (int, error) {
 }
 
 func (z *ZstdReadCloser) Close() error {
-	return z.zstdReadCloser.Close()
+	// The zstd reader does not close the underlying reader on its own
+	var err error
+	if z.zstdReadCloser != nil {
+		err = z.zstdReadCloser.Close()
+	}
+	return errors.Join(err, z.in.Close())
+}
+
+// GzipReadCloser is a reader that decompresses the input data using gzip.
+type GzipReadCloser struct {
+	in             io.ReadCloser
+	gzipReadCloser *gzip.Reader
+}
+
+var _ io.ReadCloser = &GzipReadCloser{}
+
+func NewGzipReadCloser(in io.ReadCloser) *GzipReadCloser {
+	return &GzipReadCloser{in: in}
+}
+
+func (g *GzipReadCloser) Read(p []byte) (int, error) {
+	if g.gzipReadCloser == nil {
+		reader, err := gzip.NewReader(g.in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		g.gzipReadCloser = reader
+	}
+
+	return g.gzipReadCloser.Read(p)
+}
+
+func (g *GzipReadCloser) Close() error {
+	// The gzip reader does not close the underlying reader on its own
+	var err error
+	if g.gzipReadCloser != nil {
+		err = g.gzipReadCloser.Close()
+	}
+	return errors.Join(err, g.in.Close())
 }
 
 // paddedReadCloser is a reader that trims trailing zeroes from the last block.
@@ -2,6 +2,7 @@ package ftio
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/aes"
 	"crypto/cipher"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 )
 
 // Fivetran sends the connector AES-encrypted Zstd-compressed files.
@@ -309,3 +311,77 @@ var _ io.ReadSeekCloser = &nonReadSeekCloser{}
 func (c *nonReadSeekCloser) Close() error {
 	return nil
 }
+
+func TestNewFileReader(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	data := []byte("id,name\n1,alice\n2,bob\n")
+
+	for _, encryption := range []pb.Encryption{pb.Encryption_NONE, pb.Encryption_AES} {
+		for _, compression := range []pb.Compression{pb.Compression_OFF, pb.Compression_ZSTD, pb.Compression_GZIP} {
+			t.Run(fmt.Sprintf("%s_%s", encryption, compression), func(t *testing.T) {
+				tmpFile := createFivetranFile(t, encryption, compression, key, data)
+
+				reader, err := NewFileReader(tmpFile, encryption, compression, key)
+				require.NoError(t, err)
+
+				read, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				require.Equal(t, data, read)
+
+				require.NoError(t, reader.Close())
+			})
+		}
+	}
+}
+
+func TestNewFileReaderRequiresKeyForEncryptedFiles(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	tmpFile := createFivetranFile(t, pb.Encryption_AES, pb.Compression_ZSTD, key, []byte("Hello, World!"))
+
+	_, err := NewFileReader(tmpFile, pb.Encryption_AES, pb.Compression_ZSTD, nil)
+	require.ErrorContains(t, err, "key is required")
+}
+
+// createFivetranFile writes data to a temporary file, compressed and encrypted
+// the same way as Fivetran does for the given compression and encryption.
+func createFivetranFile(t *testing.T, encryption pb.Encryption, compression pb.Compression, key []byte, data []byte) string {
+	compressed := bytes.NewBuffer(nil)
+	switch compression {
+	case pb.Compression_OFF:
+		compressed.Write(data)
+	case pb.Compression_ZSTD:
+		writer, err := zstd.NewWriter(compressed)
+		require.NoError(t, err)
+		_, err = writer.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+	case pb.Compression_GZIP:
+		writer := gzip.NewWriter(compressed)
+		_, err := writer.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+	}
+
+	content := compressed.Bytes()
+	if encryption == pb.Encryption_AES {
+		block, err := aes.NewCipher(key)
+		require.NoError(t, err)
+
+		iv := []byte("fedcba9876543210")
+		stream := cipher.NewCBCEncrypter(block, iv)
+
+		// PKCS7 padding
+		padding := aes.BlockSize - len(content)%aes.BlockSize
+		padded := append(append([]byte(nil), content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+		encrypted := make([]byte, len(padded))
+		stream.CryptBlocks(encrypted, padded)
+
+		content = append(append([]byte(nil), iv...), encrypted...)
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "test.csv")
+	require.NoError(t, os.WriteFile(tmpFile, content, 0644))
+
+	return tmpFile
+}
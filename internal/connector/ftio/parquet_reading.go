@@ -0,0 +1,288 @@
+package ftio
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// ParquetRowReader reads rows from a Parquet batch file as typed Go values.
+//
+// Parquet needs random access to the footer of the file, while Fivetran batch files
+// can only be decrypted and decompressed as a stream.
+// That's why the decoded stream is spooled to a temporary file first,
+// so that the memory usage does not grow with the size of the batch file.
+type ParquetRowReader struct {
+	spool *os.File
+	file  *parquet.File
+
+	columns []string
+	types   []parquet.Type
+
+	rowGroup int
+	rows     parquet.Rows
+	buf      []parquet.Row
+	bufLen   int
+	bufPos   int
+}
+
+// parquetReadBatchSize is the number of rows read from a row group at once.
+const parquetReadBatchSize = 256
+
+// NewParquetRowReader spools the decoded Parquet content from r to a temporary file
+// and opens it for reading.
+//
+// Only flat schemas, which are what Fivetran sends, are supported.
+// It's the caller's responsibility to close the returned reader.
+func NewParquetRowReader(r io.Reader) (*ParquetRowReader, error) {
+	spool, err := os.CreateTemp("", "fivetran-batch-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+
+	pr := &ParquetRowReader{spool: spool}
+
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to spool parquet file: %w", err), pr.Close())
+	}
+
+	f, err := parquet.OpenFile(spool, size)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to open parquet file: %w", err), pr.Close())
+	}
+	pr.file = f
+
+	schema := f.Schema()
+	paths := schema.Columns()
+	pr.columns = make([]string, len(paths))
+	pr.types = make([]parquet.Type, len(paths))
+	for _, path := range paths {
+		if len(path) != 1 {
+			return nil, errors.Join(fmt.Errorf("nested parquet column %s is not supported", strings.Join(path, ".")), pr.Close())
+		}
+		leaf, ok := schema.Lookup(path...)
+		if !ok {
+			return nil, errors.Join(fmt.Errorf("parquet column %s not found in the schema", path[0]), pr.Close())
+		}
+		if leaf.Node.Repeated() {
+			return nil, errors.Join(fmt.Errorf("repeated parquet column %s is not supported", path[0]), pr.Close())
+		}
+		pr.columns[leaf.ColumnIndex] = path[0]
+		pr.types[leaf.ColumnIndex] = leaf.Node.Type()
+	}
+
+	pr.buf = make([]parquet.Row, parquetReadBatchSize)
+
+	return pr, nil
+}
+
+// Columns returns the column names in the order Read returns the values of each row.
+func (p *ParquetRowReader) Columns() []string {
+	return p.columns
+}
+
+// Read returns the values of the next row, or io.EOF once every row has been read.
+//
+// Null values are returned as nil, and the other values are converted according to
+// the logical type of the column. See ParquetValue for the conversion rules.
+func (p *ParquetRowReader) Read() ([]any, error) {
+	for p.bufPos >= p.bufLen {
+		if err := p.fill(); err != nil {
+			return nil, err
+		}
+	}
+
+	row := p.buf[p.bufPos]
+	p.bufPos++
+
+	values := make([]any, len(p.columns))
+	for _, v := range row {
+		i := v.Column()
+		if i < 0 || i >= len(values) {
+			return nil, fmt.Errorf("parquet value refers to unknown column index %d", i)
+		}
+		typed, err := ParquetValue(v, p.types[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert value of parquet column %s: %w", p.columns[i], err)
+		}
+		values[i] = typed
+	}
+
+	return values, nil
+}
+
+// fill reads the next batch of rows into the buffer,
+// moving on to the next row group when the current one is exhausted.
+func (p *ParquetRowReader) fill() error {
+	if p.rows == nil {
+		rowGroups := p.file.RowGroups()
+		if p.rowGroup >= len(rowGroups) {
+			return io.EOF
+		}
+		p.rows = rowGroups[p.rowGroup].Rows()
+		p.rowGroup++
+	}
+
+	n, err := p.rows.ReadRows(p.buf)
+	p.bufLen = n
+	p.bufPos = 0
+
+	if errors.Is(err, io.EOF) {
+		closeErr := p.rows.Close()
+		p.rows = nil
+		if closeErr != nil {
+			return fmt.Errorf("failed to close parquet row group: %w", closeErr)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read parquet rows: %w", err)
+	}
+
+	return nil
+}
+
+// Close releases the row group reader and removes the spooled file.
+func (p *ParquetRowReader) Close() error {
+	var errs []error
+	if p.rows != nil {
+		errs = append(errs, p.rows.Close())
+		p.rows = nil
+	}
+	if p.spool != nil {
+		errs = append(errs, p.spool.Close())
+		errs = append(errs, os.Remove(p.spool.Name()))
+		p.spool = nil
+	}
+	return errors.Join(errs...)
+}
+
+// ParquetValue converts a Parquet value to a Go value according to the column type.
+//
+// The conversion rules are:
+//   - null values become nil
+//   - BOOLEAN becomes bool
+//   - INT32 and INT64 become int64, unless annotated as DATE or TIMESTAMP (time.Time),
+//     TIME (time.Duration), or DECIMAL (string)
+//   - FLOAT and DOUBLE become float64
+//   - INT96 is the legacy timestamp representation and becomes time.Time
+//   - BYTE_ARRAY and FIXED_LEN_BYTE_ARRAY become string when annotated as STRING, ENUM or JSON,
+//     a canonical UUID string when annotated as UUID, a string when annotated as DECIMAL,
+//     and []byte otherwise
+func ParquetValue(v parquet.Value, t parquet.Type) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	var logical format.LogicalTypeValue
+	if lt := t.LogicalType(); lt != nil {
+		logical = lt.Value
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean(), nil
+	case parquet.Int32, parquet.Int64:
+		n := v.Int64()
+		if v.Kind() == parquet.Int32 {
+			n = int64(v.Int32())
+		}
+		switch lt := logical.(type) {
+		case *format.DateType:
+			return time.Unix(n*24*60*60, 0).UTC(), nil
+		case *format.TimestampType:
+			return unixTime(n, lt.Unit.Value.Duration()), nil
+		case *format.TimeType:
+			return time.Duration(n) * lt.Unit.Value.Duration(), nil
+		case *format.DecimalType:
+			return formatDecimal(big.NewInt(n), lt.Scale), nil
+		}
+		return n, nil
+	case parquet.Int96:
+		return int96ToTime(v.Int96()), nil
+	case parquet.Float:
+		// Widening float32 to float64 directly would give 1.100000023841858 for 1.1,
+		// so we go through the shortest decimal representation of the float32,
+		// which is also what Fivetran writes to CSV batch files.
+		return strconv.ParseFloat(strconv.FormatFloat(float64(v.Float()), 'g', -1, 32), 64)
+	case parquet.Double:
+		return v.Double(), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		b := v.ByteArray()
+		switch lt := logical.(type) {
+		case *format.StringType, *format.EnumType, *format.JsonType:
+			return string(b), nil
+		case *format.UUIDType:
+			return formatUUID(b)
+		case *format.DecimalType:
+			return formatDecimal(bigIntFromTwosComplement(b), lt.Scale), nil
+		}
+		// Copy the bytes because the underlying buffer is reused across rows
+		return append([]byte(nil), b...), nil
+	default:
+		return nil, fmt.Errorf("unsupported parquet value kind: %s", v.Kind())
+	}
+}
+
+// unixTime converts n units since the Unix epoch to time.Time.
+// It avoids multiplying n by the unit, which would overflow time.Duration
+// for timestamps like 9999-12-31 that Fivetran uses for _fivetran_end.
+func unixTime(n int64, unit time.Duration) time.Time {
+	perSecond := int64(time.Second / unit)
+	return time.Unix(n/perSecond, (n%perSecond)*int64(unit)).UTC()
+}
+
+// int96ToTime converts the legacy INT96 timestamp, which consists of
+// nanoseconds within the day followed by the Julian day number, to time.Time.
+func int96ToTime(v deprecated.Int96) time.Time {
+	const julianDayOfUnixEpoch = 2440588
+	nanos := int64(v[1])<<32 | int64(v[0])
+	days := int64(v[2]) - julianDayOfUnixEpoch
+	return time.Unix(days*24*60*60, nanos).UTC()
+}
+
+// bigIntFromTwosComplement decodes a big-endian two's complement integer,
+// which is how Parquet encodes byte-array-backed decimals.
+func bigIntFromTwosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+// formatDecimal formats the unscaled value of a decimal as a plain decimal string.
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	s := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if len(s) <= int(scale) {
+			s = strings.Repeat("0", int(scale)-len(s)+1) + s
+		}
+		s = s[:len(s)-int(scale)] + "." + s[len(s)-int(scale):]
+	} else if scale < 0 {
+		s += strings.Repeat("0", int(-scale))
+	}
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func formatUUID(b []byte) (string, error) {
+	if len(b) != 16 {
+		return "", fmt.Errorf("invalid uuid length: %d", len(b))
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
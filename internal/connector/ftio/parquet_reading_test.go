@@ -0,0 +1,116 @@
+package ftio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+type parquetTestRow struct {
+	ID        int64     `parquet:"id"`
+	Name      *string   `parquet:"name,optional"`
+	Active    bool      `parquet:"active"`
+	Score     float64   `parquet:"score"`
+	Balance   int64     `parquet:"balance,decimal(2:18)"`
+	UpdatedAt time.Time `parquet:"updated_at,timestamp(millisecond)"`
+	Born      int32     `parquet:"born,date"` // days since the Unix epoch
+	Payload   []byte    `parquet:"payload"`
+}
+
+func TestParquetRowReader(t *testing.T) {
+	name := "alice"
+	end := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	rows := []parquetTestRow{
+		{
+			ID:        1,
+			Name:      &name,
+			Active:    true,
+			Score:     1.5,
+			Balance:   -12345,
+			UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6_000_000, time.UTC),
+			Born:      7441,
+			Payload:   []byte{0x01, 0x02},
+		},
+		{
+			ID:        2,
+			UpdatedAt: end,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, parquet.Write(&buf, rows))
+
+	r, err := NewParquetRowReader(&buf)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	require.Equal(t, []string{"id", "name", "active", "score", "balance", "updated_at", "born", "payload"}, r.Columns())
+
+	first, err := r.Read()
+	require.NoError(t, err)
+	require.Equal(t, []any{
+		int64(1),
+		"alice",
+		true,
+		1.5,
+		"-123.45",
+		time.Date(2024, 1, 2, 3, 4, 5, 6_000_000, time.UTC),
+		time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		[]byte{0x01, 0x02},
+	}, first)
+
+	second, err := r.Read()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), second[0])
+	require.Nil(t, second[1])
+	require.Equal(t, false, second[2])
+	require.Equal(t, "0.00", second[4])
+	require.Equal(t, end, second[5])
+	require.Equal(t, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), second[6])
+
+	_, err = r.Read()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestParquetValue_Float(t *testing.T) {
+	// FLOAT values are converted to the float64 with the same shortest representation,
+	// rather than the exact widened value 1.100000023841858
+	v, err := ParquetValue(parquet.ValueOf(float32(1.1)), parquet.FloatType)
+	require.NoError(t, err)
+	require.Equal(t, 1.1, v)
+}
+
+func TestFormatDecimal(t *testing.T) {
+	testCases := []struct {
+		unscaled int64
+		scale    int32
+		expected string
+	}{
+		{12345, 2, "123.45"},
+		{-12345, 2, "-123.45"},
+		{5, 3, "0.005"},
+		{-5, 3, "-0.005"},
+		{12, 0, "12"},
+		{12, -2, "1200"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, formatDecimal(bigIntFromTwosComplement(twosComplement(tc.unscaled)), tc.scale))
+	}
+}
+
+// twosComplement encodes n as a big-endian 8-byte two's complement integer.
+func twosComplement(n int64) []byte {
+	b := make([]byte, 8)
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
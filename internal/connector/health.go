@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"github.com/surrealdb/surrealdb.go"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// DefaultHealthCheckInterval is how often the readiness of the connector is checked by default.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// connectorService is the name the grpc.health.v1 service reports the readiness of the connector under.
+var connectorService = pb.DestinationConnector_ServiceDesc.ServiceName
+
+// healthChecker reports the health of the connector through the grpc.health.v1 service.
+//
+// The overall status, of the empty service name, is the liveness of the connector,
+// while the status of connectorService is its readiness,
+// which also requires the SurrealDB endpoint to be reachable if set.
+// Both are NOT_SERVING once the connector is draining.
+type healthChecker struct {
+	*health.Server
+
+	// endpoint is the URL of the SurrealDB instance checked every interval, if any
+	endpoint string
+	interval time.Duration
+	logger   zerolog.Logger
+
+	// reachable is the result of the last check of endpoint, to log changes only
+	reachable *bool
+}
+
+func newHealthChecker(endpoint string, interval time.Duration, logger zerolog.Logger) *healthChecker {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	h := &healthChecker{
+		Server:   health.NewServer(),
+		endpoint: endpoint,
+		interval: interval,
+		logger:   logger,
+	}
+
+	// The connector is only ready once the endpoint is known to be reachable
+	ready := healthpb.HealthCheckResponse_SERVING
+	if endpoint != "" {
+		ready = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	h.SetServingStatus(connectorService, ready)
+
+	return h
+}
+
+// Start checks the endpoint every interval until ctx is done, if set.
+func (h *healthChecker) Start(ctx context.Context) {
+	if h.endpoint == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			h.check(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// check updates the readiness of the connector with the reachability of the endpoint.
+// The update is ignored once draining, after Shutdown.
+func (h *healthChecker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+
+	err := pingSurrealDB(ctx, h.endpoint)
+	reachable := err == nil
+
+	if h.reachable == nil || *h.reachable != reachable {
+		if reachable {
+			h.logger.Info().Str("endpoint", h.endpoint).Msg("SurrealDB endpoint is reachable, the connector is ready")
+		} else {
+			h.logger.Warn().Err(err).Str("endpoint", h.endpoint).Msg("SurrealDB endpoint is unreachable, the connector is not ready")
+		}
+	}
+	h.reachable = &reachable
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if !reachable {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.SetServingStatus(connectorService, status)
+}
+
+// pingSurrealDB connects to the SurrealDB instance of the endpoint, and asks for its version,
+// which requires no authentication.
+func pingSurrealDB(ctx context.Context, endpoint string) error {
+	db, err := surrealdb.FromEndpointURLString(ctx, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SurrealDB: %w", err)
+	}
+	defer func() { _ = db.Close(ctx) }()
+
+	if _, err := db.Version(ctx); err != nil {
+		return fmt.Errorf("failed to get the version of SurrealDB: %w", err)
+	}
+	return nil
+}
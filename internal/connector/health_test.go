@@ -0,0 +1,41 @@
+package connector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, h *healthChecker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := h.Check(t.Context(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestHealthChecker_Draining(t *testing.T) {
+	h := newHealthChecker("", 0, zerolog.Nop())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, connectorService))
+
+	h.Shutdown()
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, connectorService))
+}
+
+func TestHealthChecker_UnreachableEndpoint(t *testing.T) {
+	h := newHealthChecker("ws://127.0.0.1:1/rpc", time.Second, zerolog.Nop())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, connectorService),
+		"the connector should not be ready before the endpoint is checked")
+
+	h.check(t.Context())
+	require.NotNil(t, h.reachable)
+	assert.False(t, *h.reachable)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, connectorService))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, ""), "the connector should still be live")
+}
@@ -0,0 +1,257 @@
+package introspect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a field defined on a table.
+type Field struct {
+	// Name is the field name, like `name`, or `tags[*]` for the elements of the array field `tags`.
+	// Escaped identifiers are unescaped, so that `my-field` is named my-field.
+	Name string
+	// Kind is the field type, like `option<string>`, or empty if the field was defined without a type.
+	Kind string
+	// Comment is the unquoted comment, or empty if the field was defined without a comment.
+	Comment string
+}
+
+// header is the part of a DEFINE FIELD, INDEX, or EVENT statement
+// that names the defined thing and its table, like `DEFINE FIELD name ON TABLE user`.
+type header struct {
+	tokens []token
+	// name is the tokens of the name of the defined thing
+	name []token
+	// table is the index of the table token
+	table int
+}
+
+// parseHeader tokenizes the DEFINE statement of one of the given kinds, like FIELD or INDEX,
+// and locates the name and the table in it.
+func parseHeader(def string, kinds ...string) (header, error) {
+	tokens, err := tokenize(def)
+	if err != nil {
+		return header{}, err
+	}
+
+	if len(tokens) < 2 || !tokens[0].is("DEFINE") || !isAny(tokens[1], kinds) {
+		return header{}, fmt.Errorf("not a DEFINE %s statement: %s", strings.Join(kinds, "/"), def)
+	}
+
+	i := 2
+	switch {
+	case i < len(tokens) && tokens[i].is("OVERWRITE"):
+		i++
+	case i+2 < len(tokens) && tokens[i].is("IF") && tokens[i+1].is("NOT") && tokens[i+2].is("EXISTS"):
+		i += 3
+	}
+	nameStart := i
+
+	// The name is at least one token, so a field named `on` is never mistaken for the ON keyword.
+	depth := 0
+	for i++; i < len(tokens); i++ {
+		depth += bracketDepth(tokens[i])
+		if depth == 0 && tokens[i].is("ON") {
+			break
+		}
+	}
+	if nameStart >= len(tokens) || i >= len(tokens) {
+		return header{}, fmt.Errorf("missing ON clause in %s", def)
+	}
+	name := tokens[nameStart:i]
+
+	i++
+	if i < len(tokens) && tokens[i].is("TABLE") {
+		i++
+	}
+	if i >= len(tokens) || tokens[i].kind != tokenIdent {
+		return header{}, fmt.Errorf("missing table name in %s", def)
+	}
+
+	return header{tokens: tokens, name: name, table: i}, nil
+}
+
+func isAny(t token, keywords []string) bool {
+	for _, k := range keywords {
+		if t.is(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// bracketDepth returns how much the token changes the nesting depth of (), [], and {}.
+func bracketDepth(t token) int {
+	if t.kind != tokenPunct {
+		return 0
+	}
+	switch t.value {
+	case "(", "[", "{":
+		return 1
+	case ")", "]", "}":
+		return -1
+	}
+	return 0
+}
+
+// ParseField parses a DEFINE FIELD statement as returned by INFO FOR TABLE, like
+//
+//	DEFINE FIELD name ON user TYPE option<string> DEFAULT 'n/a' COMMENT '{"ft_index":1}' PERMISSIONS FULL
+func ParseField(def string) (Field, error) {
+	h, err := parseHeader(def, "FIELD")
+	if err != nil {
+		return Field{}, err
+	}
+
+	var name strings.Builder
+	for _, t := range h.name {
+		name.WriteString(t.value)
+	}
+	f := Field{Name: name.String()}
+
+	tokens := h.tokens
+	i := h.table + 1
+	if i < len(tokens) && (tokens[i].is("FLEXIBLE") || tokens[i].is("FLEX")) {
+		i++
+	}
+	if i < len(tokens) && tokens[i].is("TYPE") {
+		i++
+		end, err := skipKind(tokens, i)
+		if err != nil {
+			return Field{}, fmt.Errorf("invalid type of field %s in %s: %w", f.Name, def, err)
+		}
+		f.Kind = def[tokens[i].start:tokens[end-1].end]
+		i = end
+	}
+
+	// The other clauses are skipped, looking for a COMMENT keyword followed by a string.
+	// Strings in DEFAULT, VALUE, and ASSERT expressions are single tokens,
+	// and a field named comment in them is never directly followed by a string.
+	depth := 0
+	for ; i < len(tokens); i++ {
+		depth += bracketDepth(tokens[i])
+		if depth == 0 && tokens[i].is("COMMENT") && i+1 < len(tokens) && tokens[i+1].kind == tokenString {
+			f.Comment = tokens[i+1].value
+			break
+		}
+	}
+
+	return f, nil
+}
+
+// skipKind returns the index of the first token after the type starting at index i,
+// like `option<array<string>>` or `int | string`.
+func skipKind(tokens []token, i int) (int, error) {
+	for {
+		var err error
+		i, err = skipKindTerm(tokens, i)
+		if err != nil {
+			return 0, err
+		}
+		if i < len(tokens) && tokens[i].isPunct("|") {
+			i++
+			continue
+		}
+		return i, nil
+	}
+}
+
+func skipKindTerm(tokens []token, i int) (int, error) {
+	if i >= len(tokens) {
+		return 0, fmt.Errorf("missing type")
+	}
+
+	t := tokens[i]
+	switch {
+	case bracketDepth(t) > 0:
+		// Literal types, like `{ a: int }` or `[string, int]`
+		return skipBrackets(tokens, i)
+	case t.isPunct("-"):
+		// Negative number literals
+		return skipKindTerm(tokens, i+1)
+	case t.kind == tokenIdent:
+		i++
+		// Decimal number literals are words separated by dots
+		for i+1 < len(tokens) && tokens[i].isPunct(".") && tokens[i+1].kind == tokenIdent {
+			i += 2
+		}
+		if i < len(tokens) && tokens[i].isPunct("<") {
+			return skipBrackets(tokens, i)
+		}
+		return i, nil
+	case t.kind == tokenString:
+		return i + 1, nil
+	default:
+		return 0, fmt.Errorf("unexpected %q", t.value)
+	}
+}
+
+// skipBrackets returns the index of the first token after the brackets opening at index i,
+// including the nested ones.
+func skipBrackets(tokens []token, i int) (int, error) {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		t := tokens[j]
+		switch {
+		case t.isPunct("<") || bracketDepth(t) > 0:
+			depth++
+		case t.isPunct(">") || bracketDepth(t) < 0:
+			depth--
+		}
+		if depth == 0 {
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed %q", tokens[i].value)
+}
+
+// Retarget returns the DEFINE FIELD, INDEX, or EVENT statement with its table replaced,
+// so that the definition can be copied to another table.
+func Retarget(def, table string) (string, error) {
+	h, err := parseHeader(def, "FIELD", "INDEX", "EVENT")
+	if err != nil {
+		return "", err
+	}
+
+	t := h.tokens[h.table]
+	return def[:t.start] + table + def[t.end:], nil
+}
+
+// RetargetTable returns the DEFINE TABLE statement with the table renamed,
+// so that the definition, along with its permissions, changefeed and comment, can be copied to another table.
+func RetargetTable(def, table string) (string, error) {
+	tokens, err := tokenize(def)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tokens) < 2 || !tokens[0].is("DEFINE") || !tokens[1].is("TABLE") {
+		return "", fmt.Errorf("not a DEFINE TABLE statement: %s", def)
+	}
+
+	i := 2
+	switch {
+	case i < len(tokens) && tokens[i].is("OVERWRITE"):
+		i++
+	case i+2 < len(tokens) && tokens[i].is("IF") && tokens[i+1].is("NOT") && tokens[i+2].is("EXISTS"):
+		i += 3
+	}
+	if i >= len(tokens) || tokens[i].kind != tokenIdent {
+		return "", fmt.Errorf("missing table name in %s", def)
+	}
+
+	t := tokens[i]
+	return def[:t.start] + table + def[t.end:], nil
+}
+
+// RenameField returns the DEFINE FIELD statement with the field renamed,
+// so that the definition can be copied to another field.
+func RenameField(def, name string) (string, error) {
+	h, err := parseHeader(def, "FIELD")
+	if err != nil {
+		return "", err
+	}
+
+	start, end := h.name[0].start, h.name[len(h.name)-1].end
+	return def[:start] + name + def[end:], nil
+}
@@ -0,0 +1,196 @@
+package introspect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want Field
+	}{
+		{
+			name: "type",
+			def:  "DEFINE FIELD name ON user TYPE string PERMISSIONS FULL",
+			want: Field{Name: "name", Kind: "string"},
+		},
+		{
+			name: "comment",
+			def:  `DEFINE FIELD name ON user TYPE option<string> COMMENT '{"ft_index":1,"ft_type":13}' PERMISSIONS FULL`,
+			want: Field{Name: "name", Kind: "option<string>", Comment: `{"ft_index":1,"ft_type":13}`},
+		},
+		{
+			name: "comment with escaped quotes",
+			def:  `DEFINE FIELD name ON user TYPE string COMMENT 'it\'s a \"name\" with a \\ backslash' PERMISSIONS FULL`,
+			want: Field{Name: "name", Kind: "string", Comment: `it's a "name" with a \ backslash`},
+		},
+		{
+			name: "double quoted comment",
+			def:  `DEFINE FIELD name ON user TYPE string COMMENT "it's"`,
+			want: Field{Name: "name", Kind: "string", Comment: "it's"},
+		},
+		{
+			name: "comment containing keywords",
+			def:  `DEFINE FIELD name ON user TYPE string COMMENT 'has TYPE int and COMMENT \'x\''`,
+			want: Field{Name: "name", Kind: "string", Comment: "has TYPE int and COMMENT 'x'"},
+		},
+		{
+			name: "nested types with spaces",
+			def:  "DEFINE FIELD tags ON user TYPE option<array<record<tag | label>, 10>> PERMISSIONS FULL",
+			want: Field{Name: "tags", Kind: "option<array<record<tag | label>, 10>>"},
+		},
+		{
+			name: "union type",
+			def:  "DEFINE FIELD code ON user TYPE int | string COMMENT 'x'",
+			want: Field{Name: "code", Kind: "int | string", Comment: "x"},
+		},
+		{
+			name: "default and assert",
+			def:  `DEFINE FIELD age ON user TYPE int DEFAULT 18 ASSERT $value > 0 AND $value < 150 COMMENT '{"ft_index":2}' PERMISSIONS FULL`,
+			want: Field{Name: "age", Kind: "int", Comment: `{"ft_index":2}`},
+		},
+		{
+			name: "default containing COMMENT",
+			def:  `DEFINE FIELD note ON user TYPE string DEFAULT 'COMMENT' VALUE string::concat(comment, 'x') COMMENT 'y'`,
+			want: Field{Name: "note", Kind: "string", Comment: "y"},
+		},
+		{
+			name: "without type",
+			def:  "DEFINE FIELD anything ON user PERMISSIONS FULL",
+			want: Field{Name: "anything"},
+		},
+		{
+			name: "flexible",
+			def:  "DEFINE FIELD attrs ON TABLE user FLEXIBLE TYPE object",
+			want: Field{Name: "attrs", Kind: "object"},
+		},
+		{
+			name: "overwrite",
+			def:  "DEFINE FIELD OVERWRITE name ON user TYPE string",
+			want: Field{Name: "name", Kind: "string"},
+		},
+		{
+			name: "if not exists",
+			def:  "DEFINE FIELD IF NOT EXISTS name ON user TYPE string",
+			want: Field{Name: "name", Kind: "string"},
+		},
+		{
+			name: "nested field",
+			def:  "DEFINE FIELD tags[*] ON user TYPE string PERMISSIONS FULL",
+			want: Field{Name: "tags[*]", Kind: "string"},
+		},
+		{
+			name: "escaped name",
+			def:  "DEFINE FIELD `first name` ON `user-table` TYPE string",
+			want: Field{Name: "first name", Kind: "string"},
+		},
+		{
+			name: "field named on",
+			def:  "DEFINE FIELD on ON user TYPE bool",
+			want: Field{Name: "on", Kind: "bool"},
+		},
+		{
+			name: "literal type",
+			def:  "DEFINE FIELD status ON user TYPE 'active' | 'inactive' | -1.5 COMMENT 'x'",
+			want: Field{Name: "status", Kind: "'active' | 'inactive' | -1.5", Comment: "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseField(tt.def)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f)
+		})
+	}
+}
+
+func TestParseField_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		wantErr string
+	}{
+		{name: "not a field", def: "DEFINE INDEX idx ON user FIELDS name", wantErr: "not a DEFINE FIELD statement"},
+		{name: "missing table", def: "DEFINE FIELD name TYPE string", wantErr: "missing ON clause"},
+		{name: "unterminated comment", def: "DEFINE FIELD name ON user TYPE string COMMENT 'x", wantErr: "unterminated"},
+		{name: "unclosed type", def: "DEFINE FIELD name ON user TYPE option<string", wantErr: "unclosed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseField(tt.def)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRetarget(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want string
+	}{
+		{
+			name: "field",
+			def:  "DEFINE FIELD name ON users TYPE string COMMENT 'ON users ' PERMISSIONS FULL",
+			want: "DEFINE FIELD name ON people TYPE string COMMENT 'ON users ' PERMISSIONS FULL",
+		},
+		{
+			name: "field named like the table",
+			def:  "DEFINE FIELD users ON TABLE users TYPE string",
+			want: "DEFINE FIELD users ON TABLE people TYPE string",
+		},
+		{
+			name: "escaped table",
+			def:  "DEFINE FIELD name ON `users` TYPE string",
+			want: "DEFINE FIELD name ON people TYPE string",
+		},
+		{
+			name: "index",
+			def:  "DEFINE INDEX idx ON users FIELDS name UNIQUE",
+			want: "DEFINE INDEX idx ON people FIELDS name UNIQUE",
+		},
+		{
+			name: "event",
+			def:  "DEFINE EVENT audit ON users WHEN $event = 'CREATE' THEN (CREATE log SET table = 'users')",
+			want: "DEFINE EVENT audit ON people WHEN $event = 'CREATE' THEN (CREATE log SET table = 'users')",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Retarget(tt.def, "people")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetargetTable(t *testing.T) {
+	got, err := RetargetTable("DEFINE TABLE users TYPE NORMAL SCHEMAFULL CHANGEFEED 1d COMMENT 'users' PERMISSIONS FOR select FULL", "people")
+	require.NoError(t, err)
+	assert.Equal(t, "DEFINE TABLE people TYPE NORMAL SCHEMAFULL CHANGEFEED 1d COMMENT 'users' PERMISSIONS FOR select FULL", got)
+
+	got, err = RetargetTable("DEFINE TABLE IF NOT EXISTS `my-users` SCHEMALESS", "people")
+	require.NoError(t, err)
+	assert.Equal(t, "DEFINE TABLE IF NOT EXISTS people SCHEMALESS", got)
+
+	_, err = RetargetTable("DEFINE FIELD name ON users", "people")
+	assert.Error(t, err)
+}
+
+func TestRenameField(t *testing.T) {
+	got, err := RenameField("DEFINE FIELD `first name` ON users TYPE string DEFAULT 'first name' COMMENT 'first name'", "given_name")
+	require.NoError(t, err)
+	assert.Equal(t, "DEFINE FIELD given_name ON users TYPE string DEFAULT 'first name' COMMENT 'first name'", got)
+
+	got, err = RenameField("DEFINE FIELD OVERWRITE name ON users TYPE string", "full_name")
+	require.NoError(t, err)
+	assert.Equal(t, "DEFINE FIELD OVERWRITE full_name ON users TYPE string", got)
+}
@@ -0,0 +1,148 @@
+// Package introspect reads the schema of SurrealDB tables.
+//
+// Field names, types, and comments are read from the structured output of
+// `INFO FOR TABLE ... STRUCTURE` where the server supports it.
+// For older servers, they are parsed from the DEFINE FIELD statements returned by `INFO FOR TABLE`
+// with a tokenizer that understands quoted strings, escaped identifiers, and nested types,
+// rather than by splitting the statements on keywords.
+package introspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	surrealdb "github.com/surrealdb/surrealdb.go"
+)
+
+// ErrNoTableInfo is returned when INFO FOR TABLE returns no result.
+var ErrNoTableInfo = errors.New("no table info returned")
+
+// Definitions are the DEFINE statements of a table's fields, indexes and events, keyed by their names,
+// as returned by INFO FOR TABLE.
+type Definitions struct {
+	Fields  map[string]string `cbor:"fields"`
+	Indexes map[string]string `cbor:"indexes"`
+	Events  map[string]string `cbor:"events"`
+}
+
+// Fields returns the fields defined on the table.
+//
+// Nested fields are included, like `tags[*]` for the elements of the array field `tags`.
+// A table that doesn't exist has no fields.
+func Fields(ctx context.Context, db *surrealdb.DB, table string) ([]Field, error) {
+	fields, structureErr := structuredFields(ctx, db, table)
+	if structureErr == nil {
+		return fields, nil
+	}
+	if errors.Is(structureErr, ErrNoTableInfo) {
+		return nil, structureErr
+	}
+
+	// Servers not supporting STRUCTURE fail to parse the query,
+	// in which case the fields are parsed from their definitions.
+	defs, err := TableDefinitions(ctx, db, table)
+	if err != nil {
+		return nil, errors.Join(structureErr, err)
+	}
+
+	fields = make([]Field, 0, len(defs.Fields))
+	for _, def := range defs.Fields {
+		f, err := ParseField(def)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse field definition of table %s: %w", table, err)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// structuredFields returns the fields defined on the table using INFO FOR TABLE ... STRUCTURE,
+// whose result is formatted like:
+//
+//	{
+//		"events": [],
+//		"fields": [
+//			{ "name": "name", "kind": "string", "comment": "...", "what": "user", ... }
+//		],
+//		"indexes": [],
+//		"lives": [],
+//		"tables": []
+//	}
+func structuredFields(ctx context.Context, db *surrealdb.DB, table string) ([]Field, error) {
+	type structuredField struct {
+		Name    string `cbor:"name"`
+		Kind    string `cbor:"kind"`
+		Comment string `cbor:"comment"`
+	}
+	type structuredInfo struct {
+		Fields []structuredField `cbor:"fields"`
+	}
+
+	info, err := surrealdb.Query[structuredInfo](ctx, db, fmt.Sprintf("INFO FOR TABLE %s STRUCTURE;", table), nil)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil || len(*info) == 0 {
+		return nil, ErrNoTableInfo
+	}
+
+	sfs := (*info)[0].Result.Fields
+	fields := make([]Field, 0, len(sfs))
+	for _, sf := range sfs {
+		// Names are formatted as in DEFINE FIELD statements, with escaped identifiers in backticks.
+		name, err := unescapeName(sf.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse field name %s of table %s: %w", sf.Name, table, err)
+		}
+		fields = append(fields, Field{Name: name, Kind: sf.Kind, Comment: sf.Comment})
+	}
+	return fields, nil
+}
+
+// unescapeName returns the field name with escaped identifiers unescaped.
+func unescapeName(name string) (string, error) {
+	tokens, err := tokenize(name)
+	if err != nil {
+		return "", err
+	}
+
+	var s string
+	for _, t := range tokens {
+		s += t.value
+	}
+	return s, nil
+}
+
+// TableDefinitions returns the DEFINE statements of the table's fields, indexes and events.
+func TableDefinitions(ctx context.Context, db *surrealdb.DB, table string) (Definitions, error) {
+	info, err := surrealdb.Query[Definitions](ctx, db, fmt.Sprintf("INFO FOR TABLE %s;", table), nil)
+	if err != nil {
+		return Definitions{}, err
+	}
+	if info == nil || len(*info) == 0 {
+		return Definitions{}, ErrNoTableInfo
+	}
+	return (*info)[0].Result, nil
+}
+
+// TableDefinition returns the DEFINE TABLE statement of the table, as returned by INFO FOR DB,
+// which carries its schema mode, permissions, changefeed and comment.
+func TableDefinition(ctx context.Context, db *surrealdb.DB, table string) (string, error) {
+	type dbInfo struct {
+		Tables map[string]string `cbor:"tables"`
+	}
+	info, err := surrealdb.Query[dbInfo](ctx, db, "INFO FOR DB", nil)
+	if err != nil {
+		return "", err
+	}
+	if info == nil || len(*info) == 0 {
+		return "", ErrNoTableInfo
+	}
+
+	def, ok := (*info)[0].Result.Tables[table]
+	if !ok {
+		return "", fmt.Errorf("table %s is not defined", table)
+	}
+	return def, nil
+}
@@ -0,0 +1,159 @@
+package introspect
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	// tokenIdent is a keyword or an identifier, unescaped if it was enclosed in backticks or ⟨⟩
+	tokenIdent tokenKind = iota
+	// tokenString is a string literal, unescaped
+	tokenString
+	// tokenParam is a parameter like $value
+	tokenParam
+	// tokenPunct is a single punctuation character, like `<`, `[`, or `.`
+	tokenPunct
+)
+
+// token is a SurrealQL token.
+// start and end are the byte offsets of the token in the tokenized statement,
+// so that clauses can be cut out of, or replaced in, the statement as they were written.
+type token struct {
+	kind       tokenKind
+	value      string
+	start, end int
+}
+
+// is reports whether the token is the keyword, case-insensitively.
+func (t token) is(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.value, keyword)
+}
+
+// isPunct reports whether the token is the punctuation character.
+func (t token) isPunct(c string) bool {
+	return t.kind == tokenPunct && t.value == c
+}
+
+// tokenize splits a SurrealQL statement into tokens.
+//
+// It knows just enough of SurrealQL to find the clauses of DEFINE statements:
+// strings and escaped identifiers are read as a whole, so that keywords, quotes and brackets
+// within them are never mistaken for the statement's own.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '\'' || r == '"':
+			value, end, err := readQuoted(s, i, r)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, start: i, end: end})
+			i = end
+		case r == '`':
+			value, end, err := readQuoted(s, i, '`')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: value, start: i, end: end})
+			i = end
+		case r == '⟨':
+			value, end, err := readQuoted(s, i, '⟩')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: value, start: i, end: end})
+			i = end
+		case r == '$':
+			end := readWord(s, i+size)
+			tokens = append(tokens, token{kind: tokenParam, value: s[i:end], start: i, end: end})
+			i = end
+		case isWordRune(r):
+			end := readWord(s, i)
+			// Prefixed strings, like r'table:id' or d'2024-01-01'
+			if end-i == 1 && end < len(s) && strings.ContainsRune("rsdub", r) && (s[end] == '\'' || s[end] == '"') {
+				value, strEnd, err := readQuoted(s, end, rune(s[end]))
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: tokenString, value: value, start: i, end: strEnd})
+				i = strEnd
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: s[i:end], start: i, end: end})
+			i = end
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, value: string(r), start: i, end: i + size})
+			i += size
+		}
+	}
+	return tokens, nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// readWord returns the end offset of the word starting at offset i.
+func readWord(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isWordRune(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// readQuoted reads the quoted string or identifier starting at offset i,
+// returning its unescaped value and the end offset of the closing quote.
+func readQuoted(s string, i int, closing rune) (string, int, error) {
+	_, size := utf8.DecodeRuneInString(s[i:])
+	var b strings.Builder
+	for j := i + size; j < len(s); {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		j += size
+		switch {
+		case r == closing:
+			return b.String(), j, nil
+		case r == '\\' && j < len(s):
+			e, size := utf8.DecodeRuneInString(s[j:])
+			j += size
+			b.WriteRune(unescape(e))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c at offset %d in %s", closing, i, s)
+}
+
+// unescape returns the character the escape sequence of a backslash and e stands for.
+func unescape(e rune) rune {
+	switch e {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case '0':
+		return 0
+	default:
+		// Quotes, backslashes, and anything else escaped for no reason
+		return e
+	}
+}
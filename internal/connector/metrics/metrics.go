@@ -30,6 +30,11 @@ type Collector struct {
 	dbWritesCompleted atomic.Int64
 	dbWritesPerSecond float64
 	dbWriteErrors     atomic.Int64
+	dbWriteRetries    atomic.Int64
+
+	// Bulk database write metrics
+	dbBatchWrites    atomic.Int64
+	dbBatchWriteTime atomic.Int64 // in nanoseconds
 
 	// Timing metrics
 	lastResetTime    time.Time
@@ -40,6 +45,9 @@ type Collector struct {
 	memoryUsageMB   uint64
 	goroutineCount  int
 
+	// Cumulative labeled metrics exposed via the /metrics endpoint
+	registry *Registry
+
 	// Configuration
 	LogInterval time.Duration
 	logging     framework.Logger
@@ -55,6 +63,7 @@ func NewCollector(logging framework.Logger, logInterval time.Duration) *Collecto
 		lastResetTime: time.Now(),
 		LogInterval:   logInterval,
 		logging:       logging,
+		registry:      DefaultRegistry,
 	}
 
 	return mc
@@ -67,14 +76,17 @@ func (mc *Collector) Start(ctx context.Context) {
 }
 
 // RecordProcessed increments the processed records counter
-func (mc *Collector) RecordProcessed(count int64, bytes int64) {
+func (mc *Collector) RecordProcessed(l Labels, count int64, bytes int64) {
 	mc.recordsProcessed.Add(count)
 	mc.bytesProcessed.Add(bytes)
+	mc.registry.records.add(l, float64(count))
+	mc.registry.bytes.add(l, float64(bytes))
 }
 
 // FileProcessed increments the processed files counter
-func (mc *Collector) FileProcessed() {
+func (mc *Collector) FileProcessed(l Labels) {
 	mc.filesProcessed.Add(1)
+	mc.registry.files.add(l, 1)
 }
 
 // FileProcessingStarted increments the current file processing counter
@@ -84,24 +96,50 @@ func (mc *Collector) FileProcessingStarted() {
 }
 
 // FileProcessingCompleted decrements the current file processing counter
-func (mc *Collector) FileProcessingCompleted(duration time.Duration) {
+func (mc *Collector) FileProcessingCompleted(l Labels, duration time.Duration) {
 	mc.currentFileProcessing.Add(-1)
 	mc.totalProcessTime.Add(duration.Nanoseconds())
+	mc.registry.fileDuration.observe(l, duration.Seconds())
 }
 
 // FileProcessingError increments the file processing error counter
-func (mc *Collector) FileProcessingError() {
+func (mc *Collector) FileProcessingError(l Labels) {
 	mc.fileProcessingErrors.Add(1)
+	mc.registry.fileErrors.add(l, 1)
 }
 
 // DBWriteCompleted increments the database write counter
-func (mc *Collector) DBWriteCompleted(count int64) {
+func (mc *Collector) DBWriteCompleted(l Labels, count int64) {
+	mc.dbWritesCompleted.Add(count)
+	mc.registry.dbWrites.add(l, float64(count))
+}
+
+// DBBatchWriteCompleted records a multi-record write of count records that took duration.
+// The records are also counted as database writes.
+func (mc *Collector) DBBatchWriteCompleted(l Labels, count int64, duration time.Duration) {
 	mc.dbWritesCompleted.Add(count)
+	mc.dbBatchWrites.Add(1)
+	mc.dbBatchWriteTime.Add(duration.Nanoseconds())
+	mc.registry.dbWrites.add(l, float64(count))
+	mc.registry.dbWriteDuration.observe(l, duration.Seconds())
 }
 
 // DBWriteError increments the database write error counter
-func (mc *Collector) DBWriteError() {
+func (mc *Collector) DBWriteError(l Labels) {
 	mc.dbWriteErrors.Add(1)
+	mc.registry.dbWriteErrors.add(l, 1)
+}
+
+// DBWriteRetried increments the database write retry counter
+func (mc *Collector) DBWriteRetried(l Labels) {
+	mc.dbWriteRetries.Add(1)
+	mc.registry.dbWriteRetries.add(l, 1)
+}
+
+// Flush logs the metrics of the current interval right away,
+// like a final snapshot once the periodic logging is stopped on shutdown.
+func (mc *Collector) Flush() {
+	mc.logMetrics()
 }
 
 // periodicLogger logs metrics at regular intervals
@@ -189,13 +227,22 @@ func (mc *Collector) logMetrics() {
 	files := mc.filesProcessed.Load()
 	errors := mc.fileProcessingErrors.Load()
 	dbErrors := mc.dbWriteErrors.Load()
+	dbRetries := mc.dbWriteRetries.Load()
 	totalProcessNanos := mc.totalProcessTime.Load()
+	dbBatchWrites := mc.dbBatchWrites.Load()
+	dbBatchWriteNanos := mc.dbBatchWriteTime.Load()
 
 	// Averages
 	avgFileProcessingMs := float64(0)
 	if totalFileProc > 0 {
 		avgFileProcessingMs = float64(totalProcessNanos) / float64(totalFileProc) / 1e6
 	}
+	avgDBBatchSize := float64(0)
+	avgDBBatchWriteMs := float64(0)
+	if dbBatchWrites > 0 {
+		avgDBBatchSize = float64(dbWrites) / float64(dbBatchWrites)
+		avgDBBatchWriteMs = float64(dbBatchWriteNanos) / float64(dbBatchWrites) / 1e6
+	}
 
 	mc.logging.LogInfo("Connector Performance Metrics",
 		"interval_seconds", elapsed,
@@ -207,11 +254,15 @@ func (mc *Collector) logMetrics() {
 		"files_processed", files,
 		"db_writes", dbWrites,
 		"db_writes_per_second", mc.dbWritesPerSecond,
+		"db_batch_writes", dbBatchWrites,
+		"avg_db_batch_size", avgDBBatchSize,
+		"avg_db_batch_write_ms", avgDBBatchWriteMs,
 		"current_file_processing", currentFileProc,
 		"total_file_processing", totalFileProc,
 		"avg_file_processing_ms", avgFileProcessingMs,
 		"file_processing_errors", errors,
 		"db_write_errors", dbErrors,
+		"db_write_retries", dbRetries,
 		"cpu_usage_percent", cpuUsage,
 		"memory_usage_mb", memUsage,
 		"goroutines", goroutines,
@@ -225,6 +276,9 @@ func (mc *Collector) logMetrics() {
 	mc.totalFileProcessing.Store(0)
 	mc.fileProcessingErrors.Store(0)
 	mc.dbWriteErrors.Store(0)
+	mc.dbWriteRetries.Store(0)
+	mc.dbBatchWrites.Store(0)
+	mc.dbBatchWriteTime.Store(0)
 	mc.totalProcessTime.Store(0)
 	mc.lastResetTime = time.Now()
 }
@@ -21,10 +21,10 @@ func TestMetricsCollector(t *testing.T) {
 
 	// Simulate some file_procesing
 	mc.FileProcessingStarted()
-	mc.RecordProcessed(100, 1024)
-	mc.FileProcessed()
-	mc.DBWriteCompleted(50)
-	mc.FileProcessingCompleted(10 * time.Millisecond)
+	mc.RecordProcessed(Labels{}, 100, 1024)
+	mc.FileProcessed(Labels{})
+	mc.DBWriteCompleted(Labels{}, 50)
+	mc.FileProcessingCompleted(Labels{}, 10*time.Millisecond)
 
 	// Wait for at least one logging interval
 	time.Sleep(150 * time.Millisecond)
@@ -51,8 +51,8 @@ func TestMetricsCollector(t *testing.T) {
 	assert.NotNil(t, perfMsg.Fields["goroutines"])
 
 	// Test error counters
-	mc.FileProcessingError()
-	mc.DBWriteError()
+	mc.FileProcessingError(Labels{})
+	mc.DBWriteError(Labels{})
 
 	assert.Equal(t, int64(1), mc.fileProcessingErrors.Load())
 	assert.Equal(t, int64(1), mc.dbWriteErrors.Load())
@@ -78,8 +78,8 @@ func TestMetricsInServer(t *testing.T) {
 	mc.Start(ctx)
 
 	// Simulate some metrics
-	mc.RecordProcessed(10, 100)
-	mc.DBWriteCompleted(5)
+	mc.RecordProcessed(Labels{}, 10, 100)
+	mc.DBWriteCompleted(Labels{}, 5)
 
 	// Wait for metrics to be logged
 	time.Sleep(150 * time.Millisecond)
@@ -105,9 +105,9 @@ func TestMetricsCollectorWithFileProcessing(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		mc.FileProcessingStarted()
 		time.Sleep(5 * time.Millisecond)
-		mc.RecordProcessed(20, 200)
-		mc.DBWriteCompleted(20)
-		mc.FileProcessingCompleted(5 * time.Millisecond)
+		mc.RecordProcessed(Labels{}, 20, 200)
+		mc.DBWriteCompleted(Labels{}, 20)
+		mc.FileProcessingCompleted(Labels{}, 5*time.Millisecond)
 	}
 
 	// Wait for metrics log
@@ -128,3 +128,40 @@ func TestMetricsCollectorWithFileProcessing(t *testing.T) {
 	assert.Greater(t, avgOpTime, 4.0)
 	assert.Less(t, avgOpTime, 10.0)
 }
+
+func TestMetricsCollectorWithBatchWrites(t *testing.T) {
+	mockLogger := NewMockLogging()
+	mc := NewCollector(mockLogger, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mc.Start(ctx)
+
+	mc.DBBatchWriteCompleted(Labels{}, 1000, 20*time.Millisecond)
+	mc.DBBatchWriteCompleted(Labels{}, 500, 10*time.Millisecond)
+
+	// Wait for metrics log
+	time.Sleep(100 * time.Millisecond)
+
+	perfMsg := mockLogger.FindMessage("Connector Performance Metrics")
+	require.NotNil(t, perfMsg, "Should have logged performance metrics")
+
+	assert.Equal(t, int64(1500), perfMsg.Fields["db_writes"])
+	assert.Equal(t, int64(2), perfMsg.Fields["db_batch_writes"])
+	assert.Equal(t, 750.0, perfMsg.Fields["avg_db_batch_size"])
+	assert.InDelta(t, 15.0, perfMsg.Fields["avg_db_batch_write_ms"].(float64), 0.001)
+}
+
+func TestMetricsCollectorFlush(t *testing.T) {
+	mockLogger := NewMockLogging()
+	// An interval never reached by the test, so that only Flush logs metrics
+	mc := NewCollector(mockLogger, time.Hour)
+
+	mc.RecordProcessed(Labels{}, 10, 100)
+	mc.Flush()
+
+	perfMsg := mockLogger.FindMessage("Connector Performance Metrics")
+	require.NotNil(t, perfMsg, "Flush should log performance metrics")
+	assert.Equal(t, int64(10), perfMsg.Fields["records_processed"])
+}
@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Operations used as the operation label of the metrics.
+const (
+	OperationReplace       = "replace"
+	OperationUpdate        = "update"
+	OperationDelete        = "delete"
+	OperationEarliestStart = "earliest_start"
+)
+
+// Labels identify what a metric is recorded for.
+type Labels struct {
+	// RPC is the name of the RPC, like WriteBatch
+	RPC string
+	// Schema is the Fivetran schema
+	Schema string
+	// Table is the Fivetran table
+	Table string
+	// Operation is the kind of batch file being processed, like replace
+	Operation string
+}
+
+func (l Labels) values() []string {
+	return []string{l.RPC, l.Schema, l.Table, l.Operation}
+}
+
+var labelNames = []string{"rpc", "schema", "table", "operation"}
+
+type labelsKey struct{}
+
+// WithLabels returns a context carrying the labels of the metrics recorded within it.
+func WithLabels(ctx context.Context, l Labels) context.Context {
+	return context.WithValue(ctx, labelsKey{}, l)
+}
+
+// WithOperation returns a context carrying the labels of ctx with the operation replaced.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	l := LabelsFromContext(ctx)
+	l.Operation = operation
+	return WithLabels(ctx, l)
+}
+
+// LabelsFromContext returns the labels carried by ctx, or empty labels if there are none.
+func LabelsFromContext(ctx context.Context) Labels {
+	l, _ := ctx.Value(labelsKey{}).(Labels)
+	return l
+}
+
+// durationBuckets are the upper bounds in seconds of the duration histograms.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// counterVec is a cumulative counter for each set of labels.
+type counterVec struct {
+	name   string
+	help   string
+	mu     sync.Mutex
+	values map[Labels]float64
+}
+
+func (c *counterVec) add(l Labels, v float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[l] += v
+}
+
+// histogram counts the observed values in cumulative buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// histogramVec is a cumulative histogram for each set of labels.
+type histogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[Labels]*histogram
+}
+
+func (h *histogramVec) observe(l Labels, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[l]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[l] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += v
+	hist.count++
+}
+
+// Registry holds the cumulative connector metrics,
+// and exposes them in the Prometheus text format.
+//
+// Unlike the metrics logged by Collector, they are never reset,
+// so that they can be scraped and graphed by a monitoring system.
+type Registry struct {
+	records         *counterVec
+	bytes           *counterVec
+	files           *counterVec
+	fileErrors      *counterVec
+	dbWrites        *counterVec
+	dbWriteErrors   *counterVec
+	dbWriteRetries  *counterVec
+	fileDuration    *histogramVec
+	dbWriteDuration *histogramVec
+}
+
+// DefaultRegistry is the registry collectors record to, and Handler exposes.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	counter := func(name, help string) *counterVec {
+		return &counterVec{name: name, help: help, values: map[Labels]float64{}}
+	}
+	hist := func(name, help string) *histogramVec {
+		return &histogramVec{name: name, help: help, buckets: durationBuckets, values: map[Labels]*histogram{}}
+	}
+
+	return &Registry{
+		records:         counter("surreal_fivetran_records_processed_total", "Number of batch file records processed."),
+		bytes:           counter("surreal_fivetran_bytes_processed_total", "Approximate number of batch file bytes processed."),
+		files:           counter("surreal_fivetran_files_processed_total", "Number of batch files processed."),
+		fileErrors:      counter("surreal_fivetran_file_processing_errors_total", "Number of batch file processing errors."),
+		dbWrites:        counter("surreal_fivetran_db_writes_total", "Number of records written to SurrealDB."),
+		dbWriteErrors:   counter("surreal_fivetran_db_write_errors_total", "Number of failed writes to SurrealDB."),
+		dbWriteRetries:  counter("surreal_fivetran_db_write_retries_total", "Number of writes to SurrealDB retried after a transient failure."),
+		fileDuration:    hist("surreal_fivetran_file_processing_duration_seconds", "Time spent processing the batch files of an operation."),
+		dbWriteDuration: hist("surreal_fivetran_db_batch_write_duration_seconds", "Time spent on each multi-record write to SurrealDB."),
+	}
+}
+
+// Handler returns the HTTP handler exposing DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, c := range []*counterVec{r.records, r.bytes, r.files, r.fileErrors, r.dbWrites, r.dbWriteErrors, r.dbWriteRetries} {
+		writeCounter(bw, c)
+	}
+	for _, h := range []*histogramVec{r.fileDuration, r.dbWriteDuration} {
+		writeHistogram(bw, h)
+	}
+
+	return bw.Flush()
+}
+
+func writeCounter(w *bufio.Writer, c *counterVec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, l := range sortedLabels(c.values) {
+		fmt.Fprintf(w, "%s{%s} %s\n", c.name, formatLabels(l, ""), formatValue(c.values[l]))
+	}
+}
+
+func writeHistogram(w *bufio.Writer, h *histogramVec) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, l := range sortedLabels(h.values) {
+		hist := h.values[l]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, formatLabels(l, formatValue(upper)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, formatLabels(l, "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, formatLabels(l, ""), formatValue(hist.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, formatLabels(l, ""), hist.count)
+	}
+}
+
+// sortedLabels returns the labels of the values in a stable order.
+func sortedLabels[V any](values map[Labels]V) []Labels {
+	labels := make([]Labels, 0, len(values))
+	for l := range values {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return strings.Join(labels[i].values(), "\x00") < strings.Join(labels[j].values(), "\x00")
+	})
+	return labels
+}
+
+// formatLabels formats the labels, with the le label of histogram buckets if le is not empty.
+func formatLabels(l Labels, le string) string {
+	var b strings.Builder
+	for i, v := range l.values() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", labelNames[i], escapeLabelValue(v))
+	}
+	if le != "" {
+		fmt.Fprintf(&b, ",le=\"%s\"", le)
+	}
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_CumulativeLabeledMetrics(t *testing.T) {
+	mc := NewCollector(NewMockLogging(), time.Hour)
+	mc.registry = NewRegistry()
+
+	replace := Labels{RPC: "WriteBatch", Schema: "sales", Table: "orders", Operation: OperationReplace}
+	update := Labels{RPC: "WriteBatch", Schema: "sales", Table: "orders", Operation: OperationUpdate}
+
+	mc.RecordProcessed(replace, 10, 100)
+	mc.RecordProcessed(update, 5, 50)
+	mc.DBBatchWriteCompleted(replace, 10, 20*time.Millisecond)
+	mc.DBWriteError(update)
+	mc.DBWriteRetried(update)
+	mc.FileProcessingCompleted(replace, 2*time.Second)
+
+	// Logging the interval metrics resets them, but not the cumulative ones
+	mc.logMetrics()
+	mc.RecordProcessed(replace, 3, 30)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	var out strings.Builder
+	require.NoError(t, mc.registry.Write(&out))
+	body := out.String()
+
+	assert.Contains(t, body, "# TYPE surreal_fivetran_records_processed_total counter\n")
+	assert.Contains(t, body, `surreal_fivetran_records_processed_total{rpc="WriteBatch",schema="sales",table="orders",operation="replace"} 13`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_records_processed_total{rpc="WriteBatch",schema="sales",table="orders",operation="update"} 5`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_bytes_processed_total{rpc="WriteBatch",schema="sales",table="orders",operation="replace"} 130`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_db_writes_total{rpc="WriteBatch",schema="sales",table="orders",operation="replace"} 10`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_db_write_errors_total{rpc="WriteBatch",schema="sales",table="orders",operation="update"} 1`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_db_write_retries_total{rpc="WriteBatch",schema="sales",table="orders",operation="update"} 1`+"\n")
+
+	assert.Contains(t, body, "# TYPE surreal_fivetran_file_processing_duration_seconds histogram\n")
+	assert.Contains(t, body, `surreal_fivetran_file_processing_duration_seconds_bucket{rpc="WriteBatch",schema="sales",table="orders",operation="replace",le="1"} 0`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_file_processing_duration_seconds_bucket{rpc="WriteBatch",schema="sales",table="orders",operation="replace",le="2.5"} 1`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_file_processing_duration_seconds_bucket{rpc="WriteBatch",schema="sales",table="orders",operation="replace",le="+Inf"} 1`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_file_processing_duration_seconds_sum{rpc="WriteBatch",schema="sales",table="orders",operation="replace"} 2`+"\n")
+	assert.Contains(t, body, `surreal_fivetran_db_batch_write_duration_seconds_count{rpc="WriteBatch",schema="sales",table="orders",operation="replace"} 1`+"\n")
+}
+
+func TestFormatLabels_EscapesValues(t *testing.T) {
+	l := Labels{RPC: "WriteBatch", Schema: `a"b`, Table: `c\d`, Operation: "e\nf"}
+	assert.Equal(t, `rpc="WriteBatch",schema="a\"b",table="c\\d",operation="e\nf"`, formatLabels(l, ""))
+}
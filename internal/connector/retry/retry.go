@@ -0,0 +1,158 @@
+// Package retry runs operations again on transient SurrealDB failures,
+// like transaction conflicts and dropped connections, with exponential backoff and jitter.
+//
+// Only idempotent operations must be retried, as a failure does not tell
+// whether SurrealDB applied the operation before the connection dropped.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// Policy is how many times and how long apart an operation is attempted.
+//
+// The zero Policy attempts operations once, never retrying them.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, which doubles on each retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+
+	// OnRetry, if set, is called before waiting delay to make the attempt following the failed one
+	OnRetry func(ctx context.Context, attempt int, err error, delay time.Duration)
+}
+
+// DefaultPolicy returns the policy retrying up to 4 times within about 3 seconds,
+// which leaves time for a dropped WebSocket to reconnect.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    5,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// Do runs op until it succeeds, fails with an error that is not transient,
+// or fails MaxAttempts times, and returns the last error.
+// It stops waiting to retry once ctx is done.
+func (p Policy) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !IsTransient(err) {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := p.Backoff(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the delay after the failed attempt, starting from 1.
+//
+// The delay grows exponentially from InitialBackoff up to MaxBackoff,
+// and is jittered down by up to half, so that the writers failing at once
+// do not conflict again by retrying at once.
+func (p Policy) Backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// transientMessages are the SurrealDB errors of queries that can succeed when run again.
+var transientMessages = []string{
+	// Failed to commit transaction due to a read or write conflict. This transaction can be retried
+	"can be retried",
+	"transaction conflict",
+	"write conflict",
+	"resource busy",
+}
+
+// connectionMessages are the errors of connections dropped while sending a query.
+var connectionMessages = []string{
+	"connection is closed",
+	"response channel closed",
+	"use of closed network connection",
+	"connection reset by peer",
+	"broken pipe",
+	"websocket: close",
+	"unexpected EOF",
+}
+
+// IsTransient reports whether the failure may go away by itself,
+// that is a transaction conflict, a dropped connection, or a timed-out request.
+//
+// Other errors SurrealDB returned for the query, like a syntax error,
+// fail the same way every time, and are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	if errors.Is(err, &surrealdb.QueryError{}) || errors.Is(err, &surrealdb.RPCError{}) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	for _, m := range connectionMessages {
+		if strings.Contains(msg, strings.ToLower(m)) {
+			return true
+		}
+	}
+	return false
+}
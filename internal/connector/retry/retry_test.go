@@ -0,0 +1,142 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+var errConflict = &surrealdb.QueryError{Message: "Failed to commit transaction due to a read or write conflict. This transaction can be retried"}
+
+func TestPolicy_Do(t *testing.T) {
+	p := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	t.Run("retries transient failures until success", func(t *testing.T) {
+		var attempts int
+		var retried []int
+		p := p
+		p.OnRetry = func(_ context.Context, attempt int, err error, _ time.Duration) {
+			require.ErrorIs(t, err, errConflict)
+			retried = append(retried, attempt)
+		}
+
+		err := p.Do(context.Background(), func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errConflict
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, []int{1, 2}, retried)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var attempts int
+		err := p.Do(context.Background(), func(context.Context) error {
+			attempts++
+			return errConflict
+		})
+		require.ErrorIs(t, err, errConflict)
+		require.Contains(t, err.Error(), "gave up after 3 attempts")
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("never retries other failures", func(t *testing.T) {
+		syntaxErr := &surrealdb.QueryError{Message: "Parse error: Unexpected token"}
+
+		var attempts int
+		err := p.Do(context.Background(), func(context.Context) error {
+			attempts++
+			return syntaxErr
+		})
+		require.Equal(t, syntaxErr, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("zero policy attempts once", func(t *testing.T) {
+		var attempts int
+		err := Policy{}.Do(context.Background(), func(context.Context) error {
+			attempts++
+			return errConflict
+		})
+		require.Equal(t, errConflict, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops waiting once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := Policy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		p.OnRetry = func(context.Context, int, error, time.Duration) { cancel() }
+
+		var attempts int
+		err := p.Do(ctx, func(context.Context) error {
+			attempts++
+			return errConflict
+		})
+		require.ErrorIs(t, err, errConflict)
+		require.Equal(t, 1, attempts)
+	})
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for _, tt := range []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	} {
+		for range 20 {
+			d := p.Backoff(tt.attempt)
+			require.GreaterOrEqual(t, d, tt.max/2, "attempt %d", tt.attempt)
+			require.LessOrEqual(t, d, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+
+	require.Zero(t, Policy{}.Backoff(1))
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"transaction conflict", errConflict, true},
+		{"wrapped transaction conflict", fmt.Errorf("unable to write 10 records in bulk: %w", errConflict), true},
+		{"resource busy", &surrealdb.QueryError{Message: "Transaction conflict: Resource busy"}, true},
+		{"query error", &surrealdb.QueryError{Message: "Found 'abc' for field `amount`, but expected a int"}, false},
+		{"rpc error", fmt.Errorf("query failed: %w", &surrealdb.RPCError{Code: -32000, Message: "There was a problem with the database"}), false},
+		{"closed connection", errors.New("connection is closed"), true},
+		{"closed response channel", errors.New("response channel closed"), true},
+		{"websocket close", errors.New("websocket: close 1006 (abnormal closure): unexpected EOF"), true},
+		{"eof", fmt.Errorf("read: %w", io.EOF), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"request timeout", fmt.Errorf("send: %w", context.DeadlineExceeded), true},
+		{"canceled", fmt.Errorf("send: %w", context.Canceled), false},
+		{"other", errors.New("unable to get primary key columns"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsTransient(tt.err))
+		})
+	}
+}
@@ -2,27 +2,162 @@ package connector
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/surrealdb/fivetran-destination/internal/connector/server"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
-func Serve(lis net.Listener, logger zerolog.Logger) error {
+// DefaultDrainTimeout is how long a graceful shutdown waits for in-flight RPCs by default.
+const DefaultDrainTimeout = 30 * time.Second
+
+// ServeOption configures the gRPC server of Serve.
+type ServeOption func(*serveOptions)
+
+type serveOptions struct {
+	tlsConfig *tls.Config
+
+	// shutdown stops the server once done, waiting up to drainTimeout for in-flight RPCs
+	shutdown     context.Context
+	drainTimeout time.Duration
+
+	// healthEndpoint is the SurrealDB endpoint whose reachability the readiness requires, if any
+	healthEndpoint string
+	healthInterval time.Duration
+
+	reflection bool
+}
+
+// WithTLS makes Serve serve TLS with the config, like the one of TLSConfig.ServerConfig.
+func WithTLS(cfg *tls.Config) ServeOption {
+	return func(o *serveOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithGracefulShutdown makes Serve shut down once ctx is done, like when the connector receives SIGTERM.
+//
+// The server stops accepting new RPCs, and waits up to drainTimeout for the in-flight ones to complete.
+// Then, the contexts of the remaining RPCs are canceled, and Serve waits for them to return,
+// so that the pooled connections are only closed once no RPC uses them.
+func WithGracefulShutdown(ctx context.Context, drainTimeout time.Duration) ServeOption {
+	return func(o *serveOptions) {
+		o.shutdown = ctx
+		o.drainTimeout = drainTimeout
+	}
+}
+
+// WithHealthCheck makes the readiness reported by the grpc.health.v1 service require
+// the SurrealDB instance of the endpoint, like wss://your.surrealdb.instance/rpc, to be reachable,
+// which is checked every interval.
+func WithHealthCheck(endpoint string, interval time.Duration) ServeOption {
+	return func(o *serveOptions) {
+		o.healthEndpoint = endpoint
+		o.healthInterval = interval
+	}
+}
+
+// WithReflection registers the gRPC server reflection service,
+// so that clients like grpcurl can call the connector without its protos.
+func WithReflection() ServeOption {
+	return func(o *serveOptions) {
+		o.reflection = true
+	}
+}
+
+func Serve(lis net.Listener, logger zerolog.Logger, opts ...ServeOption) error {
+	var o serveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Create a new gRPC server with increased message size limits
-	s := grpc.NewServer(
-		grpc.MaxRecvMsgSize(1024*1024*50), // 50MB
-		grpc.MaxSendMsgSize(1024*1024*50), // 50MB
-	)
+	grpcOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(1024 * 1024 * 50), // 50MB
+		grpc.MaxSendMsgSize(1024 * 1024 * 50), // 50MB
+		// Make Stop wait for the canceled RPCs to return, before we close the pooled connections
+		grpc.WaitForHandlers(true),
+		// Start the root span of each RPC
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),
+	}
+	if o.tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(o.tlsConfig)))
+	}
+	s := grpc.NewServer(grpcOpts...)
 	srv := server.New(logger)
 
-	// Start server components (metrics collector, etc.)
-	ctx := context.Background()
+	// Start server components (metrics collector, etc.), which run until we stop serving
+	ctx, cancel := context.WithCancel(context.Background())
 	srv.Start(ctx)
 
 	pb.RegisterDestinationConnectorServer(s, srv)
 
-	return s.Serve(lis)
+	// Report the liveness and readiness of the connector
+	health := newHealthChecker(o.healthEndpoint, o.healthInterval, logger)
+	health.Start(ctx)
+	healthgrpc.RegisterHealthServer(s, health)
+
+	if o.reflection {
+		reflection.Register(s)
+	}
+
+	// Close the pooled connections once we stop serving,
+	// after stopping the server components so that the final metrics snapshot is the last one
+	defer srv.Close(context.Background())
+	defer cancel()
+
+	served := make(chan struct{})
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		if o.shutdown == nil {
+			return
+		}
+		select {
+		case <-served:
+		case <-o.shutdown.Done():
+			logger.Info().Dur("drain_timeout", o.drainTimeout).Msg("Shutting down, draining in-flight RPCs")
+			// Report NOT_SERVING while draining, so that no new RPCs are routed to the connector
+			health.Shutdown()
+			drain(s, o.drainTimeout, logger)
+		}
+	}()
+
+	// Serve returns as soon as the server stops accepting connections, before the in-flight RPCs are drained
+	err := s.Serve(lis)
+	close(served)
+	<-drained
+
+	return err
+}
+
+// drain stops the server gracefully, waiting up to timeout for the in-flight RPCs to complete,
+// before canceling the remaining ones.
+func drain(s *grpc.Server, timeout time.Duration, logger zerolog.Logger) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+		logger.Info().Msg("Drained in-flight RPCs")
+	case <-timer.C:
+		logger.Warn().Dur("drain_timeout", timeout).Msg("Drain timeout exceeded, canceling in-flight RPCs")
+		// Stop cancels the contexts of the remaining RPCs, and makes GracefulStop return once they do
+		s.Stop()
+		<-stopped
+	}
 }
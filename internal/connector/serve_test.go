@@ -0,0 +1,114 @@
+package connector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	shutdown, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(lis, zerolog.Nop(), WithGracefulShutdown(shutdown, time.Second))
+	}()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = pb.NewDestinationConnectorClient(conn).ConfigurationForm(t.Context(), &pb.ConfigurationFormRequest{})
+	require.NoError(t, err)
+
+	stop()
+	select {
+	case err := <-served:
+		assert.NoError(t, err, "a graceful shutdown should not be an error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the shutdown")
+	}
+}
+
+func TestServe_HealthAndReflection(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	shutdown, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() { _ = Serve(lis, zerolog.Nop(), WithGracefulShutdown(shutdown, time.Second), WithReflection()) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(t.Context(), &healthpb.HealthCheckRequest{Service: connectorService})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	listed, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, s := range listed.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	assert.Contains(t, services, connectorService)
+	assert.Contains(t, services, healthpb.Health_ServiceDesc.ServiceName)
+}
+
+func TestDrain_CancelsRPCsAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	// A server whose every RPC blocks until canceled
+	s := grpc.NewServer(
+		grpc.WaitForHandlers(true),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			close(started)
+			<-stream.Context().Done()
+			close(canceled)
+			return stream.Context().Err()
+		}),
+	)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	go func() {
+		_ = conn.Invoke(context.Background(), "/test.Blocking/Block", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	<-started
+
+	begin := time.Now()
+	drain(s, 100*time.Millisecond, zerolog.Nop())
+
+	assert.GreaterOrEqual(t, time.Since(begin), 100*time.Millisecond, "drain should wait for the in-flight RPC")
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("drain returned before the in-flight RPC was canceled")
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// defaultWriteChunkSize is the default number of records written by a single statement.
+const defaultWriteChunkSize = 1000
+
+// chunkBuffer buffers rows and flushes them in chunks of up to size rows.
+//
+// The caller must call Flush after the last Add to write the remaining rows.
+type chunkBuffer[T any] struct {
+	size  int
+	rows  []T
+	flush func(rows []T) error
+}
+
+func newChunkBuffer[T any](size int, flush func(rows []T) error) *chunkBuffer[T] {
+	if size <= 0 {
+		size = defaultWriteChunkSize
+	}
+	return &chunkBuffer[T]{
+		size:  size,
+		rows:  make([]T, 0, size),
+		flush: flush,
+	}
+}
+
+// Add buffers the row, and flushes the buffer once it is full.
+func (c *chunkBuffer[T]) Add(row T) error {
+	c.rows = append(c.rows, row)
+	if len(c.rows) >= c.size {
+		return c.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered rows, if any.
+func (c *chunkBuffer[T]) Flush() error {
+	if len(c.rows) == 0 {
+		return nil
+	}
+	if err := c.flush(c.rows); err != nil {
+		return err
+	}
+	c.rows = c.rows[:0]
+	return nil
+}
+
+// Abort discards the buffered rows.
+func (c *chunkBuffer[T]) Abort() {
+	c.rows = c.rows[:0]
+}
+
+// bulkKind is how a bulkRow is written.
+type bulkKind int
+
+const (
+	// bulkReplace replaces the whole content of the record, creating it if it does not exist.
+	bulkReplace bulkKind = iota
+	// bulkUpdate replaces the whole content of the record, only if it already exists.
+	bulkUpdate
+	// bulkMerge merges the content into the record, creating it if it does not exist.
+	// This is how we deal with unmodified columns, which are omitted from the content.
+	bulkMerge
+)
+
+// bulkRow is a record to be written by a bulk statement.
+type bulkRow struct {
+	id      models.RecordID
+	content map[string]any
+	kind    bulkKind
+	// edge is the endpoints of the record if it is an edge of a relation table, or nil otherwise
+	edge *bulkEdge
+	// source is the batch file row the record was read from in quarantine mode, or nil otherwise
+	source *batchRow
+}
+
+// bulkEdge is the endpoints of an edge of a relation table.
+//
+// They are only set when the edge is created, as they are identified by primary key columns,
+// so that an existing edge always relates the same records.
+type bulkEdge struct {
+	in, out models.RecordID
+}
+
+func bulkRowID(row bulkRow) models.RecordID {
+	return row.id
+}
+
+func bulkRowSource(row bulkRow) *batchRow {
+	return row.source
+}
+
+func recordID(id models.RecordID) models.RecordID {
+	return id
+}
+
+// tableFields returns the names of the fields of the table written by bulk statements, which are all but id.
+func tableFields(fields map[string]tablemapper.ColumnInfo) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if name != "id" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// bulkWriteQuery returns the function returning the query writing the rows to the table with the fields,
+// and its parameters.
+//
+// Consecutive rows of the same kind are written by a single INSERT statement,
+// so that the rows for the same record are still written in order.
+func bulkWriteQuery(fields []string) func(rows []bulkRow) (string, map[string]any) {
+	return func(rows []bulkRow) (string, map[string]any) {
+		var query strings.Builder
+		vars := map[string]any{}
+
+		for start := 0; start < len(rows); {
+			end := start + 1
+			for end < len(rows) && rows[end].kind == rows[start].kind {
+				end++
+			}
+
+			param := fmt.Sprintf("rows%d", len(vars))
+			query.WriteString(bulkInsertStatement(rows[start].id.Table, fields, rows[start].kind, param, rows[start:end]))
+			query.WriteString("\n")
+
+			contents := make([]map[string]any, 0, end-start)
+			for _, row := range rows[start:end] {
+				content := make(map[string]any, len(row.content)+1)
+				for k, v := range row.content {
+					content[k] = v
+				}
+				content["id"] = row.id
+				if row.edge != nil {
+					content[tablemapper.RelationIn] = row.edge.in
+					content[tablemapper.RelationOut] = row.edge.out
+				}
+				contents = append(contents, content)
+			}
+			vars[param] = contents
+
+			start = end
+		}
+
+		return query.String(), vars
+	}
+}
+
+// bulkInsertStatement returns the INSERT statement writing the rows of the kind, passed as $param,
+// to the table with the fields.
+//
+// Existing records are updated with ON DUPLICATE KEY UPDATE,
+// where $input is the row that would have been inserted.
+// Edges of relation tables are written by INSERT RELATION, which is the only statement creating them in bulk.
+func bulkInsertStatement(table string, tableFields []string, kind bulkKind, param string, rows []bulkRow) string {
+	// The fields set by the statement, sorted so that the statement is deterministic.
+	// Rows replacing the whole content of records set every field of the table,
+	// so that the fields they omit are unset rather than left with their previous values.
+	fieldSet := map[string]struct{}{}
+	if kind != bulkMerge {
+		for _, f := range tableFields {
+			fieldSet[f] = struct{}{}
+		}
+	}
+	for _, row := range rows {
+		for k := range row.content {
+			fieldSet[k] = struct{}{}
+		}
+	}
+	fields := make([]string, 0, len(fieldSet))
+	for k := range fieldSet {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	insert := "INSERT"
+	if rows[0].edge != nil {
+		insert = "INSERT RELATION"
+	}
+
+	source := "$" + param
+	if kind == bulkUpdate {
+		// Updates never create records
+		source = fmt.Sprintf("$%s[WHERE record::exists(id)]", param)
+	}
+
+	if len(fields) == 0 {
+		// Nothing to update, but records still need to be created
+		return fmt.Sprintf("%s IGNORE INTO %s %s RETURN NONE;", insert, quoteIdent(table), source)
+	}
+
+	sets := make([]string, 0, len(fields))
+	for _, f := range fields {
+		field := quoteIdent(f)
+		if kind == bulkMerge {
+			// Fields omitted by the row are kept as they are
+			sets = append(sets, fmt.Sprintf("%s = IF object::keys($input) CONTAINS %s THEN $input.%s ELSE %s END", field, strconv.Quote(f), field, field))
+		} else {
+			// Fields omitted by the row are unset, as its content replaces the whole record
+			sets = append(sets, fmt.Sprintf("%s = $input.%s", field, field))
+		}
+	}
+
+	return fmt.Sprintf("%s INTO %s %s ON DUPLICATE KEY UPDATE %s RETURN NONE;", insert, quoteIdent(table), source, strings.Join(sets, ", "))
+}
+
+// quoteIdent quotes the table or field name so that it can be used in SurrealQL as is.
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// bulkDeleteQuery returns the query deleting every record in the list, and its parameters.
+func bulkDeleteQuery(ids []models.RecordID) (string, map[string]any) {
+	return `DELETE $ids RETURN NONE;`, map[string]any{"ids": ids}
+}
+
+// execBulkWrite runs the query writing count records, from first to last.
+// The first and last record IDs are only used to identify the failed chunk in errors.
+//
+// The query is retried on transient failures, as writing the same records again
+// with their whole content, or deleting them again, leaves them the same.
+func (s *Server) execBulkWrite(ctx context.Context, db *surrealdb.DB, query string, vars map[string]any, count int, first, last models.RecordID) error {
+	start := time.Now()
+
+	err := s.retry.Do(ctx, func(ctx context.Context) error {
+		_, err := tracing.Query[any](ctx, db, query, vars)
+		return err
+	})
+	if err != nil {
+		if s.metrics != nil {
+			s.metrics.DBWriteError(metrics.LabelsFromContext(ctx))
+		}
+		return fmt.Errorf("unable to write %d records in bulk, from %v to %v: %w", count, first, last, err)
+	}
+
+	// Track successful DB writes
+	if s.metrics != nil {
+		s.metrics.DBBatchWriteCompleted(metrics.LabelsFromContext(ctx), int64(count), time.Since(start))
+	}
+
+	if s.Debugging() {
+		s.LogDebug("Wrote records in bulk", "count", count, "duration", time.Since(start))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestChunkBuffer(t *testing.T) {
+	var flushed [][]int
+	chunk := newChunkBuffer(3, func(rows []int) error {
+		flushed = append(flushed, append([]int(nil), rows...))
+		return nil
+	})
+
+	for i := 1; i <= 7; i++ {
+		require.NoError(t, chunk.Add(i))
+	}
+	require.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}}, flushed)
+
+	require.NoError(t, chunk.Flush())
+	require.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, flushed)
+
+	// Flushing an empty buffer is a no-op
+	require.NoError(t, chunk.Flush())
+	require.Len(t, flushed, 3)
+}
+
+func TestChunkBuffer_FlushError(t *testing.T) {
+	chunk := newChunkBuffer(2, func(rows []int) error {
+		return errors.New("write failed")
+	})
+
+	require.NoError(t, chunk.Add(1))
+	require.ErrorContains(t, chunk.Add(2), "write failed")
+}
+
+func TestBulkWriteQuery(t *testing.T) {
+	id := func(v string) models.RecordID { return models.NewRecordID("users", []any{v}) }
+	rows := []bulkRow{
+		{id: id("user1"), content: map[string]any{"name": "Alice", "age": 20}, kind: bulkReplace},
+		{id: id("user2"), content: map[string]any{"name": "Bob"}, kind: bulkReplace},
+		{id: id("user1"), content: map[string]any{"age": 21}, kind: bulkMerge},
+		{id: id("user2"), content: map[string]any{"name": "Bobby", "age": 30}, kind: bulkUpdate},
+	}
+
+	// Rows replacing the whole content of records also unset the fields of the table they omit
+	fields := tableFields(map[string]tablemapper.ColumnInfo{"id": {Name: "id"}, "name": {Name: "name"}, "age": {Name: "age"}, "email": {Name: "email"}})
+
+	query, vars := bulkWriteQuery(fields)(rows)
+	require.Equal(t, "INSERT INTO `users` $rows0 ON DUPLICATE KEY UPDATE `age` = $input.`age`, `email` = $input.`email`, `name` = $input.`name` RETURN NONE;\n"+
+		"INSERT INTO `users` $rows1 ON DUPLICATE KEY UPDATE `age` = IF object::keys($input) CONTAINS \"age\" THEN $input.`age` ELSE `age` END RETURN NONE;\n"+
+		"INSERT INTO `users` $rows2[WHERE record::exists(id)] ON DUPLICATE KEY UPDATE `age` = $input.`age`, `email` = $input.`email`, `name` = $input.`name` RETURN NONE;\n",
+		query)
+	require.Equal(t, map[string]any{
+		"rows0": []map[string]any{
+			{"id": id("user1"), "name": "Alice", "age": 20},
+			{"id": id("user2"), "name": "Bob"},
+		},
+		"rows1": []map[string]any{
+			{"id": id("user1"), "age": 21},
+		},
+		"rows2": []map[string]any{
+			{"id": id("user2"), "name": "Bobby", "age": 30},
+		},
+	}, vars)
+}
+
+func TestWriteBatch_SuccessMultipleChunks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.writeChunkSize = 2
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	fileParams := testframework.GetTestFileParams()
+
+	// 5 records are replaced in 3 chunks
+	columns := []string{"_fivetran_id", "name", "age", "active"}
+	var replaceRecords [][]string
+	for i := 1; i <= 5; i++ {
+		replaceRecords = append(replaceRecords, []string{fmt.Sprintf("user%d", i), fmt.Sprintf("User %d", i), "20", "true"})
+	}
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, replaceRecords, replaceKey)
+
+	// Updates with and without unmodified columns are mixed within a chunk
+	updateRecords := [][]string{
+		{"user1", "Alice", fileParams.UnmodifiedString, "false"},
+		{"user2", "Bob", "30", "false"},
+		{"user3", fileParams.UnmodifiedString, "40", fileParams.UnmodifiedString},
+	}
+	updateKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	updateFile := testframework.CreateEncryptedCSV(t, tempDir, "update.csv", columns, updateRecords, updateKey)
+
+	deleteRecords := [][]string{{"user4"}, {"user5"}}
+	deleteKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	deleteFile := testframework.CreateEncryptedCSV(t, tempDir, "delete.csv", []string{"_fivetran_id"}, deleteRecords, deleteKey)
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		UpdateFiles:   []string{updateFile},
+		DeleteFiles:   []string{deleteFile},
+		Keys:          map[string][]byte{replaceFile: replaceKey, updateFile: updateKey, deleteFile: deleteKey},
+		FileParams:    fileParams,
+	})
+	require.NoError(t, err)
+	success, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+	require.True(t, success.Success)
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 3)
+	testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user1"},
+		map[string]interface{}{"name": "Alice", "age": uint64(20), "active": false})
+	testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user2"},
+		map[string]interface{}{"name": "Bob", "age": uint64(30), "active": false})
+	testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user3"},
+		map[string]interface{}{"name": "User 3", "age": uint64(40), "active": true})
+	testframework.AssertRecordNotExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user4"})
+}
+
+func TestWriteBatch_ReplaceUnsetsOmittedFields(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	fileParams := testframework.GetTestFileParams()
+
+	writeReplace := func(name string, columns []string, records [][]string) {
+		key, err := testframework.GenerateAESKey()
+		require.NoError(t, err)
+		file := testframework.CreateEncryptedCSV(t, tempDir, name, columns, records, key)
+
+		_, err = srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+			Configuration: config,
+			SchemaName:    schema,
+			Table:         table,
+			ReplaceFiles:  []string{file},
+			Keys:          map[string][]byte{file: key},
+			FileParams:    fileParams,
+		})
+		require.NoError(t, err)
+	}
+
+	writeReplace("replace1.csv", []string{"_fivetran_id", "name", "age", "active"}, [][]string{{"user1", "Alice", "20", "true"}})
+	// The second file has no age column, which replaces the record without age
+	writeReplace("replace2.csv", []string{"_fivetran_id", "name", "active"}, [][]string{{"user1", "Alice", "false"}})
+
+	records := testframework.QueryTable(t, config, "test", schema, table.Name)
+	require.Len(t, records, 1)
+	require.Equal(t, false, records[0]["active"])
+	require.NotContains(t, records[0], "age")
+}
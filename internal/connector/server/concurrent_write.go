@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// connectFunc acquires a connection to the database the batch is written to,
+// and returns the function to release it.
+type connectFunc func(ctx context.Context) (*surrealdb.DB, releaseFunc, error)
+
+// writeConns are the connections used by the write workers of a batch.
+//
+// Worker 0 always uses the batch's own connection, and the other workers acquire theirs
+// with connect on first use. The acquired connections are kept until release,
+// so that they are acquired once per batch rather than once per write phase.
+type writeConns struct {
+	db      *surrealdb.DB
+	connect connectFunc
+
+	mu       sync.Mutex
+	conns    map[int]*surrealdb.DB
+	releases map[int]releaseFunc
+	errs     map[int]error
+}
+
+// newWriteConns returns the connections for the write workers of a batch written on db.
+// When connect is nil, every worker uses db.
+func newWriteConns(db *surrealdb.DB, connect connectFunc) *writeConns {
+	return &writeConns{
+		db:       db,
+		connect:  connect,
+		conns:    map[int]*surrealdb.DB{},
+		releases: map[int]releaseFunc{},
+		errs:     map[int]error{},
+	}
+}
+
+// get returns the connection of the worker, acquiring it if needed.
+func (c *writeConns) get(ctx context.Context, worker int) (*surrealdb.DB, error) {
+	if worker == 0 || c.connect == nil {
+		return c.db, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if db, ok := c.conns[worker]; ok {
+		return db, nil
+	}
+
+	db, release, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[worker] = db
+	c.releases[worker] = release
+
+	return db, nil
+}
+
+// fail records that the worker failed with err,
+// so that its connection is released with err and discarded if it is broken.
+func (c *writeConns) fail(worker int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errs[worker] = err
+}
+
+// release releases the connections acquired by the workers.
+// The batch's own connection is released by the caller.
+func (c *writeConns) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for worker, release := range c.releases {
+		release(c.errs[worker])
+	}
+	c.conns = map[int]*surrealdb.DB{}
+	c.releases = map[int]releaseFunc{}
+	c.errs = map[int]error{}
+}
+
+// rowWriter writes the rows read from batch files.
+//
+// Add may buffer rows, so the caller must call Flush after the last Add
+// to make sure every row is written, or Abort to discard the buffered rows on failure.
+type rowWriter[T any] interface {
+	Add(row T) error
+	Flush() error
+	Abort()
+}
+
+// newRowWriter returns a rowWriter that writes rows in chunks, each with the query built by query.
+//
+// In transactional write mode, that is when tx is not nil, the chunks are added to tx,
+// which commits them in transactions. Rows are then written serially by the calling goroutine,
+// so that the committed rows are always the first rows of the batch.
+//
+// Otherwise, when the server is configured with a write concurrency greater than 1,
+// rows are sharded by the hash of their record ID across that many workers,
+// each with its own connection from conns.
+// Rows for the same record always go to the same worker in the order they were added,
+// so that the writes to the same record are never reordered.
+// Otherwise, rows are written on the batch's own connection by the calling goroutine.
+//
+// When ctx carries a quarantine and source is not nil, a chunk SurrealDB rejects is written again one row at a time,
+// and the rows SurrealDB rejects are quarantined with the batch file rows source returns.
+// In transactional write mode, a rejected transaction fails the batch instead,
+// as the rows it rolled back can't be told apart.
+func newRowWriter[T any](ctx context.Context, s *Server, conns *writeConns, tx *txWriter, id func(T) models.RecordID, source func(T) *batchRow, query func(rows []T) (string, map[string]any)) rowWriter[T] {
+	if tx != nil {
+		return newChunkBuffer(tx.size, func(rows []T) error {
+			q, vars := query(rows)
+			tx.Add(q, vars)
+			return tx.Done(ctx, len(rows))
+		})
+	}
+
+	bulkWrite := func(ctx context.Context, db *surrealdb.DB, rows []T) error {
+		q, vars := query(rows)
+		return s.execBulkWrite(ctx, db, q, vars, len(rows), id(rows[0]), id(rows[len(rows)-1]))
+	}
+
+	write := bulkWrite
+	if q := quarantineFrom(ctx); q != nil && source != nil {
+		write = func(ctx context.Context, db *surrealdb.DB, rows []T) error {
+			err := bulkWrite(ctx, db, rows)
+			if err == nil || !errors.Is(err, &surrealdb.QueryError{}) {
+				return err
+			}
+			return writeOneByOne(rows, source, func(rows []T) error {
+				return bulkWrite(ctx, db, rows)
+			}, func(row *batchRow, err error) error {
+				return q.reject(ctx, row, err)
+			})
+		}
+	}
+
+	if s.writeConcurrency <= 1 {
+		return newChunkBuffer(s.writeChunkSize, func(rows []T) error {
+			return write(ctx, conns.db, rows)
+		})
+	}
+
+	return newShardedWriter(ctx, s, s.writeConcurrency, conns, id, write)
+}
+
+// shardedWriter dispatches rows to a fixed number of workers by the hash of their record ID.
+//
+// A failure in one worker cancels the others, and is returned by the next Add or Flush.
+type shardedWriter[T any] struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	id     func(T) models.RecordID
+	shards []chan T
+	wg     sync.WaitGroup
+}
+
+var _ rowWriter[any] = &shardedWriter[any]{}
+
+func newShardedWriter[T any](ctx context.Context, s *Server, workers int, conns *writeConns, id func(T) models.RecordID, write func(ctx context.Context, db *surrealdb.DB, rows []T) error) *shardedWriter[T] {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	w := &shardedWriter[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		id:     id,
+		shards: make([]chan T, workers),
+	}
+
+	for i := range w.shards {
+		// Buffer a chunk worth of rows so that the reader rarely waits for the workers
+		w.shards[i] = make(chan T, s.writeChunkSize)
+		w.wg.Add(1)
+		go func(worker int, rows <-chan T) {
+			defer w.wg.Done()
+			if err := w.runWorker(s, worker, rows, conns, write); err != nil {
+				conns.fail(worker, err)
+				// Cancel before draining the remaining rows,
+				// so that the other workers and Add stop as soon as possible.
+				w.cancel(fmt.Errorf("write worker %d failed: %w", worker, err))
+				// Drain the remaining rows, so that Add never blocks on a dead worker
+				for range rows {
+				}
+			}
+		}(i, w.shards[i])
+	}
+
+	if s.Debugging() {
+		s.LogDebug("Started write workers", "workers", workers)
+	}
+
+	return w
+}
+
+func (w *shardedWriter[T]) runWorker(s *Server, worker int, rows <-chan T, conns *writeConns, write func(ctx context.Context, db *surrealdb.DB, rows []T) error) error {
+	db, err := conns.get(w.ctx, worker)
+	if err != nil {
+		return err
+	}
+
+	chunk := newChunkBuffer(s.writeChunkSize, func(rows []T) error {
+		return write(w.ctx, db, rows)
+	})
+
+	for row := range rows {
+		if err := w.ctx.Err(); err != nil {
+			return context.Cause(w.ctx)
+		}
+		if err := chunk.Add(row); err != nil {
+			return err
+		}
+	}
+
+	return chunk.Flush()
+}
+
+// Add dispatches the row to the worker responsible for its record ID.
+func (w *shardedWriter[T]) Add(row T) error {
+	// Fail fast once a worker has failed, even if the shard still has room for the row
+	if w.ctx.Err() != nil {
+		return context.Cause(w.ctx)
+	}
+
+	shard := w.shards[shardOf(w.id(row), len(w.shards))]
+
+	select {
+	case <-w.ctx.Done():
+		return context.Cause(w.ctx)
+	case shard <- row:
+		return nil
+	}
+}
+
+// Flush waits for the workers to write every row added so far and stops them.
+// The writer must not be used after Flush.
+func (w *shardedWriter[T]) Flush() error {
+	for _, shard := range w.shards {
+		close(shard)
+	}
+	w.wg.Wait()
+
+	err := context.Cause(w.ctx)
+	w.cancel(nil)
+
+	return err
+}
+
+// Abort stops the workers without waiting for the remaining rows to be written.
+// The writer must not be used after Abort.
+func (w *shardedWriter[T]) Abort() {
+	w.cancel(context.Canceled)
+	for _, shard := range w.shards {
+		close(shard)
+	}
+	w.wg.Wait()
+}
+
+// shardOf returns the shard of the record ID out of n shards.
+func shardOf(id models.RecordID, n int) int {
+	h := fnv.New32a()
+	_, _ = fmt.Fprintf(h, "%v", id.ID)
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestShardOf(t *testing.T) {
+	a := models.NewRecordID("users", []any{"user1"})
+	b := models.NewRecordID("users", []any{"user1"})
+
+	// The same record always goes to the same shard
+	require.Equal(t, shardOf(a, 8), shardOf(b, 8))
+
+	// Records are spread across shards
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		seen[shardOf(models.NewRecordID("users", []any{fmt.Sprintf("user%d", i)}), 4)] = true
+	}
+	require.Len(t, seen, 4)
+}
+
+func TestShardedWriter_WorkerFailureCancelsWriter(t *testing.T) {
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.writeChunkSize = 1
+	const workers = 3
+
+	// Worker 0 uses the batch's connection and blocks in write until it is canceled,
+	// while the other workers fail to connect once worker 0 has started writing.
+	connectErr := errors.New("connection refused")
+	writing := make(chan struct{})
+	conns := newWriteConns(nil, func(ctx context.Context) (*surrealdb.DB, releaseFunc, error) {
+		<-writing
+		return nil, nil, connectErr
+	})
+	var canceledWrites atomic.Int32
+	w := newShardedWriter(t.Context(), srv, workers, conns,
+		recordID,
+		func(ctx context.Context, db *surrealdb.DB, rows []models.RecordID) error {
+			close(writing)
+			<-ctx.Done()
+			canceledWrites.Add(1)
+			return context.Cause(ctx)
+		},
+	)
+
+	// The first row goes to worker 0
+	id := func(i int) models.RecordID { return models.NewRecordID("users", []any{i}) }
+	first := 0
+	for shardOf(id(first), workers) != 0 {
+		first++
+	}
+	require.NoError(t, w.Add(id(first)))
+
+	// Each worker holds at most one row in flight and one buffered row,
+	// so Add must fail before all of them are full.
+	maxRows := workers*(1+srv.writeChunkSize) + 1
+	var addErr error
+	for i := 0; i < maxRows && addErr == nil; i++ {
+		addErr = w.Add(id(first + 1 + i))
+	}
+	require.ErrorIs(t, addErr, connectErr, "Add must fail within %d rows", maxRows)
+
+	// Worker 0 stops writing once the failure cancels it
+	flushed := make(chan error, 1)
+	go func() { flushed <- w.Flush() }()
+	select {
+	case err := <-flushed:
+		require.ErrorIs(t, err, connectErr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not return, the other workers were not canceled")
+	}
+	require.Equal(t, int32(1), canceledWrites.Load())
+}
+
+func TestWriteBatch_SuccessConcurrentWriters(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.writeChunkSize = 3
+	srv.writeConcurrency = 4
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	columns := []string{"_fivetran_id", "name", "age", "active"}
+
+	// Each record is replaced twice within the same file,
+	// so the last replace must win regardless of the concurrency
+	var replaceRecords [][]string
+	for round := 1; round <= 2; round++ {
+		for i := 1; i <= 20; i++ {
+			replaceRecords = append(replaceRecords, []string{fmt.Sprintf("user%d", i), fmt.Sprintf("User %d round %d", i, round), fmt.Sprint(round), "true"})
+		}
+	}
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, replaceRecords, replaceKey)
+
+	// Deletes are applied after replaces
+	var deleteRecords [][]string
+	for i := 11; i <= 20; i++ {
+		deleteRecords = append(deleteRecords, []string{fmt.Sprintf("user%d", i)})
+	}
+	deleteKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	deleteFile := testframework.CreateEncryptedCSV(t, tempDir, "delete.csv", []string{"_fivetran_id"}, deleteRecords, deleteKey)
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		DeleteFiles:   []string{deleteFile},
+		Keys:          map[string][]byte{replaceFile: replaceKey, deleteFile: deleteKey},
+		FileParams:    testframework.GetTestFileParams(),
+	})
+	require.NoError(t, err)
+	success, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+	require.True(t, success.Success)
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 10)
+	for i := 1; i <= 10; i++ {
+		testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+			map[string]interface{}{"_fivetran_id": fmt.Sprintf("user%d", i)},
+			map[string]interface{}{"name": fmt.Sprintf("User %d round 2", i), "age": uint64(2)})
+	}
+}
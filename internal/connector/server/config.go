@@ -1,6 +1,10 @@
 package server
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type AuthLevel int
 
@@ -14,6 +18,15 @@ const (
 	AuthLevelIDNamespace = "namespace"
 )
 
+// Record ID layouts selectable via the record_ids configuration.
+const (
+	// RecordIDsArray makes record IDs the array of the primary key values, like users:[42].
+	RecordIDsArray = "array"
+	// RecordIDsScalar makes the record IDs of tables with a single primary key column
+	// the primary key value, like users:42.
+	RecordIDsScalar = "scalar"
+)
+
 type config struct {
 	url       string
 	user      string
@@ -23,6 +36,27 @@ type config struct {
 
 	// either user/pass or token needs to be set
 	token string
+
+	// schemaMapping maps Fivetran schemas and tables to SurrealDB databases and tables
+	schemaMapping schemaMapping
+
+	// scalarRecordIDs makes the tables created with a single primary key column use scalar record IDs
+	scalarRecordIDs bool
+
+	// recordLinks are the columns created as links to other tables
+	recordLinks recordLinks
+
+	// relationTables are the tables created as relation tables
+	relationTables relationTables
+
+	// quarantine makes batches quarantine the rows that can't be written to rejectedTable instead of failing
+	quarantine bool
+
+	// migrationDryRun makes migrations only plan their statements instead of running them
+	migrationDryRun bool
+
+	// verifySchema and verifyTable are the table the table-consistency configuration test verifies, if any
+	verifySchema, verifyTable string
 }
 
 func (c *config) validate() error {
@@ -65,6 +99,55 @@ func (s *Server) parseConfig(configuration map[string]string) (config, error) {
 		return config{}, fmt.Errorf("unknown auth level: %s", authLevelStr)
 	}
 
+	mapping, err := parseSchemaMapping(configuration)
+	if err != nil {
+		return config{}, err
+	}
+
+	var scalarRecordIDs bool
+	switch configuration["record_ids"] {
+	case "", RecordIDsArray:
+	case RecordIDsScalar:
+		scalarRecordIDs = true
+	default:
+		return config{}, fmt.Errorf("unknown record ids: %s", configuration["record_ids"])
+	}
+
+	links, err := parseRecordLinks(configuration["record_links"])
+	if err != nil {
+		return config{}, err
+	}
+
+	relations, err := parseRelationTables(configuration["relation_tables"])
+	if err != nil {
+		return config{}, err
+	}
+
+	var quarantine bool
+	if v := configuration["quarantine_rejected_rows"]; v != "" {
+		quarantine, err = strconv.ParseBool(v)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid quarantine_rejected_rows: %s", v)
+		}
+	}
+
+	var migrationDryRun bool
+	if v := configuration["migration_dry_run"]; v != "" {
+		migrationDryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid migration_dry_run: %s", v)
+		}
+	}
+
+	var verifySchema, verifyTable string
+	if v := configuration["verify_table"]; v != "" {
+		var ok bool
+		verifySchema, verifyTable, ok = strings.Cut(v, ".")
+		if !ok || verifySchema == "" || verifyTable == "" {
+			return config{}, fmt.Errorf("invalid verify_table: %s, expected schema.table", v)
+		}
+	}
+
 	cfg := config{
 		url:       configuration["url"],
 		ns:        configuration["ns"],
@@ -72,6 +155,15 @@ func (s *Server) parseConfig(configuration map[string]string) (config, error) {
 		pass:      configuration["pass"],
 		token:     configuration["token"],
 		authLevel: authLevel,
+
+		schemaMapping:   mapping,
+		scalarRecordIDs: scalarRecordIDs,
+		recordLinks:     links,
+		relationTables:  relations,
+		quarantine:      quarantine,
+		migrationDryRun: migrationDryRun,
+		verifySchema:    verifySchema,
+		verifyTable:     verifyTable,
 	}
 
 	if err := cfg.validate(); err != nil {
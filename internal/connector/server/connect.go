@@ -3,10 +3,20 @@ package server
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
 	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/contrib/rews"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
+	"github.com/surrealdb/surrealdb.go/pkg/connection/gorillaws"
 )
 
+// reconnectInterval is how often a WebSocket connection opened by connectAndUse checks whether it dropped.
+// It is shorter than the backoff of the retried writes, so that they are retried on the reconnected connection.
+const reconnectInterval = time.Second
+
 // connect connects to SurrealDB and returns a DB instance
 //
 // It authenticates against the SurrealDB instance as a namespace-level user
@@ -23,13 +33,25 @@ func (s *Server) connect(ctx context.Context, cfg config) (*surrealdb.DB, error)
 		return nil, fmt.Errorf("failed to connect to SurrealDB: %w", err)
 	}
 
+	if err := s.authenticate(ctx, db, cfg); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// authenticate signs in using the provided username and password,
+// or authenticates using the provided token if cfg.token is set.
+//
+// It is also used to re-authenticate pooled connections whose session is about to expire.
+func (s *Server) authenticate(ctx context.Context, db *surrealdb.DB, cfg config) error {
 	token := cfg.token
 
 	if token == "" {
 		if err := s.signIn(ctx, db, cfg); err != nil {
-			return nil, fmt.Errorf("failed to sign in to SurrealDB: %w", err)
+			return fmt.Errorf("failed to sign in to SurrealDB: %w", err)
 		}
-		return db, nil
+		return nil
 	}
 
 	// If you end up panicking here like `panic: cbor: 18 bytes of extraneous data starting at index 21`,
@@ -40,12 +62,12 @@ func (s *Server) connect(ctx context.Context, cfg config) (*surrealdb.DB, error)
 	// respectively.
 	if err := db.Authenticate(ctx, token); err != nil {
 		if isTokenExpiredError(err) {
-			return nil, fmt.Errorf("%w: %v", ErrTokenExpired, err)
+			return fmt.Errorf("%w: %v", ErrTokenExpired, err)
 		}
-		return nil, fmt.Errorf("failed to authenticate with SurrealDB: %w", err)
+		return fmt.Errorf("failed to authenticate with SurrealDB: %w", err)
 	}
 
-	return db, nil
+	return nil
 }
 
 func (s *Server) signIn(ctx context.Context, db *surrealdb.DB, cfg config) error {
@@ -75,41 +97,125 @@ func (s *Server) signIn(ctx context.Context, db *surrealdb.DB, cfg config) error
 	return nil
 }
 
+// dialReconnecting connects to SurrealDB like surrealdb.FromEndpointURLString,
+// except that a WebSocket connection reconnects by itself once dropped,
+// restoring the namespace, the database, and the authentication of its session.
+func dialReconnecting(ctx context.Context, endpoint string) (*surrealdb.DB, error) {
+	u, err := url.ParseRequestURI(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return surrealdb.FromEndpointURLString(ctx, endpoint)
+	}
+
+	conf := connection.NewConfig(u)
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid connection config: %w", err)
+	}
+
+	conn := rews.New(func(context.Context) (*gorillaws.Connection, error) {
+		return gorillaws.New(conf), nil
+	}, reconnectInterval, conf.Unmarshaler, conf.Logger)
+
+	return surrealdb.FromConnection(ctx, conn)
+}
+
 // connectAndUse connects to SurrealDB and returns a DB instance
 //
 // It authenticates against the SurrealDB instance as a namespace-level user
 // with the SurrealDB namespace specified in cfg.ns (via ConfigurationForm),
-// and then switches to the specified database (schema) using USE.
-func (s *Server) connectAndUse(ctx context.Context, cfg config, schema string) (*surrealdb.DB, error) {
-	db, err := s.connect(ctx, cfg)
+// and then switches to the specified database using USE.
+//
+// Unlike connect, the connection reconnects by itself when the WebSocket drops,
+// so that the writes failed by the drop can be retried on the same connection.
+// It is the one the connections used by RPCs are opened with. See acquireDB.
+func (s *Server) connectAndUse(ctx context.Context, cfg config, database string) (_ *surrealdb.DB, err error) {
+	db, err := dialReconnecting(ctx, cfg.url)
 	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SurrealDB: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			// Stop reconnecting the connection nobody uses
+			_ = db.Close(ctx)
+		}
+	}()
+
+	if err := s.authenticate(ctx, db, cfg); err != nil {
 		return nil, err
 	}
 
-	// Note that we assume Fivetran `schema` is the same as SurrealDB `database`.
-	// So we treat SurrealDB `namespace` as a global setting, that limits every operation from this
+	// We treat SurrealDB `namespace` as a global setting, that limits every operation from this
 	// connector to SurrealDB within the namespace.
 	//
 	// If you read this connector's implementation,
 	// you'll notice Fivetran calls our RPCs like `hey, create a table named <schema>.<table>`,
-	// and we interpret it as `ok let's create a table <table> in database <schema>`.
-	if err := db.Use(ctx, cfg.ns, schema); err != nil {
+	// and by default we interpret it as `ok let's create a table <table> in database <schema>`.
+	// The database is decided by cfg.schemaMapping, though. See acquireDB.
+	if err := db.Use(ctx, cfg.ns, database); err != nil {
 		return nil, fmt.Errorf("failed to use namespace %s: %w", cfg.ns, err)
 	}
 
 	return db, nil
 }
 
-func (s *Server) parseConfigAndConnect(ctx context.Context, configuration map[string]string, schema string) (*surrealdb.DB, error) {
-	cfg, err := s.parseConfig(configuration)
-	if err != nil {
-		return nil, err
+// acquireDB returns a connection that is signed in and uses the database the Fivetran schema is mapped to,
+// and the function to release it.
+//
+// The connection is taken from the connection pool, so that RPCs for the same
+// configuration and database reuse connections instead of dialing and signing in again.
+// The caller must call release once done with the connection, instead of closing it,
+// passing the error it got while using the connection, if any.
+func (s *Server) acquireDB(ctx context.Context, cfg config, schema string) (*surrealdb.DB, releaseFunc, error) {
+	database := cfg.schemaMapping.databaseFor(schema)
+
+	if s.pool == nil {
+		db, err := s.connectAndUse(ctx, cfg, database)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func(error) {
+			if err := db.Close(ctx); err != nil {
+				s.LogWarning("failed to close db", err)
+			}
+		}, nil
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, schema)
-	if err != nil {
-		return nil, err
+	return s.pool.acquire(ctx, cfg, database)
+}
+
+// tryAcquireDB is like acquireDB, but returns errConnPoolFull instead of waiting
+// for a connection to be released when the pool is full.
+func (s *Server) tryAcquireDB(ctx context.Context, cfg config, schema string) (*surrealdb.DB, releaseFunc, error) {
+	if s.pool == nil {
+		return s.acquireDB(ctx, cfg, schema)
 	}
 
-	return db, nil
+	return s.pool.tryAcquire(ctx, cfg, cfg.schemaMapping.databaseFor(schema))
+}
+
+// newServerConnPool returns a connection pool that connects the same way as connectAndUse.
+func (s *Server) newServerConnPool(opts connPoolOptions) *connPool {
+	p := newConnPool(s.Logging, opts)
+	p.dial = s.connectAndUse
+	p.reauth = func(ctx context.Context, db *surrealdb.DB, cfg config, database string) error {
+		if err := s.authenticate(ctx, db, cfg); err != nil {
+			return err
+		}
+		if err := db.Use(ctx, cfg.ns, database); err != nil {
+			return fmt.Errorf("failed to use namespace %s: %w", cfg.ns, err)
+		}
+		return nil
+	}
+	p.ping = func(ctx context.Context, db *surrealdb.DB) error {
+		// INFO FOR DB requires the session to be authenticated,
+		// so this fails when the session has expired.
+		_, err := tracing.Query[any](ctx, db, "INFO FOR DB", nil)
+		return err
+	}
+	p.close = func(ctx context.Context, db *surrealdb.DB) error {
+		return db.Close(ctx)
+	}
+	return p
 }
@@ -0,0 +1,485 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/log"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// poolKey identifies the connections that can be shared,
+// which are the ones signed in with the same credentials and using the same database.
+type poolKey struct {
+	url       string
+	user      string
+	pass      string
+	token     string
+	ns        string
+	authLevel AuthLevel
+	database  string
+}
+
+func newPoolKey(cfg config, database string) poolKey {
+	return poolKey{
+		url:       cfg.url,
+		user:      cfg.user,
+		pass:      cfg.pass,
+		token:     cfg.token,
+		ns:        cfg.ns,
+		authLevel: cfg.authLevel,
+		database:  database,
+	}
+}
+
+// pooledConn is a connection owned by the pool.
+type pooledConn struct {
+	db  *surrealdb.DB
+	cfg config
+	key poolKey
+
+	// authenticatedAt is when the connection signed in or authenticated last time
+	authenticatedAt time.Time
+	// lastUsed is when the connection was released to the pool last time
+	lastUsed time.Time
+}
+
+// connPoolOptions configures connPool.
+type connPoolOptions struct {
+	// maxConnections is the maximum number of open connections, both idle and in use, across all keys.
+	// Zero means unlimited.
+	maxConnections int
+	// maxIdleTime is how long an idle connection is kept before being closed
+	maxIdleTime time.Duration
+	// healthCheckAfter is how long a connection can stay idle before being health-checked on reuse
+	healthCheckAfter time.Duration
+	// reauthInterval is how often a connection re-authenticates, to avoid the session expiring while in use
+	reauthInterval time.Duration
+	// acquireTimeout is how long acquire waits for a connection when maxConnections is reached.
+	// Zero means acquire waits until the context is done.
+	acquireTimeout time.Duration
+}
+
+func defaultConnPoolOptions() connPoolOptions {
+	return connPoolOptions{
+		maxConnections:   32,
+		maxIdleTime:      5 * time.Minute,
+		healthCheckAfter: 30 * time.Second,
+		reauthInterval:   30 * time.Minute,
+		acquireTimeout:   time.Minute,
+	}
+}
+
+// connPoolOptionsFromEnv returns the default options overridden by environment variables.
+func connPoolOptionsFromEnv(logging *log.Logging) connPoolOptions {
+	opts := defaultConnPoolOptions()
+
+	if v := os.Getenv("SURREAL_FIVETRAN_POOL_MAX_CONNECTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			opts.maxConnections = n
+		} else {
+			logging.LogWarning("Invalid pool max connections, falling back to the default", err, "value", v, "default", opts.maxConnections)
+		}
+	}
+
+	for _, d := range []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"SURREAL_FIVETRAN_POOL_MAX_IDLE_TIME", &opts.maxIdleTime},
+		{"SURREAL_FIVETRAN_POOL_HEALTH_CHECK_AFTER", &opts.healthCheckAfter},
+		{"SURREAL_FIVETRAN_POOL_REAUTH_INTERVAL", &opts.reauthInterval},
+		{"SURREAL_FIVETRAN_POOL_ACQUIRE_TIMEOUT", &opts.acquireTimeout},
+	} {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			logging.LogWarning("Invalid pool duration, falling back to the default", err, "env", d.env, "value", v, "default", *d.value)
+			continue
+		}
+		*d.value = parsed
+	}
+
+	return opts
+}
+
+// releaseFunc returns an acquired connection to the pool.
+//
+// err is the error the caller got while using the connection, if any.
+// The connection is closed instead of being reused when err indicates
+// the connection itself may be broken. See isConnError.
+type releaseFunc func(err error)
+
+// connPool reuses SurrealDB connections across RPCs,
+// so that a sync does not dial and sign in again for every RPC.
+type connPool struct {
+	*log.Logging
+	opts connPoolOptions
+
+	// dial opens a connection, signs in, and uses the database
+	dial func(ctx context.Context, cfg config, database string) (*surrealdb.DB, error)
+	// reauth signs in or authenticates the connection again, and uses the database
+	reauth func(ctx context.Context, db *surrealdb.DB, cfg config, database string) error
+	// ping checks the connection is alive and authenticated
+	ping func(ctx context.Context, db *surrealdb.DB) error
+	// close closes the connection
+	close func(ctx context.Context, db *surrealdb.DB) error
+
+	mu   sync.Mutex
+	idle map[poolKey][]*pooledConn
+	// open is the number of open connections, both idle and in use
+	open int
+	// released is closed and replaced every time a connection is released or closed,
+	// to wake up the acquirers waiting for a connection
+	released chan struct{}
+	closed   bool
+}
+
+func newConnPool(logging *log.Logging, opts connPoolOptions) *connPool {
+	return &connPool{
+		Logging:  logging,
+		opts:     opts,
+		idle:     make(map[poolKey][]*pooledConn),
+		released: make(chan struct{}),
+	}
+}
+
+// ErrConnPoolClosed is returned when acquiring a connection from a closed pool.
+var ErrConnPoolClosed = errors.New("connection pool is closed")
+
+// errConnPoolFull is returned by tryAcquire when no connection is available without waiting.
+var errConnPoolFull = errors.New("connection pool is full")
+
+// acquire returns a connection for the config and the database, and the function to release it.
+//
+// An idle connection for the same key is reused when available.
+// Connections that have been idle for a while are health-checked,
+// and those that have been authenticated long ago are re-authenticated before reuse.
+// When the pool is full, an idle connection for another key is closed to make room,
+// or acquire waits for a connection to be released.
+//
+// The caller must call release exactly once when done with the connection.
+// The connection is returned to the pool, and checked again on the next acquire.
+func (p *connPool) acquire(ctx context.Context, cfg config, database string) (*surrealdb.DB, releaseFunc, error) {
+	if p.opts.acquireTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.acquireTimeout)
+		defer cancel()
+	}
+
+	return p.get(ctx, cfg, database, true)
+}
+
+// tryAcquire is like acquire, but returns errConnPoolFull instead of waiting
+// when the pool is full and no idle connection can be reused or closed to make room.
+//
+// It is used for optional connections, like the ones for additional write workers,
+// so that an RPC already holding a connection never waits on the pool for more,
+// which could exhaust the pool when RPCs run concurrently.
+func (p *connPool) tryAcquire(ctx context.Context, cfg config, database string) (*surrealdb.DB, releaseFunc, error) {
+	return p.get(ctx, cfg, database, false)
+}
+
+func (p *connPool) get(ctx context.Context, cfg config, database string, block bool) (*surrealdb.DB, releaseFunc, error) {
+	key := newPoolKey(cfg, database)
+
+	for {
+		c, dial, wait, err := p.take(ctx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if c != nil {
+			if err := p.prepare(ctx, c); err != nil {
+				p.discard(c)
+				if errors.Is(err, ErrTokenExpired) {
+					return nil, nil, err
+				}
+				// Try another idle connection, or dial a new one
+				continue
+			}
+			return c.db, p.releaseFunc(c), nil
+		}
+
+		if dial {
+			db, err := p.dial(ctx, cfg, database)
+			if err != nil {
+				p.forget()
+				return nil, nil, err
+			}
+			now := time.Now()
+			c := &pooledConn{db: db, cfg: cfg, key: key, authenticatedAt: now, lastUsed: now}
+			return c.db, p.releaseFunc(c), nil
+		}
+
+		if !block {
+			return nil, nil, errConnPoolFull
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("timed out waiting for a connection from the pool of %d connections: %w", p.opts.maxConnections, ctx.Err())
+		case <-wait:
+		}
+	}
+}
+
+// take pops an idle connection for the key if any.
+// Otherwise, it reserves a slot for a new connection and returns dial = true,
+// or returns a channel to wait on when the pool is full.
+func (p *connPool) take(ctx context.Context, key poolKey) (c *pooledConn, dial bool, wait <-chan struct{}, err error) {
+	var evicted *pooledConn
+
+	p.mu.Lock()
+	defer func() {
+		p.mu.Unlock()
+		if evicted != nil {
+			p.closeConn(ctx, evicted)
+		}
+	}()
+
+	if p.closed {
+		return nil, false, nil, ErrConnPoolClosed
+	}
+
+	if conns := p.idle[key]; len(conns) > 0 {
+		// Reuse the most recently used connection, so that the others become idle long enough to be evicted
+		c = conns[len(conns)-1]
+		p.idle[key] = conns[:len(conns)-1]
+		if len(p.idle[key]) == 0 {
+			delete(p.idle, key)
+		}
+		return c, false, nil, nil
+	}
+
+	if p.opts.maxConnections <= 0 || p.open < p.opts.maxConnections {
+		p.open++
+		return nil, true, nil, nil
+	}
+
+	// Make room by closing the least recently used idle connection for another key.
+	// The slot is handed over to the new connection, so p.open stays the same.
+	evicted = p.popLeastRecentlyUsedLocked()
+	if evicted != nil {
+		return nil, true, nil, nil
+	}
+
+	return nil, false, p.released, nil
+}
+
+func (p *connPool) popLeastRecentlyUsedLocked() *pooledConn {
+	var lruKey poolKey
+	var lru *pooledConn
+	for key, conns := range p.idle {
+		// Idle connections are ordered by lastUsed, so the first one is the least recently used
+		if len(conns) > 0 && (lru == nil || conns[0].lastUsed.Before(lru.lastUsed)) {
+			lruKey, lru = key, conns[0]
+		}
+	}
+	if lru == nil {
+		return nil
+	}
+
+	p.idle[lruKey] = p.idle[lruKey][1:]
+	if len(p.idle[lruKey]) == 0 {
+		delete(p.idle, lruKey)
+	}
+	return lru
+}
+
+// prepare health-checks and re-authenticates the idle connection as needed before reuse.
+func (p *connPool) prepare(ctx context.Context, c *pooledConn) error {
+	now := time.Now()
+
+	needsReauth := p.opts.reauthInterval > 0 && now.Sub(c.authenticatedAt) >= p.opts.reauthInterval
+
+	if !needsReauth && now.Sub(c.lastUsed) >= p.opts.healthCheckAfter {
+		if err := p.ping(ctx, c.db); err != nil {
+			if !isSessionExpiredError(err) {
+				p.LogWarning("Discarding unhealthy pooled connection", err, "url", c.key.url, "database", c.key.database)
+				return err
+			}
+			needsReauth = true
+		}
+	}
+
+	if needsReauth {
+		if err := p.reauth(ctx, c.db, c.cfg, c.key.database); err != nil {
+			p.LogWarning("Failed to re-authenticate pooled connection", err, "url", c.key.url, "database", c.key.database)
+			return err
+		}
+		c.authenticatedAt = time.Now()
+
+		if p.Debugging() {
+			p.LogDebug("Re-authenticated pooled connection", "url", c.key.url, "database", c.key.database)
+		}
+	}
+
+	return nil
+}
+
+func (p *connPool) releaseFunc(c *pooledConn) releaseFunc {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			p.release(c, err)
+		})
+	}
+}
+
+// release returns the connection to the pool,
+// or closes it if err indicates the connection may be broken.
+func (p *connPool) release(c *pooledConn, err error) {
+	if isSessionExpiredError(err) {
+		// The connection is fine, but needs to re-authenticate before the next use
+		c.authenticatedAt = time.Time{}
+	} else if isConnError(err) {
+		p.LogWarning("Discarding pooled connection after a connection error", err, "url", c.key.url, "database", c.key.database)
+		p.discard(c)
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.discard(c)
+		return
+	}
+	c.lastUsed = time.Now()
+	p.idle[c.key] = append(p.idle[c.key], c)
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// discard closes the connection and frees its slot.
+func (p *connPool) discard(c *pooledConn) {
+	p.closeConn(context.Background(), c)
+	p.forget()
+}
+
+// forget frees the slot of a connection that is closed or failed to open.
+func (p *connPool) forget() {
+	p.mu.Lock()
+	p.open--
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+func (p *connPool) notifyLocked() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+func (p *connPool) closeConn(ctx context.Context, c *pooledConn) {
+	if err := p.close(ctx, c.db); err != nil {
+		p.LogWarning("failed to close pooled connection", err, "url", c.key.url, "database", c.key.database)
+	}
+}
+
+// evictIdle closes the connections that have been idle longer than maxIdleTime.
+func (p *connPool) evictIdle(ctx context.Context) {
+	var evicted []*pooledConn
+
+	p.mu.Lock()
+	cutoff := time.Now().Add(-p.opts.maxIdleTime)
+	for key, conns := range p.idle {
+		// Idle connections are ordered by lastUsed
+		n := 0
+		for n < len(conns) && conns[n].lastUsed.Before(cutoff) {
+			n++
+		}
+		evicted = append(evicted, conns[:n]...)
+		if n == len(conns) {
+			delete(p.idle, key)
+		} else {
+			p.idle[key] = conns[n:]
+		}
+	}
+	p.open -= len(evicted)
+	if len(evicted) > 0 {
+		p.notifyLocked()
+	}
+	p.mu.Unlock()
+
+	for _, c := range evicted {
+		p.closeConn(ctx, c)
+	}
+
+	if len(evicted) > 0 && p.Debugging() {
+		p.LogDebug("Evicted idle connections", "count", len(evicted))
+	}
+}
+
+// Start evicts idle connections periodically until ctx is done.
+func (p *connPool) Start(ctx context.Context) {
+	interval := p.opts.maxIdleTime / 2
+	if interval <= 0 || interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.evictIdle(ctx)
+			}
+		}
+	}()
+}
+
+// Close closes the idle connections, and makes the pool close the connections in use once released.
+func (p *connPool) Close(ctx context.Context) {
+	p.mu.Lock()
+	p.closed = true
+	var conns []*pooledConn
+	for _, idle := range p.idle {
+		conns = append(conns, idle...)
+	}
+	p.idle = make(map[poolKey][]*pooledConn)
+	p.open -= len(conns)
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		p.closeConn(ctx, c)
+	}
+}
+
+// isConnError reports whether err may have left the connection unusable.
+//
+// Errors SurrealDB returned for the query or the RPC, and the ones we return
+// after reading a successful response like ErrTableNotFound, mean the connection itself is fine.
+// Any other error, like a closed socket or a timed-out request, may leave the connection
+// broken or with a response still in flight, so it is safer not to reuse the connection.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, &surrealdb.QueryError{}) &&
+		!errors.Is(err, &surrealdb.RPCError{}) &&
+		!errors.Is(err, ErrTableNotFound)
+}
+
+// isSessionExpiredError checks if an error message indicates the session or the token has expired,
+// in which case the connection can be re-authenticated.
+func isSessionExpiredError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return isTokenExpiredError(err) || strings.Contains(msg, "The session has expired")
+}
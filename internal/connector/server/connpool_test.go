@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/log"
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
+)
+
+// fakeConnPool is a connPool whose connections are fake, for testing the pool without SurrealDB.
+type fakeConnPool struct {
+	*connPool
+
+	mu      sync.Mutex
+	dialed  int
+	closed  int
+	reauths int
+	pingErr error
+}
+
+func newFakeConnPool(t *testing.T, opts connPoolOptions) *fakeConnPool {
+	f := &fakeConnPool{}
+	f.connPool = newConnPool(&log.Logging{Logger: testframework.GetTestLogger()}, opts)
+	f.dial = func(ctx context.Context, cfg config, database string) (*surrealdb.DB, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.dialed++
+		return &surrealdb.DB{}, nil
+	}
+	f.reauth = func(ctx context.Context, db *surrealdb.DB, cfg config, database string) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.reauths++
+		return nil
+	}
+	f.ping = func(ctx context.Context, db *surrealdb.DB) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.pingErr
+	}
+	f.close = func(ctx context.Context, db *surrealdb.DB) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.closed++
+		return nil
+	}
+	return f
+}
+
+func testPoolConfig(user string) config {
+	return config{url: "ws://localhost:8000", user: user, pass: "pass", ns: "test", authLevel: AuthLevelRoot}
+}
+
+func TestConnPool_ReusesConnections(t *testing.T) {
+	p := newFakeConnPool(t, defaultConnPoolOptions())
+	cfg := testPoolConfig("root")
+
+	db1, release1, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release1(nil)
+
+	db2, release2, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	require.Same(t, db1, db2, "the released connection should be reused")
+
+	// Another database needs another connection
+	db3, release3, err := p.acquire(t.Context(), cfg, "other")
+	require.NoError(t, err)
+	require.NotSame(t, db1, db3)
+
+	release2(nil)
+	release3(nil)
+
+	require.Equal(t, 2, p.dialed)
+	require.Equal(t, 0, p.closed)
+}
+
+func TestConnPool_MaxConnections(t *testing.T) {
+	opts := defaultConnPoolOptions()
+	opts.maxConnections = 1
+	opts.acquireTimeout = 50 * time.Millisecond
+	p := newFakeConnPool(t, opts)
+	cfg := testPoolConfig("root")
+
+	_, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+
+	// The pool is full, and the connection is in use
+	_, _, err = p.acquire(t.Context(), cfg, "db")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A waiting acquirer gets the connection once released
+	done := make(chan error)
+	go func() {
+		_, release, err := p.acquire(context.Background(), cfg, "db")
+		if err == nil {
+			release(nil)
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release(nil)
+	require.NoError(t, <-done)
+
+	// An idle connection for another key is closed to make room
+	_, release, err = p.acquire(t.Context(), cfg, "other")
+	require.NoError(t, err)
+	release(nil)
+
+	require.Equal(t, 2, p.dialed)
+	require.Equal(t, 1, p.closed)
+}
+
+func TestConnPool_HealthCheck(t *testing.T) {
+	opts := defaultConnPoolOptions()
+	opts.healthCheckAfter = 0
+	p := newFakeConnPool(t, opts)
+	cfg := testPoolConfig("root")
+
+	_, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+
+	// An unhealthy connection is discarded and replaced
+	p.pingErr = errors.New("connection reset by peer")
+	_, release, err = p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+	require.Equal(t, 2, p.dialed)
+	require.Equal(t, 1, p.closed)
+
+	// A connection whose session has expired is re-authenticated
+	p.pingErr = errors.New("There was a problem with authentication: The session has expired")
+	_, release, err = p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+	require.Equal(t, 2, p.dialed)
+	require.Equal(t, 1, p.reauths)
+}
+
+func TestConnPool_ReauthInterval(t *testing.T) {
+	opts := defaultConnPoolOptions()
+	opts.reauthInterval = time.Nanosecond
+	p := newFakeConnPool(t, opts)
+	cfg := testPoolConfig("root")
+
+	_, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+
+	_, release, err = p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+
+	require.Equal(t, 1, p.dialed)
+	require.Equal(t, 1, p.reauths)
+}
+
+func TestConnPool_EvictIdle(t *testing.T) {
+	opts := defaultConnPoolOptions()
+	opts.maxIdleTime = 0
+	p := newFakeConnPool(t, opts)
+	cfg := testPoolConfig("root")
+
+	_, release1, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	_, release2, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release1(nil)
+
+	p.evictIdle(t.Context())
+	require.Equal(t, 1, p.closed)
+	require.Equal(t, 1, p.open)
+
+	// Connections in use are closed once released after the pool is closed
+	p.Close(t.Context())
+	release2(nil)
+	require.Equal(t, 2, p.closed)
+	require.Equal(t, 0, p.open)
+
+	_, _, err = p.acquire(t.Context(), cfg, "db")
+	require.ErrorIs(t, err, ErrConnPoolClosed)
+}
+
+func TestConnPool_ReleaseWithError(t *testing.T) {
+	p := newFakeConnPool(t, defaultConnPoolOptions())
+	cfg := testPoolConfig("root")
+
+	// A query error means the connection is fine, so it is reused
+	db1, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(fmt.Errorf("unable to write records: %w", &surrealdb.QueryError{Message: "Found NONE for field `name`"}))
+
+	db2, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	require.Same(t, db1, db2)
+
+	// A connection error may leave the connection broken, so it is closed
+	release(fmt.Errorf("unable to write records: %w", net.ErrClosed))
+	require.Equal(t, 1, p.closed)
+	require.Equal(t, 0, p.open)
+
+	db3, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	require.NotSame(t, db1, db3)
+
+	// An expired session is re-authenticated before the next use
+	release(errors.New("There was a problem with authentication: The session has expired"))
+	_, release, err = p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+
+	require.Equal(t, 2, p.dialed)
+	require.Equal(t, 1, p.reauths)
+}
+
+func TestConnPool_TryAcquire(t *testing.T) {
+	opts := defaultConnPoolOptions()
+	opts.maxConnections = 1
+	// Zero disables the acquire timeout rather than failing every acquire
+	opts.acquireTimeout = 0
+	p := newFakeConnPool(t, opts)
+	cfg := testPoolConfig("root")
+
+	_, release, err := p.acquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+
+	// tryAcquire never waits for the connection in use
+	_, _, err = p.tryAcquire(t.Context(), cfg, "db")
+	require.ErrorIs(t, err, errConnPoolFull)
+
+	release(nil)
+
+	_, release, err = p.tryAcquire(t.Context(), cfg, "db")
+	require.NoError(t, err)
+	release(nil)
+
+	require.Equal(t, 1, p.dialed)
+}
@@ -1,103 +1,265 @@
 package server
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
 	"time"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/ftio"
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (s *Server) processCSVRecords(files []string, fileParams *pb.FileParams, keys map[string][]byte, process func(columns []string, record []string) error) error {
+// batchFileReader reads rows from a decrypted and decompressed batch file.
+type batchFileReader interface {
+	// Columns returns the column names in the order Read returns the values of each row.
+	Columns() []string
+	// Read returns the values of the next row, or io.EOF once every row has been read.
+	//
+	// Values are strings for CSV batch files.
+	// For Parquet batch files, values are typed according to the Parquet schema,
+	// and nulls are nil. See ftio.ParquetValue for details.
+	Read() ([]any, error)
+	Close() error
+}
+
+// processBatchFiles reads the batch files in order and calls process for each row.
+//
+// The format of the files is the one we advertised to Fivetran via Capabilities.
+// The metrics are labeled with the labels carried by ctx.
+//
+// When ctx carries a quarantine, the rows process fails with an invalidRow error are quarantined,
+// and the files are processed without them.
+func (s *Server) processBatchFiles(ctx context.Context, files []string, fileParams *pb.FileParams, keys map[string][]byte, process func(columns []string, record []any) error) error {
+	labels := metrics.LabelsFromContext(ctx)
+
 	// Track file processing timing
 	if s.metrics != nil {
 		s.metrics.FileProcessingStarted()
 		defer func(start time.Time) {
-			s.metrics.FileProcessingCompleted(time.Since(start))
+			s.metrics.FileProcessingCompleted(labels, time.Since(start))
 		}(time.Now())
 	}
 
 	for _, f := range files {
-		r, err := s.openFivetranFile(f, fileParams, keys)
-		if err != nil {
-			return fmt.Errorf("failed to open fivetran file: %w", err)
+		if err := s.processBatchFile(ctx, f, fileParams, keys, process); err != nil {
+			return err
 		}
-		defer func() {
-			if err := r.Close(); err != nil {
-				s.LogWarning("failed to close fivetran file", err)
-			}
-		}()
+	}
+	return nil
+}
 
-		cr := csv.NewReader(r)
+// processBatchFile reads the batch file and calls process for each row, in a span of the file
+// tagged with the number of rows, and the time spent reading them and processing them.
+//
+// Reading covers decrypting, decompressing and parsing the file,
+// while processing covers converting the values and writing the rows, whose queries have their own spans.
+func (s *Server) processBatchFile(ctx context.Context, f string, fileParams *pb.FileParams, keys map[string][]byte, process func(columns []string, record []any) error) (err error) {
+	labels := metrics.LabelsFromContext(ctx)
+	q := quarantineFrom(ctx)
 
-		// TODO: ReuseRecord to avoid allocating a new slice for each record?
+	_, span := tracing.Tracer().Start(ctx, "batch file", trace.WithAttributes(
+		attribute.String("fivetran.file", filepath.Base(f)),
+		attribute.String("fivetran.file_format", s.batchFileFormat.String()),
+	))
+	var recordCount int64
+	var readTime, processTime time.Duration
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("fivetran.rows", recordCount),
+			attribute.Int64("fivetran.read_ms", readTime.Milliseconds()),
+			attribute.Int64("fivetran.process_ms", processTime.Milliseconds()),
+		)
+		tracing.End(span, err)
+	}()
 
-		columns, err := cr.Read()
-		if err != nil {
-			return fmt.Errorf("failed to read csv columns: %w", err)
+	start := time.Now()
+	r, err := s.openBatchFile(f, fileParams, keys)
+	readTime += time.Since(start)
+	if err != nil {
+		return fmt.Errorf("failed to open fivetran file: %w", err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			s.LogWarning("failed to close fivetran file", err)
 		}
+	}()
+
+	columns := r.Columns()
 
-		// Track file processing
-		if s.metrics != nil {
-			s.metrics.FileProcessed()
+	// Track file processing
+	if s.metrics != nil {
+		s.metrics.FileProcessed(labels)
+	}
+
+	var bytesProcessed int64
+
+	for {
+		start := time.Now()
+		record, err := r.Read()
+		readTime += time.Since(start)
+		if err != nil && err != io.EOF {
+			if s.metrics != nil {
+				s.metrics.FileProcessingError(labels)
+			}
+			return fmt.Errorf("failed to read batch file record: %w", err)
+		}
+		if err == io.EOF {
+			break
+		}
+
+		// Calculate approximate bytes for this record
+		var recordBytes int64
+		for _, field := range record {
+			recordBytes += approximateValueSize(field) + 1 // +1 for delimiter
 		}
 
-		var recordCount int64
-		var bytesProcessed int64
+		if q != nil {
+			q.reading = &batchRow{file: f, row: recordCount + 1, columns: columns, values: record}
+		}
 
-		for {
-			record, err := cr.Read()
-			if err != nil && err != io.EOF {
-				if s.metrics != nil {
-					s.metrics.FileProcessingError()
+		start = time.Now()
+		err = process(columns, record)
+		processTime += time.Since(start)
+		if err != nil {
+			var invalid *invalidRowError
+			if q != nil && errors.As(err, &invalid) {
+				if err := q.reject(ctx, q.reading, err); err != nil {
+					return err
 				}
-				return fmt.Errorf("failed to read csv record: %w", err)
-			}
-			if err == io.EOF {
-				break
+				recordCount++
+				continue
 			}
 
-			// Calculate approximate bytes for this record
-			var recordBytes int64
-			for _, field := range record {
-				recordBytes += int64(len(field)) + 1 // +1 for delimiter
+			if s.metrics != nil {
+				s.metrics.FileProcessingError(labels)
 			}
+			return fmt.Errorf("failed to process row %d of batch file %s: %w", recordCount+1, f, err)
+		}
 
-			if err := process(columns, record); err != nil {
-				if s.metrics != nil {
-					s.metrics.FileProcessingError()
-				}
-				return fmt.Errorf("failed to process csv record: %w", err)
-			}
+		recordCount++
+		bytesProcessed += recordBytes
+	}
 
-			recordCount++
-			bytesProcessed += recordBytes
+	// Update metrics after processing the file
+	if s.metrics != nil && recordCount > 0 {
+		s.metrics.RecordProcessed(labels, recordCount, bytesProcessed)
+	}
+	return nil
+}
+
+// approximateValueSize returns the approximate size of a value read from a batch file.
+func approximateValueSize(v any) int64 {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(t))
+	case []byte:
+		return int64(len(t))
+	case bool:
+		return 1
+	default:
+		// Numbers, datetimes, and durations are stored in 8 bytes or so in Parquet
+		return 8
+	}
+}
+
+// openBatchFile opens the batch file and returns a reader for its rows,
+// depending on the batch file format the server is configured with.
+//
+// It's the caller's responsibility to close the returned reader.
+func (s *Server) openBatchFile(file string, fileParams *pb.FileParams, keys map[string][]byte) (batchFileReader, error) {
+	r, err := s.openFivetranFile(file, fileParams, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.batchFileFormat {
+	case pb.BatchFileFormat_PARQUET:
+		pr, err := ftio.NewParquetRowReader(r)
+		// The Parquet content is spooled to a temporary file at this point,
+		// so we no longer need the decrypting and decompressing reader.
+		if closeErr := r.Close(); closeErr != nil {
+			s.LogWarning("failed to close fivetran file", closeErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read parquet file: %w", err)
 		}
+		return pr, nil
+	default:
+		cr := csv.NewReader(r)
 
-		// Update metrics after processing the file
-		if s.metrics != nil && recordCount > 0 {
-			s.metrics.RecordProcessed(recordCount, bytesProcessed)
+		// TODO: ReuseRecord to avoid allocating a new slice for each record?
+
+		columns, err := cr.Read()
+		if err != nil {
+			if closeErr := r.Close(); closeErr != nil {
+				s.LogWarning("failed to close fivetran file", closeErr)
+			}
+			return nil, fmt.Errorf("failed to read csv columns: %w", err)
 		}
+
+		return &csvFileReader{in: r, csv: cr, columns: columns}, nil
 	}
-	return nil
+}
+
+// csvFileReader reads rows from a CSV batch file.
+type csvFileReader struct {
+	in      io.ReadCloser
+	csv     *csv.Reader
+	columns []string
+}
+
+var _ batchFileReader = &csvFileReader{}
+
+func (c *csvFileReader) Columns() []string {
+	return c.columns
+}
+
+func (c *csvFileReader) Read() ([]any, error) {
+	record, err := c.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(record))
+	for i, v := range record {
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (c *csvFileReader) Close() error {
+	return c.in.Close()
+}
+
+// isNullValue returns true if v represents a null value,
+// which is the null string in CSV batch files, and nil in Parquet batch files.
+func isNullValue(v any, fileParams *pb.FileParams) bool {
+	return v == nil || v == fileParams.NullString
 }
 
 // Returns a decrypted and decompressed stream of the file content.
-// The original file is compressed using zstd, and then encrypted.
-// The encryption algorithm is specified in fileParams.Encryption.
-// The key is specified in keys.
+// The encryption algorithm is specified in fileParams.Encryption,
+// and the compression algorithm is specified in fileParams.Compression.
+// The key is specified in keys, and is required only when the file is encrypted.
 // In case of the CBC mode of AES, iv is prepended to the ciphertext within the file.
 //
 // It's the caller's responsibility to close the returned reader.
 func (s *Server) openFivetranFile(file string, fileParams *pb.FileParams, keys map[string][]byte) (io.ReadCloser, error) {
 	key, ok := keys[file]
-	if !ok {
+	if !ok && fileParams.Encryption != pb.Encryption_NONE {
 		return nil, fmt.Errorf("key not found for file: %s", file)
 	}
 
-	r, err := ftio.NewFivetranFileReader(file, key)
+	r, err := ftio.NewFileReader(file, fileParams.Encryption, fileParams.Compression, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fivetran file reader: %w", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
@@ -400,3 +401,83 @@ func TestDescribeTable_SuccessEmptyTableWithFields(t *testing.T) {
 	assert.Equal(t, table.Name, tableResp.Table.Name)
 	assert.Len(t, tableResp.Table.Columns, len(table.Columns))
 }
+
+func TestDescribeTable_SuccessTableNotCreatedByConnector(t *testing.T) {
+	srv, config, schema, cleanup := setupDescribeTableTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := testframework.ConnectAndUse(ctx, config["url"], "test", schema, config["user"], config["pass"])
+	require.NoError(t, err)
+	defer func() {
+		if err := db.Close(ctx); err != nil {
+			t.Logf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	// A table defined by hand, without the column metadata the connector stores in comments
+	_, err = surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE handmade SCHEMAFULL;
+		DEFINE FIELD id ON handmade TYPE int;
+		DEFINE FIELD name ON handmade TYPE string ASSERT string::len($value) > 0;
+		DEFINE FIELD price ON handmade TYPE option<decimal>;
+		DEFINE FIELD tags ON handmade TYPE option<array<string>>;
+		DEFINE FIELD created_at ON handmade TYPE datetime;
+	`, nil)
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, "handmade")
+
+	describeResp, err := srv.DescribeTable(ctx, &pb.DescribeTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		TableName:     "handmade",
+	})
+	require.NoError(t, err)
+	tableResp, ok := describeResp.Response.(*pb.DescribeTableResponse_Table)
+	require.True(t, ok, "Expected DescribeTable table response")
+
+	assertTableEquals(t, &pb.Table{
+		Name: "handmade",
+		Columns: []*pb.Column{
+			{Name: "id", Type: pb.DataType_LONG, PrimaryKey: true},
+			{Name: "created_at", Type: pb.DataType_UTC_DATETIME},
+			{Name: "name", Type: pb.DataType_STRING},
+			{Name: "price", Type: pb.DataType_DECIMAL, Params: &pb.DataTypeParams{
+				Params: &pb.DataTypeParams_Decimal{Decimal: &pb.DecimalParams{Precision: 28, Scale: 10}},
+			}},
+			{Name: "tags", Type: pb.DataType_JSON},
+		},
+	}, tableResp.Table)
+}
+
+func TestDescribeTable_FailureUnmappableFields(t *testing.T) {
+	srv, config, schema, cleanup := setupDescribeTableTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := testframework.ConnectAndUse(ctx, config["url"], "test", schema, config["user"], config["pass"])
+	require.NoError(t, err)
+	defer func() {
+		if err := db.Close(ctx); err != nil {
+			t.Logf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	_, err = surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE handmade_geo SCHEMAFULL;
+		DEFINE FIELD name ON handmade_geo TYPE string;
+		DEFINE FIELD location ON handmade_geo TYPE geometry<point>;
+	`, nil)
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, "handmade_geo")
+
+	describeResp, err := srv.DescribeTable(ctx, &pb.DescribeTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		TableName:     "handmade_geo",
+	})
+	require.Error(t, err)
+	warning, ok := describeResp.Response.(*pb.DescribeTableResponse_Warning)
+	require.True(t, ok, "Expected DescribeTable warning response")
+	assert.Contains(t, warning.Warning.Message, "location (geometry<point>)")
+}
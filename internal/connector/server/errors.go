@@ -2,6 +2,9 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"regexp"
 	"strings"
 
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
@@ -10,6 +13,17 @@ import (
 // ErrTokenExpired is a sentinel error for expired token authentication failures
 var ErrTokenExpired = errors.New("authentication token has expired")
 
+// Sentinel errors for the failures the user can fix, by changing the connector configuration
+// or the SurrealDB instance. classifyError wraps the errors of SurrealDB and the network with them.
+var (
+	ErrInvalidCredentials = errors.New("invalid SurrealDB credentials")
+	ErrPermissionDenied   = errors.New("permission denied by SurrealDB")
+	ErrNamespaceNotFound  = errors.New("SurrealDB namespace or database not found")
+	ErrHostUnreachable    = errors.New("SurrealDB host unreachable")
+	ErrTypeMismatch       = errors.New("value does not match the SurrealDB field type")
+	ErrHTTPEndpoint       = errors.New("SurrealDB URL is not a WebSocket endpoint")
+)
+
 // isTokenExpiredError checks if an error message indicates token expiration
 func isTokenExpiredError(err error) bool {
 	if err == nil {
@@ -44,3 +58,247 @@ func NewTokenExpiredTask() *pb.Task {
 		Message: TokenExpiredTaskMessage(),
 	}
 }
+
+// errorClass is a kind of failure the user can fix.
+type errorClass struct {
+	// err is the sentinel error of the class
+	err error
+	// matches reports whether an unclassified error belongs to the class
+	matches func(error) bool
+	// remediation tells the user how to fix the failure
+	remediation string
+	// transient reports whether the failure may go away on its own, like when SurrealDB restarts
+	transient bool
+}
+
+var namespaceNotFoundPattern = regexp.MustCompile(`(?i)\b(namespace|database)\b[^.]*\b(does not exist|not found)`)
+
+// errorClasses are tried in order, so that more specific classes come first.
+var errorClasses = []errorClass{
+	{
+		err:         ErrTokenExpired,
+		matches:     isTokenExpiredError,
+		remediation: TokenExpiredTaskMessage(),
+	},
+	{
+		err:     ErrHTTPEndpoint,
+		matches: errorContainsAll("cbor:", "extraneous data"),
+		remediation: `SurrealDB responded in an unexpected format, which happens when the URL is an HTTP endpoint.
+
+To fix this, set "URL" in your Fivetran connector configuration to the WebSocket endpoint of SurrealDB,
+like wss://example.com/rpc instead of https://example.com/rpc, and re-test the connection.`,
+	},
+	{
+		err:     ErrInvalidCredentials,
+		matches: errorContainsAny("There was a problem with authentication", "invalid username or password"),
+		remediation: `SurrealDB rejected the credentials of the connector.
+
+To fix this, check "User", "Password" and "Authentication Level" in your Fivetran connector configuration.
+For namespace-level authentication, the user must be defined on the namespace of "Namespace",
+like DEFINE USER fivetran ON NAMESPACE PASSWORD "your_secure_password" ROLES OWNER;`,
+	},
+	{
+		err:     ErrPermissionDenied,
+		matches: errorContainsAny("Not enough permissions", "IAM error"),
+		remediation: `The SurrealDB user of the connector is not allowed to perform this operation.
+
+To fix this, grant the user the OWNER role on the namespace of "Namespace",
+like DEFINE USER OVERWRITE fivetran ON NAMESPACE PASSWORD "your_secure_password" ROLES OWNER;
+The connector defines databases, tables and fields, so the EDITOR and VIEWER roles are not enough.`,
+	},
+	{
+		err: ErrNamespaceNotFound,
+		matches: func(err error) bool {
+			return namespaceNotFoundPattern.MatchString(err.Error())
+		},
+		remediation: `The SurrealDB namespace or database of the connector does not exist.
+
+To fix this, check "Namespace" in your Fivetran connector configuration,
+or create the namespace with DEFINE NAMESPACE your_namespace;
+Databases are created by the connector, as long as its user is allowed to define them.`,
+	},
+	{
+		err:     ErrHostUnreachable,
+		matches: isHostUnreachableError,
+		remediation: `The connector could not reach SurrealDB.
+
+To fix this, check "URL" in your Fivetran connector configuration,
+and make sure that SurrealDB is running and accepts connections from Fivetran,
+including any firewall rules or IP allowlists in front of it.`,
+		transient: true,
+	},
+	{
+		err:     ErrTypeMismatch,
+		matches: errorContainsAny("but expected a", "Couldn't coerce value for field"),
+		remediation: `A value could not be written because the type of its SurrealDB field does not match.
+
+To fix this, check the DEFINE FIELD statements of the table, like INFO FOR TABLE your_table;
+Fields defined outside of the connector must have the type of their Fivetran column,
+or be removed so that the connector can redefine them.`,
+	},
+}
+
+func errorContainsAny(substrs ...string) func(error) bool {
+	return func(err error) bool {
+		msg := err.Error()
+		for _, s := range substrs {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func errorContainsAll(substrs ...string) func(error) bool {
+	return func(err error) bool {
+		msg := err.Error()
+		for _, s := range substrs {
+			if !strings.Contains(msg, s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isHostUnreachableError checks if the connection to SurrealDB could not be established
+func isHostUnreachableError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	return errorContainsAny("connection refused", "no such host", "network is unreachable")(err)
+}
+
+// classifyError wraps err with the sentinel error of its class, if any,
+// so that errors.Is(err, ErrPermissionDenied) and alike work on SurrealDB and network errors.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return err
+		}
+	}
+	for _, c := range errorClasses {
+		if c.matches(err) {
+			return fmt.Errorf("%w: %w", c.err, err)
+		}
+	}
+	return err
+}
+
+// remediationFor returns how to fix the classified err,
+// or false if the user cannot fix it.
+func remediationFor(err error) (string, bool) {
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return c.remediation, true
+		}
+	}
+	return "", false
+}
+
+// failureTask classifies err, and returns the Task telling the user how to fix it,
+// or nil if err should be reported as a Warning.
+func (s *Server) failureTask(err error) (*pb.Task, error) {
+	err = classifyError(err)
+	remediation, ok := remediationFor(err)
+	if !ok {
+		return nil, err
+	}
+	s.LogSevere("Returning a task to the user", err)
+	return &pb.Task{
+		Message: remediation + "\n\nError: " + err.Error(),
+	}, err
+}
+
+// syncFailureTask is failureTask for the RPCs run by syncs, which report transient failures as Warnings,
+// along with the failures of the warned classes, so that Fivetran retries them instead of waiting for the user.
+// Transient failures are reported as Tasks only by the configuration test.
+func (s *Server) syncFailureTask(err error, warned ...error) (*pb.Task, error) {
+	err = classifyError(err)
+	for _, c := range errorClasses {
+		if c.transient {
+			warned = append(warned, c.err)
+		}
+	}
+	for _, w := range warned {
+		if errors.Is(err, w) {
+			return nil, err
+		}
+	}
+	return s.failureTask(err)
+}
+
+// testFailure returns the failure message of the configuration test for err,
+// including how to fix it, if possible.
+func (s *Server) testFailure(err error) (string, error) {
+	task, err := s.failureTask(err)
+	if task != nil {
+		return task.Message, err
+	}
+	return err.Error(), err
+}
+
+func (s *Server) describeTableFailure(err error) (*pb.DescribeTableResponse, error) {
+	task, err := s.syncFailureTask(err)
+	if task != nil {
+		return &pb.DescribeTableResponse{Response: &pb.DescribeTableResponse_Task{Task: task}}, err
+	}
+	return &pb.DescribeTableResponse{Response: &pb.DescribeTableResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
+
+func (s *Server) createTableFailure(err error) (*pb.CreateTableResponse, error) {
+	task, err := s.syncFailureTask(err)
+	if task != nil {
+		return &pb.CreateTableResponse{Response: &pb.CreateTableResponse_Task{Task: task}}, err
+	}
+	return &pb.CreateTableResponse{Response: &pb.CreateTableResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
+
+func (s *Server) alterTableFailure(err error) (*pb.AlterTableResponse, error) {
+	task, err := s.syncFailureTask(err)
+	if task != nil {
+		return &pb.AlterTableResponse{Response: &pb.AlterTableResponse_Task{Task: task}}, err
+	}
+	return &pb.AlterTableResponse{Response: &pb.AlterTableResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
+
+func (s *Server) truncateFailure(err error) (*pb.TruncateResponse, error) {
+	task, err := s.syncFailureTask(err)
+	if task != nil {
+		return &pb.TruncateResponse{Response: &pb.TruncateResponse_Task{Task: task}}, err
+	}
+	return &pb.TruncateResponse{Response: &pb.TruncateResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
+
+// writeBatchFailure is shared by WriteBatch and WriteHistoryBatch.
+//
+// In quarantine mode, that is when q is not nil, type mismatches are reported as Warnings too,
+// as the rows SurrealDB rejects are left to the quarantine rather than to the user.
+func (s *Server) writeBatchFailure(err error, q *quarantine) (*pb.WriteBatchResponse, error) {
+	var warned []error
+	if q != nil {
+		warned = append(warned, ErrTypeMismatch)
+	}
+	task, err := s.syncFailureTask(err, warned...)
+	if task != nil {
+		return &pb.WriteBatchResponse{Response: &pb.WriteBatchResponse_Task{Task: task}}, err
+	}
+	return &pb.WriteBatchResponse{Response: &pb.WriteBatchResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
+
+func (s *Server) migrateFailure(err error) (*pb.MigrateResponse, error) {
+	task, err := s.syncFailureTask(err)
+	if task != nil {
+		return &pb.MigrateResponse{Response: &pb.MigrateResponse_Task{Task: task}}, err
+	}
+	return &pb.MigrateResponse{Response: &pb.MigrateResponse_Warning{Warning: &pb.Warning{Message: err.Error()}}}, err
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "token expired",
+			err:      errors.New("There was a problem with authentication: The token has expired"),
+			expected: ErrTokenExpired,
+		},
+		{
+			name:     "already classified token expired",
+			err:      fmt.Errorf("%w: original error", ErrTokenExpired),
+			expected: ErrTokenExpired,
+		},
+		{
+			name:     "invalid credentials",
+			err:      fmt.Errorf("failed to sign in to SurrealDB: %w", errors.New("There was a problem with authentication")),
+			expected: ErrInvalidCredentials,
+		},
+		{
+			name:     "permission denied",
+			err:      errors.New("IAM error: Not enough permissions to perform this action"),
+			expected: ErrPermissionDenied,
+		},
+		{
+			name:     "namespace not found",
+			err:      errors.New("The namespace 'fivetran' does not exist"),
+			expected: ErrNamespaceNotFound,
+		},
+		{
+			name:     "database not found",
+			err:      errors.New("The database 'public' does not exist"),
+			expected: ErrNamespaceNotFound,
+		},
+		{
+			name:     "dial error",
+			err:      fmt.Errorf("failed to connect: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}),
+			expected: ErrHostUnreachable,
+		},
+		{
+			name:     "dns error",
+			err:      &net.DNSError{Err: "no such host", Name: "surrealdb.invalid", IsNotFound: true},
+			expected: ErrHostUnreachable,
+		},
+		{
+			name:     "type mismatch",
+			err:      errors.New("Found 'abc' for field `amount`, with record `orders:1`, but expected a int"),
+			expected: ErrTypeMismatch,
+		},
+		{
+			name:     "HTTP endpoint",
+			err:      errors.New("cbor: 18 bytes of extraneous data starting at index 21"),
+			expected: ErrHTTPEndpoint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+			require.ErrorIs(t, err, tt.expected)
+			require.ErrorIs(t, err, tt.err)
+			require.Contains(t, err.Error(), tt.err.Error())
+
+			_, ok := remediationFor(err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestClassifyError_Unclassified(t *testing.T) {
+	require.NoError(t, classifyError(nil))
+
+	for _, err := range []error{
+		errors.New("table orders has fields of types not mappable to Fivetran data types: geo (geometry)"),
+		errors.New("connection reset by peer"),
+		errors.New("the table 'orders' does not exist"),
+	} {
+		classified := classifyError(err)
+		require.Equal(t, err, classified)
+
+		_, ok := remediationFor(classified)
+		require.False(t, ok, err.Error())
+	}
+}
+
+func TestFailureResponses(t *testing.T) {
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+
+	denied := errors.New("IAM error: Not enough permissions to perform this action")
+	unknown := errors.New("something went wrong")
+
+	t.Run("classified errors become tasks", func(t *testing.T) {
+		res, err := srv.writeBatchFailure(denied, nil)
+		require.ErrorIs(t, err, ErrPermissionDenied)
+		require.Nil(t, res.GetWarning())
+		require.Contains(t, res.GetTask().Message, "ROLES OWNER")
+		require.Contains(t, res.GetTask().Message, denied.Error())
+	})
+
+	t.Run("other errors become warnings", func(t *testing.T) {
+		res, err := srv.writeBatchFailure(unknown, nil)
+		require.Equal(t, unknown, err)
+		require.Nil(t, res.GetTask())
+		require.Equal(t, unknown.Error(), res.GetWarning().Message)
+	})
+
+	t.Run("every RPC", func(t *testing.T) {
+		tasks := []interface{ GetTask() *pb.Task }{}
+		warnings := []interface{ GetWarning() *pb.Warning }{}
+
+		describe, _ := srv.describeTableFailure(denied)
+		create, _ := srv.createTableFailure(denied)
+		alter, _ := srv.alterTableFailure(denied)
+		truncate, _ := srv.truncateFailure(denied)
+		migrate, _ := srv.migrateFailure(denied)
+		tasks = append(tasks, describe, create, alter, truncate, migrate)
+
+		describe, _ = srv.describeTableFailure(unknown)
+		create, _ = srv.createTableFailure(unknown)
+		alter, _ = srv.alterTableFailure(unknown)
+		truncate, _ = srv.truncateFailure(unknown)
+		migrate, _ = srv.migrateFailure(unknown)
+		warnings = append(warnings, describe, create, alter, truncate, migrate)
+
+		for _, res := range tasks {
+			require.NotNil(t, res.GetTask())
+		}
+		for _, res := range warnings {
+			require.NotNil(t, res.GetWarning())
+		}
+	})
+
+	t.Run("transient errors become tasks only in the configuration test", func(t *testing.T) {
+		unreachable := fmt.Errorf("failed to connect: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")})
+
+		res, err := srv.writeBatchFailure(unreachable, nil)
+		require.ErrorIs(t, err, ErrHostUnreachable)
+		require.Nil(t, res.GetTask())
+		require.Contains(t, res.GetWarning().Message, "connection refused")
+
+		migrate, err := srv.migrateFailure(unreachable)
+		require.ErrorIs(t, err, ErrHostUnreachable)
+		require.Nil(t, migrate.GetTask())
+		require.NotNil(t, migrate.GetWarning())
+
+		describe, _ := srv.describeTableFailure(unreachable)
+		require.NotNil(t, describe.GetWarning())
+
+		msg, err := srv.testFailure(unreachable)
+		require.ErrorIs(t, err, ErrHostUnreachable)
+		require.Contains(t, msg, "make sure that SurrealDB is running")
+	})
+
+	t.Run("type mismatches become warnings in quarantine mode", func(t *testing.T) {
+		mismatch := errors.New("Found 'abc' for field `amount`, with record `orders:1`, but expected a int")
+
+		res, err := srv.writeBatchFailure(mismatch, nil)
+		require.ErrorIs(t, err, ErrTypeMismatch)
+		require.NotNil(t, res.GetTask())
+
+		res, err = srv.writeBatchFailure(mismatch, srv.newQuarantine(nil, "orders"))
+		require.ErrorIs(t, err, ErrTypeMismatch)
+		require.Nil(t, res.GetTask())
+		require.Contains(t, res.GetWarning().Message, mismatch.Error())
+	})
+
+	t.Run("test failure message", func(t *testing.T) {
+		msg, err := srv.testFailure(fmt.Errorf("%w: The token has expired", ErrTokenExpired))
+		require.ErrorIs(t, err, ErrTokenExpired)
+		require.Contains(t, msg, "namespace-level user/password authentication")
+
+		msg, err = srv.testFailure(unknown)
+		require.Equal(t, unknown, err)
+		require.Equal(t, unknown.Error(), msg)
+	})
+}
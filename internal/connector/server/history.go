@@ -1,12 +1,10 @@
 package server
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -74,7 +72,7 @@ func buildRangeQuerySubquery(selectFields string) string {
 			id >= type::thing($tb, $lower) AS _gte,
 			id < type::thing($tb, $upper) AS _lt
 		FROM type::table($tb)
-	) WHERE _gte = true AND _lt = true ORDER BY id DESC LIMIT 1;`, selectFields)
+	) WHERE _gte = true AND _lt = true ORDER BY id DESC LIMIT 1`, selectFields)
 }
 
 // hasIdPKColumn checks if "id" is one of the primary key columns.
@@ -87,42 +85,35 @@ func hasIdPKColumn(pkColumns []string) bool {
 	return false
 }
 
-// selectLatestHistoryRecord queries for the latest history record matching the given PK values.
+// latestHistoryRecordQuery returns the query selecting the latest history record matching the given PK values,
+// and its parameters.
 // It handles both the "id" column case (using range query) and the standard case (using equality).
 //
+// The query is a subquery, without a trailing semicolon, so that the statements writing a history mode row
+// read the previous version of the record in the same query, and in the same transaction in transactional write mode.
+//
 // Parameters:
-//   - selectFields: fields to select (e.g., "_fivetran_start, id" or "type::fields($fields), id")
-//   - extraVars: additional variables to include in the query (e.g., "fields" for type::fields)
-//   - pkColumns: primary key column names
+//   - selectFields: fields to select (e.g., "id" or "*")
+//   - pkColumns: primary key column names, where _fivetran_start is ignored
 //   - pkValues: primary key values corresponding to pkColumns
 //   - tableName: the SurrealDB table name
 //
-// Returns the query result or nil if no record found.
-func (s *Server) selectLatestHistoryRecord(
-	ctx context.Context,
-	db *surrealdb.DB,
+// Returns the query, its parameters, and the condition on the primary key for error messages.
+func (s *Server) latestHistoryRecordQuery(
 	selectFields string,
-	extraVars map[string]any,
 	pkColumns []string,
 	pkValues []any,
 	tableName string,
-) ([]map[string]any, string, error) {
+) (string, map[string]any, string) {
 	vars := map[string]any{
 		"tb": tableName,
 	}
-	for k, v := range extraVars {
-		vars[k] = v
-	}
-
-	var query string
-	var byID string
 
 	if hasIdPKColumn(pkColumns) {
 		// Range query approach for "id" column
 		rangeConfig := buildRecordIDRangeQueryBounds(pkColumns, pkValues)
 		vars["lower"] = rangeConfig.lowerBound
 		vars["upper"] = rangeConfig.upperBound
-		byID = rangeConfig.byID
 
 		if s.Debugging() {
 			var lowerTypes, upperTypes []string
@@ -132,44 +123,50 @@ func (s *Server) selectLatestHistoryRecord(
 			for _, v := range rangeConfig.upperBound {
 				upperTypes = append(upperTypes, fmt.Sprintf("%T", v))
 			}
-			s.LogDebug("selectLatestHistoryRecord range query bounds",
+			s.LogDebug("latestHistoryRecordQuery range query bounds",
 				"lower", rangeConfig.lowerBound,
 				"lowerTypes", lowerTypes,
 				"upper", rangeConfig.upperBound,
 				"upperTypes", upperTypes)
 		}
 
-		query = buildRangeQuerySubquery(selectFields)
-	} else {
-		// Standard equality approach
-		var conds []string
-		for i, col := range pkColumns {
-			if col == "_fivetran_start" {
-				continue
-			}
-			vars[col] = pkValues[i]
-			conds = append(conds, fmt.Sprintf("%s = $%s", col, col))
-		}
-		byID = strings.Join(conds, " AND ")
-
-		// Note: We include _fivetran_start in the selected fields explicitly
-		// because that's needed to use _fivetran_start in order-by clause.
-		// SurrealDB cannot find that _fivetran_start is included in $fields at query parsing time.
-		query = fmt.Sprintf(
-			"SELECT %s, _fivetran_start FROM type::table($tb) WHERE %s ORDER BY _fivetran_start DESC LIMIT 1;",
-			selectFields,
-			byID,
-		)
-	}
-
-	req, err := surrealdb.Query[[]map[string]any](ctx, db, query, vars)
-	if err != nil {
-		return nil, byID, fmt.Errorf("query failed: %w", err)
+		return buildRangeQuerySubquery(selectFields), vars, rangeConfig.byID
 	}
 
-	if len(*req) == 0 {
-		return nil, byID, fmt.Errorf("got empty query response")
+	// Standard equality approach.
+	// The PK values are passed as $pk0, $pk1, ..., so that they never collide with the parameters
+	// of the statements writing the record, which are named after the columns.
+	var conds []string
+	for i, col := range pkColumns {
+		if col == "_fivetran_start" {
+			continue
+		}
+		param := fmt.Sprintf("pk%d", i)
+		vars[param] = pkValues[i]
+		conds = append(conds, fmt.Sprintf("%s = $%s", quoteIdent(col), param))
 	}
+	byID := strings.Join(conds, " AND ")
+
+	// Note: We include _fivetran_start in the selected fields explicitly
+	// because that's needed to use _fivetran_start in order-by clause.
+	// SurrealDB cannot find that _fivetran_start is included in $fields at query parsing time.
+	query := fmt.Sprintf(
+		"SELECT %s, _fivetran_start FROM type::table($tb) WHERE %s ORDER BY _fivetran_start DESC LIMIT 1",
+		selectFields,
+		byID,
+	)
+
+	return query, vars, byID
+}
 
-	return (*req)[0].Result, byID, nil
+// withLatestHistoryRecord returns the query setting $_latest to the record selected by the latest query,
+// and running the statements only if there is one.
+//
+// The statements read the previous version of the record from $_latest,
+// so that a history mode row is read and written by a single query.
+// In transactional write mode, the query is buffered to the transaction like any other write,
+// and sees the rows written before it in the same transaction.
+// $_latest is defined by LET rather than passed as a parameter, so txWriter leaves it as is.
+func withLatestHistoryRecord(latest string, statements ...string) string {
+	return fmt.Sprintf("LET $_latest = array::first((%s));\nIF $_latest != NONE {\n\t%s\n};", latest, strings.Join(statements, "\n\t"))
 }
@@ -2,10 +2,12 @@ package server
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -517,3 +519,42 @@ func TestRangeQuerySubquery_WithCompositePK(t *testing.T) {
 		assert.Equal(t, "T2-ID1", record["name"])
 	})
 }
+
+func TestLatestHistoryRecordQuery(t *testing.T) {
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	start := models.CustomDateTime{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("equality on primary key columns", func(t *testing.T) {
+		query, vars, byID := srv.latestHistoryRecordQuery("id", []string{"tenant", "_fivetran_id", "_fivetran_start"}, []any{"t1", "user1", start}, "users")
+		assert.Equal(t, "SELECT id, _fivetran_start FROM type::table($tb) WHERE `tenant` = $pk0 AND `_fivetran_id` = $pk1 ORDER BY _fivetran_start DESC LIMIT 1", query)
+		assert.Equal(t, map[string]any{"tb": "users", "pk0": "t1", "pk1": "user1"}, vars)
+		assert.Equal(t, "`tenant` = $pk0 AND `_fivetran_id` = $pk1", byID)
+	})
+
+	t.Run("range on record IDs with id column", func(t *testing.T) {
+		query, vars, _ := srv.latestHistoryRecordQuery("*", []string{"id", "_fivetran_start"}, []any{"id1", start}, "orders")
+		assert.Equal(t, buildRangeQuerySubquery("*"), query)
+		assert.NotContains(t, query, ";", "the query is used as a subquery")
+		assert.Equal(t, "orders", vars["tb"])
+		assert.Equal(t, []any{"id1"}, vars["lower"])
+	})
+}
+
+func TestWithLatestHistoryRecord(t *testing.T) {
+	query := withLatestHistoryRecord("SELECT id FROM users",
+		"UPDATE $_latest.id SET _fivetran_active = false RETURN NONE;",
+		"UPSERT $thing CONTENT $content RETURN NONE;",
+	)
+	assert.Equal(t, "LET $_latest = array::first((SELECT id FROM users));\n"+
+		"IF $_latest != NONE {\n"+
+		"\tUPDATE $_latest.id SET _fivetran_active = false RETURN NONE;\n"+
+		"\tUPSERT $thing CONTENT $content RETURN NONE;\n"+
+		"};", query)
+
+	// $_latest is shared by the statements of the row, so the transaction never renames it
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	tx := srv.newTxWriter(nil)
+	tx.Add(query, map[string]any{"thing": "a", "content": 1})
+	assert.Contains(t, tx.statements[0], "UPDATE $_latest.id")
+	assert.Contains(t, tx.statements[0], "UPSERT $s0_thing CONTENT $s0_content")
+}
@@ -2,58 +2,117 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
 	"github.com/surrealdb/fivetran-destination/internal/connector/server/migrator"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"google.golang.org/protobuf/proto"
 )
 
-func (s *Server) migrate(ctx context.Context, req *pb.MigrateRequest) error {
+// migrate runs the migration, or only plans it in dry-run mode, in which case it returns the plan.
+func (s *Server) migrate(ctx context.Context, req *pb.MigrateRequest) (_ *migrator.Plan, err error) {
+	s.LogInfo("Starting migration operation on %s.%s", req.Details.Schema, req.Details.Table)
+
+	cfg, err := s.parseConfig(req.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing migrate config: %w", err)
+	}
+
+	ctx = metrics.WithLabels(ctx, metrics.Labels{
+		RPC:    "Migrate",
+		Schema: req.Details.Schema,
+		Table:  req.Details.Table,
+	})
+
+	req = withTargetMigrationTables(cfg, req)
 	schema, table := req.Details.Schema, req.Details.Table
-	s.LogInfo("Starting migration operation on %s.%s", schema, table)
 
-	db, err := s.parseConfigAndConnect(ctx, req.Configuration, schema)
+	db, release, err := s.acquireDB(ctx, cfg, schema)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
 	m := migrator.New(db, s.Logging)
+	if s.migrationDryRun || cfg.migrationDryRun {
+		m = migrator.NewDryRun(db, s.Logging)
+	}
+	m.WithRetry(s.retry)
+
+	fingerprint, err := migrationFingerprint(req.Details)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.BeginJournal(ctx, table, migrationOperation(req.Details), fingerprint); err != nil {
+		return nil, err
+	}
 
 	switch v := req.Details.Operation.(type) {
 	case *pb.MigrationDetails_Add:
 		if err := s.migrateAdd(ctx, m, schema, table, v.Add); err != nil {
-			return err
+			return nil, err
 		}
 	case *pb.MigrationDetails_UpdateColumnValue:
 		if err := s.migrateUpdateColumnValue(ctx, m, schema, table, v.UpdateColumnValue); err != nil {
-			return err
+			return nil, err
 		}
 	case *pb.MigrationDetails_Rename:
 		if err := s.migrateRename(ctx, m, schema, table, v.Rename); err != nil {
-			return err
+			return nil, err
 		}
 	case *pb.MigrationDetails_Copy:
 		if err := s.migrateCopy(ctx, m, schema, table, v.Copy); err != nil {
-			return err
+			return nil, err
 		}
 	case *pb.MigrationDetails_Drop:
 		if err := s.migrateDrop(ctx, m, schema, table, v.Drop); err != nil {
-			return err
+			return nil, err
 		}
 	case *pb.MigrationDetails_TableSyncModeMigration:
 		if err := s.migrateTableSyncModeMigration(ctx, m, schema, table, v.TableSyncModeMigration); err != nil {
-			return err
+			return nil, err
 		}
 	default:
-		return fmt.Errorf("unknown migration operation: %T", v)
+		return nil, fmt.Errorf("unknown migration operation: %T", v)
 	}
-	return nil
+
+	if err := m.EndJournal(ctx); err != nil {
+		return nil, err
+	}
+
+	if plan := m.Plan(); plan != nil {
+		s.LogInfo("Planned migration in dry-run mode", "schema", schema, "table", table, "steps", len(plan.Steps), "plan", plan.String())
+		return plan, nil
+	}
+	return nil, nil
+}
+
+// migrationOperation returns the name of the operation of the migration, like "TableSyncModeMigration".
+func migrationOperation(details *pb.MigrationDetails) string {
+	return strings.TrimPrefix(reflect.TypeOf(details.Operation).Elem().Name(), "MigrationDetails_")
+}
+
+// migrationFingerprint returns the hash identifying the migration in the migration journal,
+// so that only retrying the same migration resumes it once interrupted.
+func migrationFingerprint(details *pb.MigrationDetails) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(details)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal migration details: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// migrationPlanMessage returns the message of the task returned for a migration planned in dry-run mode.
+func migrationPlanMessage(plan *migrator.Plan) string {
+	return "Migration dry run is enabled, so the migration was planned without changing any data. " +
+		"Disable it to apply the migration, which runs the following statements:\n\n" + plan.String()
 }
 
 func (s *Server) migrateDrop(ctx context.Context, m *migrator.Migrator, schema string, table string, drop *pb.DropOperation) error {
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestMigrationFingerprint(t *testing.T) {
+	drop := func(table string) *pb.MigrationDetails {
+		return &pb.MigrationDetails{
+			Schema: "tester",
+			Table:  table,
+			Operation: &pb.MigrationDetails_Drop{Drop: &pb.DropOperation{
+				Entity: &pb.DropOperation_DropTable{DropTable: true},
+			}},
+		}
+	}
+
+	a, err := migrationFingerprint(drop("users"))
+	require.NoError(t, err)
+	b, err := migrationFingerprint(drop("users"))
+	require.NoError(t, err)
+	c, err := migrationFingerprint(drop("orders"))
+	require.NoError(t, err)
+
+	assert.Equal(t, a, b, "retrying the same migration resumes it")
+	assert.NotEqual(t, a, c)
+	assert.Equal(t, "Drop", migrationOperation(drop("users")))
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
-
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 )
@@ -25,7 +23,7 @@ func (m *Migrator) AddColumnWithDefaultValue(ctx context.Context, schema, table,
 	type InfoForTableResult struct {
 		Fields map[string]string `cbor:"fields"`
 	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
+	infoResults, err := read[InfoForTableResult](ctx, m, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
 	if err != nil {
 		return fmt.Errorf("failed to get table info for %s: %w", table, err)
 	}
@@ -58,14 +56,14 @@ func (m *Migrator) AddColumnWithDefaultValue(ctx context.Context, schema, table,
 	if err != nil {
 		return fmt.Errorf("failed to generate field definition: %w", err)
 	}
-	_, err = surrealdb.Query[any](ctx, m.db, defineFieldQuery, nil)
+	_, err = write[any](ctx, m, table, defineFieldQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to add column %s to table %s: %w", column, table, err)
 	}
 
 	// 6. Update existing records to have the default value
 	updateQuery := fmt.Sprintf("UPDATE %s SET %s = $default_value WHERE %s IS NONE", table, column, column)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, map[string]any{
+	_, err = write[any](ctx, m, table, updateQuery, map[string]any{
 		"default_value": defaultVal,
 	})
 	if err != nil {
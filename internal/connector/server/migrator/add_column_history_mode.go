@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
@@ -29,7 +28,7 @@ func (m *Migrator) AddColumnInHistoryMode(ctx context.Context, schema, table str
 	type InfoForTableResult struct {
 		Fields map[string]string `cbor:"fields"`
 	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
+	infoResults, err := read[InfoForTableResult](ctx, m, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
 	if err != nil {
 		return fmt.Errorf("failed to get table info for %s: %w", table, err)
 	}
@@ -44,7 +43,7 @@ func (m *Migrator) AddColumnInHistoryMode(ctx context.Context, schema, table str
 		Max *models.CustomDateTime `cbor:"max"`
 	}
 	maxQuery := fmt.Sprintf("SELECT time::max(_fivetran_start) AS max FROM %s WHERE _fivetran_active = true GROUP ALL", table)
-	maxResults, err := surrealdb.Query[[]MaxStartResult](ctx, m.db, maxQuery, nil)
+	maxResults, err := read[[]MaxStartResult](ctx, m, maxQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get max _fivetran_start from table %s: %w", table, err)
 	}
@@ -67,7 +66,7 @@ func (m *Migrator) AddColumnInHistoryMode(ctx context.Context, schema, table str
 	if err != nil {
 		return fmt.Errorf("failed to generate field definition: %w", err)
 	}
-	_, err = surrealdb.Query[any](ctx, m.db, defineFieldQuery, nil)
+	_, err = write[any](ctx, m, table, defineFieldQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to add column %s to table %s: %w", column.Name, table, err)
 	}
@@ -109,7 +108,7 @@ func (m *Migrator) AddColumnInHistoryMode(ctx context.Context, schema, table str
 			*
 		FROM %s WHERE _fivetran_active = true
 	`, table, column.Name, table)
-	_, err = surrealdb.Query[any](ctx, m.db, insertQuery, map[string]any{
+	_, err = write[any](ctx, m, table, insertQuery, map[string]any{
 		"default_value":       defaultVal,
 		"operation_timestamp": operationTimestamp,
 	})
@@ -124,7 +123,7 @@ func (m *Migrator) AddColumnInHistoryMode(ctx context.Context, schema, table str
 			_fivetran_active = false
 		WHERE _fivetran_active = true AND %s IS NONE
 	`, table, column.Name)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, map[string]any{
+	_, err = write[any](ctx, m, table, updateQuery, map[string]any{
 		"end_time_prev": models.CustomDateTime{Time: endTimePrev},
 	})
 	if err != nil {
@@ -0,0 +1,59 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+)
+
+// historyIDExpression computes the history mode record ID, [pk..., _fivetran_start],
+// from both array record IDs, [pk...], and scalar record IDs of single-column primary keys, pk.
+const historyIDExpression = "array::add(IF type::is::array(record::id(id)) THEN record::id(id) ELSE [record::id(id)] END, _fivetran_start)"
+
+// useArrayRecordIDs redefines the primary key field of a table defined to use scalar record IDs,
+// so that the table is defined to use arrays of the primary key values as record IDs.
+// It does nothing for the other tables.
+//
+// It is used before converting a table to history mode, whose record IDs are always arrays.
+// The caller is responsible for converting the IDs of the existing records, using historyIDExpression.
+func (m *Migrator) useArrayRecordIDs(ctx context.Context, table string) error {
+	tm := tablemapper.New(m.db, m.Logging)
+	info, err := tm.InfoForTable(ctx, table)
+	if err != nil {
+		return fmt.Errorf("failed to get table info for %s: %w", table, err)
+	}
+
+	for _, c := range info.Columns {
+		if !c.ScalarRecordID {
+			continue
+		}
+
+		columns, err := tablemapper.ColumnsFromSurrealToFivetran([]tablemapper.ColumnInfo{c})
+		if err != nil {
+			return err
+		}
+
+		// The field definitions generated from the Fivetran column never mark it as the scalar record ID.
+		var q string
+		if c.Name == "id" {
+			q, err = tablemapper.DefineFieldQueryForHistoryModeIDFromFt(table, columns[0], c.FtIndex)
+		} else {
+			q, err = tablemapper.DefineFieldQueryFromFt(table, columns[0], c.FtIndex)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := write[any](ctx, m, table, q, nil); err != nil {
+			return fmt.Errorf("failed to redefine field %s on %s for array record IDs: %w", c.Name, table, err)
+		}
+
+		m.LogInfo("Switched table to array record IDs",
+			"table", table,
+			"column", c.Name,
+		)
+	}
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -40,10 +39,12 @@ func (m *Migrator) BatchCopyRecords(ctx context.Context, oldTable, newTable, fie
 	`, newTable, fields, oldTable)
 
 	// Start from the beginning
-	startID := models.NewRecordID(oldTable, []any{})
+	startID := firstRecordID(oldTable)
 
 	for {
-		results, err := surrealdb.Query[any](ctx, m.db, copyQuery, map[string]any{
+		// The batch is not retried on transient failures, as it may have been committed
+		// before the connection dropped, and inserting its records again would fail
+		results, err := write[any](ctx, m, oldTable, copyQuery, map[string]any{
 			"start_id":   startID,
 			"batch_size": batchSize,
 		})
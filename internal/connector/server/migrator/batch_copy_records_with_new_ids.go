@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -24,6 +23,10 @@ import (
 // Unlike BatchCopyRecords, this tracks the original source IDs for pagination rather than
 // the new inserted IDs.
 //
+// The copy is a journaled step of the migration, whose cursor is saved along with each batch,
+// so that an interrupted copy resumes after the last copied record rather than copying records twice.
+// For the same reason, a batch is only retried on transient failures in a journaled migration.
+//
 // Example use cases:
 //   - Copying with simplified IDs: idExpression = "array::slice(record::id(id), 0, 1)"
 //   - Copying with extended IDs: idExpression = "array::add(record::id(id), $timestamp)"
@@ -34,21 +37,34 @@ func (m *Migrator) BatchCopyRecordsWithNewIDs(ctx context.Context, fromTable, se
 		batchSize = 1000
 	}
 
+	return m.step(ctx, "copy "+fromTable+" to "+toTable, func() error {
+		return m.batchCopyRecordsWithNewIDs(ctx, fromTable, selectedFields, toTable, idExpression, insertedFields, batchSize, additionalVars)
+	})
+}
+
+func (m *Migrator) batchCopyRecordsWithNewIDs(ctx context.Context, fromTable, selectedFields, toTable, idExpression, insertedFields string, batchSize int, additionalVars map[string]any) error {
+	journalVars := map[string]any{}
+	resumed, saveCursor := m.cursor(journalVars)
+
 	// Query to copy records in batches with ID transformation
 	// Use temp variable $selected to track original IDs for pagination
 	copyQuery := fmt.Sprintf(`
 		BEGIN;
 		LET $selected = SELECT %s FROM %s WHERE id > $start_id LIMIT $batch_size;
 		LET $inserted = INSERT INTO %s (SELECT %s AS id, %s FROM $selected);
+		%s
 		RETURN {
 			last_source_record: array::last($selected),
 			inserted_count: array::len($inserted)
 		};
 		COMMIT;
-	`, selectedFields, fromTable, toTable, idExpression, insertedFields)
+	`, selectedFields, fromTable, toTable, idExpression, insertedFields, saveCursor)
 
-	// Start from the beginning
-	startID := models.NewRecordID(fromTable, []any{})
+	// Start from the beginning, or after the last record copied before the interruption
+	startID := firstRecordID(fromTable)
+	if resumed != nil {
+		startID = models.NewRecordID(fromTable, resumed.ID)
+	}
 
 	for {
 		// Build query parameters by merging additionalVars with pagination params
@@ -60,8 +76,11 @@ func (m *Migrator) BatchCopyRecordsWithNewIDs(ctx context.Context, fromTable, se
 		for k, v := range additionalVars {
 			queryParams[k] = v
 		}
+		for k, v := range journalVars {
+			queryParams[k] = v
+		}
 
-		results, err := surrealdb.Query[map[string]any](ctx, m.db, copyQuery, queryParams)
+		results, err := writeCursorBatch[map[string]any](ctx, m, fromTable, copyQuery, queryParams)
 		if err != nil {
 			return fmt.Errorf("batch copy with new IDs failed: %w", err)
 		}
@@ -0,0 +1,44 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchDeleteRecords deletes records matching the condition from table in batches
+// to prevent large transactions that could exhaust memory and crash RocksDB.
+//
+// The function executes batched DELETE operations until no records match the condition.
+// Each batch is a separate transaction to keep WAL entries small.
+//
+// Parameters:
+//   - table: the table to delete records from
+//   - condition: SurrealQL WHERE condition (e.g., "_fivetran_synced <= $cutoff")
+//   - batchSize: number of records per batch
+//   - additionalVars: additional query parameters to pass to the query (can be nil)
+//
+// It returns the number of deleted records.
+func (m *Migrator) BatchDeleteRecords(ctx context.Context, table, condition string, batchSize int, additionalVars map[string]any) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	// array::len returns 0 when no records remain
+	query := fmt.Sprintf(`
+		array::len(
+			DELETE (SELECT id FROM %s WHERE %s LIMIT $batch_size) RETURN BEFORE
+		)
+	`, table, condition)
+
+	deleted, err := m.repeatBatch(ctx, "delete", table, query, batchSize, additionalVars)
+	if err != nil {
+		return deleted, err
+	}
+
+	m.LogInfo("Batch delete completed",
+		"table", table,
+		"deleted", deleted,
+	)
+
+	return deleted, nil
+}
@@ -0,0 +1,67 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+)
+
+func TestBatchDeleteRecords_DeletesMatchingRecords(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE truncated SCHEMAFULL;
+		DEFINE FIELD value ON truncated TYPE option<int>;
+		DEFINE FIELD _fivetran_synced ON truncated TYPE option<datetime>;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	// Insert 10 old records and 2 new records
+	for i := 1; i <= 12; i++ {
+		synced := "2024-01-01T00:00:00Z"
+		if i > 10 {
+			synced = "2024-06-01T00:00:00Z"
+		}
+		_, err = surrealdb.Query[any](ctx, db, `CREATE type::thing("truncated", $id) SET value = $value, _fivetran_synced = type::datetime($synced)`, map[string]any{
+			"id":     i,
+			"value":  i * 10,
+			"synced": synced,
+		})
+		require.NoError(t, err, "Failed to insert record %d", i)
+	}
+
+	// Delete old records in batches of 3
+	deleted, err := migrator.BatchDeleteRecords(ctx, "truncated", "_fivetran_synced <= type::datetime($cutoff)", 3, map[string]any{
+		"cutoff": "2024-03-01T00:00:00Z",
+	})
+	require.NoError(t, err, "BatchDeleteRecords failed")
+	assert.Equal(t, 10, deleted)
+
+	results, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM truncated", nil)
+	require.NoError(t, err)
+	require.NotNil(t, results)
+	require.NotEmpty(t, *results)
+	assert.Len(t, (*results)[0].Result, 2, "Only new records should remain")
+}
+
+func TestBatchDeleteRecords_EmptyTable(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE empty_truncated SCHEMAFULL;
+		DEFINE FIELD value ON empty_truncated TYPE option<int>;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	deleted, err := migrator.BatchDeleteRecords(ctx, "empty_truncated", "true", 10, nil)
+	require.NoError(t, err, "BatchDeleteRecords on empty table should not fail")
+	assert.Equal(t, 0, deleted)
+}
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // BatchMoveRecords moves records from oldTable to newTable in batches to prevent
@@ -47,7 +45,7 @@ func (m *Migrator) BatchMoveRecords(ctx context.Context, oldTable, newTable, sel
 
 		if m.Debugging() {
 			debugSelectQuery := fmt.Sprintf("SELECT %s FROM %s LIMIT %d", selectedFields, oldTable, batchSize)
-			debugSelectRes, err := surrealdb.Query[[]map[string]any](ctx, m.db, debugSelectQuery, queryParams)
+			debugSelectRes, err := read[[]map[string]any](ctx, m, debugSelectQuery, queryParams)
 			if err != nil {
 				return fmt.Errorf("failed to debug select before batch move from %s to %s: %w", oldTable, newTable, err)
 			}
@@ -59,7 +57,7 @@ func (m *Migrator) BatchMoveRecords(ctx context.Context, oldTable, newTable, sel
 			)
 		}
 
-		results, err := surrealdb.Query[any](ctx, m.db, query, queryParams)
+		results, err := writeBatch[any](ctx, m, oldTable, query, queryParams)
 		if err != nil {
 			return fmt.Errorf("batch move failed: %w", err)
 		}
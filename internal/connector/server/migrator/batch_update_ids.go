@@ -3,9 +3,8 @@ package migrator
 import (
 	"context"
 	"fmt"
-	"strings"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/fivetran-destination/internal/connector/introspect"
 )
 
 // BatchUpdateIDs moves records from table to a temp table with new IDs,
@@ -30,50 +29,63 @@ func (m *Migrator) BatchUpdateIDs(ctx context.Context, table, selectedFields, id
 
 	tempTable := fmt.Sprintf("_temp_%s", table)
 
+	// Each step is journaled, so that an interrupted update resumes from the step it was interrupted in,
+	// rather than moving records whose IDs were already updated to the temp table again.
+	// The moves are resumable by themselves, as each batch moves its records atomically.
+
 	// 1. Create temp table by copying schema from original table using INFO FOR TABLE
-	type InfoForTableResult struct {
-		Fields map[string]string `cbor:"fields"`
-	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
-	if err != nil {
-		return fmt.Errorf("failed to get table info for %s: %w", table, err)
-	}
+	err := m.step(ctx, "create "+tempTable, func() error {
+		defs, err := introspect.TableDefinitions(ctx, m.db, table)
+		if err != nil {
+			return fmt.Errorf("failed to get table info for %s: %w", table, err)
+		}
 
-	// Create temp table
-	_, err = surrealdb.Query[any](ctx, m.db, fmt.Sprintf("DEFINE TABLE %s SCHEMAFULL", tempTable), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create temp table %s: %w", tempTable, err)
-	}
+		// Create temp table
+		_, err = write[any](ctx, m, tempTable, fmt.Sprintf("DEFINE TABLE %s SCHEMAFULL", tempTable), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create temp table %s: %w", tempTable, err)
+		}
 
-	// Copy field definitions, replacing original table name with temp table name
-	if infoResults != nil && len(*infoResults) > 0 {
-		fields := (*infoResults)[0].Result.Fields
-		for _, fieldDef := range fields {
+		// Copy field definitions, replacing original table name with temp table name
+		for _, fieldDef := range defs.Fields {
 			// fieldDef is like "DEFINE FIELD name ON products TYPE option<string>"
 			// Replace table name to target temp table
-			tempFieldDef := strings.Replace(fieldDef, " ON "+table+" ", " ON "+tempTable+" ", 1)
-			_, err = surrealdb.Query[any](ctx, m.db, tempFieldDef, nil)
+			tempFieldDef, err := introspect.Retarget(fieldDef, tempTable)
+			if err == nil {
+				_, err = write[any](ctx, m, tempTable, tempFieldDef, nil)
+			}
 			if err != nil {
 				m.LogInfo("Warning: could not copy field definition", "error", err.Error())
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// 2. Move records from original to temp with new IDs
-	toTempInsertedFields := fmt.Sprintf("%s AS id, %s", idExpression, insertedFields)
-	err = m.BatchMoveRecords(ctx, table, tempTable, selectedFields, toTempInsertedFields, batchSize, additionalVars)
+	err = m.step(ctx, "move "+table+" to "+tempTable, func() error {
+		toTempInsertedFields := fmt.Sprintf("%s AS id, %s", idExpression, insertedFields)
+		return m.BatchMoveRecords(ctx, table, tempTable, selectedFields, toTempInsertedFields, batchSize, additionalVars)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to move records to temp table: %w", err)
 	}
 
 	// 3. Move records back from temp to original (IDs are already updated)
-	err = m.BatchMoveRecords(ctx, tempTable, table, "*", "*", batchSize, nil)
+	err = m.step(ctx, "move "+tempTable+" to "+table, func() error {
+		return m.BatchMoveRecords(ctx, tempTable, table, "*", "*", batchSize, nil)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to move records back from temp table: %w", err)
 	}
 
 	// 4. Remove temp table
-	_, err = surrealdb.Query[any](ctx, m.db, fmt.Sprintf("REMOVE TABLE %s", tempTable), nil)
+	err = m.step(ctx, "remove "+tempTable, func() error {
+		_, err := write[any](ctx, m, tempTable, fmt.Sprintf("REMOVE TABLE %s", tempTable), nil)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to remove temp table %s: %w", tempTable, err)
 	}
@@ -0,0 +1,46 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchUpdateRecords updates records matching the condition in table in batches
+// to prevent large transactions that could exhaust memory and crash RocksDB.
+//
+// The function executes batched UPDATE operations until no records match the condition.
+// Therefore, the set expression must make the updated records no longer match the condition.
+// Otherwise, the function would loop forever.
+//
+// Parameters:
+//   - table: the table to update records in
+//   - condition: SurrealQL WHERE condition (e.g., "_fivetran_active = true")
+//   - set: SurrealQL SET clause without the SET keyword (e.g., "_fivetran_active = false")
+//   - batchSize: number of records per batch
+//   - additionalVars: additional query parameters to pass to the query (can be nil)
+//
+// It returns the number of updated records.
+func (m *Migrator) BatchUpdateRecords(ctx context.Context, table, condition, set string, batchSize int, additionalVars map[string]any) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	// array::len returns 0 when no records remain
+	query := fmt.Sprintf(`
+		array::len(
+			UPDATE (SELECT id FROM %s WHERE %s LIMIT $batch_size) SET %s RETURN BEFORE
+		)
+	`, table, condition, set)
+
+	updated, err := m.repeatBatch(ctx, "update", table, query, batchSize, additionalVars)
+	if err != nil {
+		return updated, err
+	}
+
+	m.LogInfo("Batch update completed",
+		"table", table,
+		"updated", updated,
+	)
+
+	return updated, nil
+}
@@ -0,0 +1,39 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+)
+
+func TestBatchUpdateRecords_UpdatesMatchingRecords(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE history SCHEMAFULL;
+		DEFINE FIELD _fivetran_active ON history TYPE option<bool>;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	for i := 1; i <= 7; i++ {
+		_, err = surrealdb.Query[any](ctx, db, `CREATE type::thing("history", $id) SET _fivetran_active = true`, map[string]any{
+			"id": i,
+		})
+		require.NoError(t, err, "Failed to insert record %d", i)
+	}
+
+	updated, err := migrator.BatchUpdateRecords(ctx, "history", "_fivetran_active = true", "_fivetran_active = false", 3, nil)
+	require.NoError(t, err, "BatchUpdateRecords failed")
+	assert.Equal(t, 7, updated)
+
+	results, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM history WHERE _fivetran_active = true", nil)
+	require.NoError(t, err)
+	require.NotNil(t, results)
+	require.NotEmpty(t, *results)
+	assert.Empty(t, (*results)[0].Result, "No record should remain active")
+}
@@ -3,9 +3,8 @@ package migrator
 import (
 	"context"
 	"fmt"
-	"strings"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/fivetran-destination/internal/connector/introspect"
 )
 
 // CopyColumn adds a new column and copies data from the source column to the destination column.
@@ -19,19 +18,12 @@ import (
 // but the new column won't have data from the source column.
 func (m *Migrator) CopyColumn(ctx context.Context, schema, table, fromColumn, toColumn string) error {
 	// 1. Get the field definition of fromColumn from the table schema
-	type InfoForTableResult struct {
-		Fields map[string]string `cbor:"fields"`
-	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
+	defs, err := introspect.TableDefinitions(ctx, m.db, table)
 	if err != nil {
 		return fmt.Errorf("failed to get table info for %s: %w", table, err)
 	}
 
-	if infoResults == nil || len(*infoResults) == 0 {
-		return fmt.Errorf("no table info returned for %s", table)
-	}
-
-	fields := (*infoResults)[0].Result.Fields
+	fields := defs.Fields
 	fromFieldDef, exists := fields[fromColumn]
 	if !exists {
 		return fmt.Errorf("source column %s does not exist in table %s", fromColumn, table)
@@ -40,16 +32,17 @@ func (m *Migrator) CopyColumn(ctx context.Context, schema, table, fromColumn, to
 	// 2. Create new field definition by replacing field name
 	// fromFieldDef is like "DEFINE FIELD name ON table TYPE option<string> COMMENT '...'"
 	// Replace the field name to create the new column definition
-	toFieldDef := strings.Replace(fromFieldDef, "DEFINE FIELD "+fromColumn+" ", "DEFINE FIELD "+toColumn+" ", 1)
-
-	_, err = surrealdb.Query[any](ctx, m.db, toFieldDef, nil)
+	toFieldDef, err := introspect.RenameField(fromFieldDef, toColumn)
+	if err == nil {
+		_, err = write[any](ctx, m, table, toFieldDef, nil)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create column %s in table %s: %w", toColumn, table, err)
 	}
 
 	// 3. Copy data from source to destination column
 	updateQuery := fmt.Sprintf("UPDATE %s SET %s = %s", table, toColumn, fromColumn)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, nil)
+	_, err = write[any](ctx, m, table, updateQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to copy data from %s to %s in table %s: %w", fromColumn, toColumn, table, err)
 	}
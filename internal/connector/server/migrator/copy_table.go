@@ -3,9 +3,8 @@ package migrator
 import (
 	"context"
 	"fmt"
-	"strings"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/fivetran-destination/internal/connector/introspect"
 )
 
 // CopyTable creates a new table and copies all data from a source table to a destination table.
@@ -18,45 +17,94 @@ import (
 // If this operation returns an unsupported error, Fivetran will fall back to CreateTable RPC
 // without data copying.
 func (m *Migrator) CopyTable(ctx context.Context, schema, fromTable, toTable string) error {
-	// 1. Get schema from source table
-	type InfoForTableResult struct {
-		Fields map[string]string `cbor:"fields"`
+	if err := m.copyTable(ctx, fromTable, toTable); err != nil {
+		return err
 	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", fromTable), nil)
+
+	m.LogInfo("Copied table",
+		"from_table", fromTable,
+		"to_table", toTable,
+	)
+
+	return nil
+}
+
+// copyTable creates toTable with the definitions of fromTable, copies the records of fromTable to it in batches,
+// and verifies the copy, leaving fromTable untouched.
+func (m *Migrator) copyTable(ctx context.Context, fromTable, toTable string) error {
+	const batchSize = 1000
+
+	// 1. Get the table-level definition, fields, indexes and events of the source table
+	tableDef, err := introspect.TableDefinition(ctx, m.db, fromTable)
+	if err != nil {
+		return fmt.Errorf("failed to get table definition for %s: %w", fromTable, err)
+	}
+	defs, err := introspect.TableDefinitions(ctx, m.db, fromTable)
 	if err != nil {
 		return fmt.Errorf("failed to get table info for %s: %w", fromTable, err)
 	}
 
-	// 2. Create destination table
-	_, err = surrealdb.Query[any](ctx, m.db, fmt.Sprintf("DEFINE TABLE %s SCHEMAFULL", toTable), nil)
+	// 2. Create the destination table with the same table-level definition and fields
+	err = m.step(ctx, "create "+toTable, func() error {
+		return m.defineTableLike(ctx, toTable, tableDef, defs.Fields)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 3. Copy all records in batches, keeping their IDs
+	err = m.BatchCopyRecordsWithNewIDs(ctx, fromTable, "*", toTable, "record::id(id)", "*", batchSize, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create destination table %s: %w", toTable, err)
-	}
-
-	// 3. Copy field definitions from source, replacing table name
-	if infoResults != nil && len(*infoResults) > 0 {
-		fields := (*infoResults)[0].Result.Fields
-		for _, fieldDef := range fields {
-			// Replace table name in field definition
-			newFieldDef := strings.Replace(fieldDef, " ON "+fromTable+" ", " ON "+toTable+" ", 1)
-			_, err = surrealdb.Query[any](ctx, m.db, newFieldDef, nil)
-			if err != nil {
-				m.LogInfo("Warning: could not copy field definition", "error", err.Error())
+		return fmt.Errorf("failed to copy data from %s to %s: %w", fromTable, toTable, err)
+	}
+
+	// 4. Define the indexes and events once the records are copied,
+	// so that the indexes are built once, and the events never fire for the copied records
+	err = m.step(ctx, "define indexes and events on "+toTable, func() error {
+		for _, def := range []map[string]string{defs.Indexes, defs.Events} {
+			for name, d := range def {
+				newDef, err := introspect.Retarget(d, toTable)
+				if err == nil {
+					_, err = write[any](ctx, m, toTable, newDef, nil)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to define %s on new table %s: %w", name, toTable, err)
+				}
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// 4. Copy all data from source to destination
-	insertQuery := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", toTable, fromTable)
-	_, err = surrealdb.Query[any](ctx, m.db, insertQuery, nil)
+	// 5. Verify the copy
+	return m.VerifyCopy(ctx, fromTable, toTable)
+}
+
+// defineTableLike defines the table with the DEFINE TABLE statement and the field definitions of another table,
+// removing the table again if any of the fields can't be defined.
+func (m *Migrator) defineTableLike(ctx context.Context, table, tableDef string, fieldDefs map[string]string) error {
+	newTableDef, err := introspect.RetargetTable(tableDef, table)
+	if err == nil {
+		_, err = write[any](ctx, m, table, newTableDef, nil)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to copy data from %s to %s: %w", fromTable, toTable, err)
+		return fmt.Errorf("failed to create new table %s: %w", table, err)
 	}
 
-	m.LogInfo("Copied table",
-		"from_table", fromTable,
-		"to_table", toTable,
-	)
+	for _, fieldDef := range fieldDefs {
+		// e.g., "DEFINE FIELD name ON old_table TYPE string" -> "DEFINE FIELD name ON new_table TYPE string"
+		newFieldDef, err := introspect.Retarget(fieldDef, table)
+		if err == nil {
+			_, err = write[any](ctx, m, table, newFieldDef, nil)
+		}
+		if err != nil {
+			// Try to clean up on failure
+			_, _ = write[any](ctx, m, table, fmt.Sprintf("REMOVE TABLE %s", table), nil)
+			return fmt.Errorf("failed to define field on new table %s: %w", table, err)
+		}
+	}
 
 	return nil
 }
@@ -3,10 +3,9 @@ package migrator
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/fivetran-destination/internal/connector/introspect"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -34,57 +33,66 @@ import (
 func (m *Migrator) CopyTableToHistoryMode(ctx context.Context, schema, table, fromTable, toTable, softDeletedColumn string) error {
 	const batchSize = 1000
 
-	now := models.CustomDateTime{Time: time.Now().UTC()}
-	endTimeMax := models.CustomDateTime{Time: time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)}
-
-	// 1. Get source table schema to replicate field definitions
-	type InfoForTableResult struct {
-		Fields map[string]string `cbor:"fields"`
-	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", fromTable), nil)
+	// A resumed copy uses the start time of the interrupted run for the remaining records
+	now, err := recall(ctx, m, "now", models.CustomDateTime{Time: time.Now().UTC()})
 	if err != nil {
-		return fmt.Errorf("failed to get source table info: %w", err)
-	}
-	if infoResults == nil || len(*infoResults) == 0 {
-		return fmt.Errorf("failed to get source table info: empty results")
-	}
-	sourceFields := (*infoResults)[0].Result.Fields
-
-	// 2. Create destination table with same fields plus history fields
-	_, err = surrealdb.Query[any](ctx, m.db, fmt.Sprintf("DEFINE TABLE %s SCHEMAFULL", toTable), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create destination table: %w", err)
+		return err
 	}
+	endTimeMax := models.CustomDateTime{Time: time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)}
 
-	// Copy field definitions from source table, replacing table name
-	for fieldName, fieldDef := range sourceFields {
-		// Skip soft delete column if present - we'll omit it during copy
-		if softDeletedColumn != "" && fieldName == softDeletedColumn {
-			continue
-		}
-		// Replace old table name with new table name in the field definition
-		// fieldDef is like "DEFINE FIELD fieldName ON oldTable TYPE ..."
-		newFieldDef := strings.Replace(fieldDef, " ON "+fromTable+" ", " ON "+toTable+" ", 1)
-		_, err = surrealdb.Query[any](ctx, m.db, newFieldDef, nil)
+	// 1. Create destination table with the fields of the source table plus history fields
+	err = m.step(ctx, "create "+toTable, func() error {
+		// Get source table schema to replicate field definitions
+		defs, err := introspect.TableDefinitions(ctx, m.db, fromTable)
 		if err != nil {
-			return fmt.Errorf("failed to define field %s on %s: %w", fieldName, toTable, err)
+			return fmt.Errorf("failed to get source table info: %w", err)
 		}
-	}
+		sourceFields := defs.Fields
 
-	// Add history mode fields to destination table
-	historyFields := []string{
-		fmt.Sprintf("DEFINE FIELD _fivetran_start ON %s TYPE option<datetime>", toTable),
-		fmt.Sprintf("DEFINE FIELD _fivetran_end ON %s TYPE option<datetime>", toTable),
-		fmt.Sprintf("DEFINE FIELD _fivetran_active ON %s TYPE option<bool>", toTable),
-	}
-	for _, fieldDef := range historyFields {
-		_, err := surrealdb.Query[any](ctx, m.db, fieldDef, nil)
+		_, err = write[any](ctx, m, toTable, fmt.Sprintf("DEFINE TABLE %s SCHEMAFULL", toTable), nil)
 		if err != nil {
-			return fmt.Errorf("failed to add history field: %w", err)
+			return fmt.Errorf("failed to create destination table: %w", err)
 		}
+
+		// Copy field definitions from source table, replacing table name
+		for fieldName, fieldDef := range sourceFields {
+			// Skip soft delete column if present - we'll omit it during copy
+			if softDeletedColumn != "" && fieldName == softDeletedColumn {
+				continue
+			}
+			// Replace old table name with new table name in the field definition
+			// fieldDef is like "DEFINE FIELD fieldName ON oldTable TYPE ..."
+			newFieldDef, err := introspect.Retarget(fieldDef, toTable)
+			if err == nil {
+				_, err = write[any](ctx, m, toTable, newFieldDef, nil)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to define field %s on %s: %w", fieldName, toTable, err)
+			}
+		}
+
+		// Add history mode fields to destination table
+		historyFields := []string{
+			fmt.Sprintf("DEFINE FIELD _fivetran_start ON %s TYPE option<datetime>", toTable),
+			fmt.Sprintf("DEFINE FIELD _fivetran_end ON %s TYPE option<datetime>", toTable),
+			fmt.Sprintf("DEFINE FIELD _fivetran_active ON %s TYPE option<bool>", toTable),
+		}
+		for _, fieldDef := range historyFields {
+			_, err := write[any](ctx, m, toTable, fieldDef, nil)
+			if err != nil {
+				return fmt.Errorf("failed to add history field: %w", err)
+			}
+		}
+
+		// The destination table copies the source's definition of scalar record IDs, if any,
+		// which is never used in history mode.
+		return m.useArrayRecordIDs(ctx, toTable)
+	})
+	if err != nil {
+		return err
 	}
 
-	// 3. Copy records with history mode transformation
+	// 2. Copy records with history mode transformation
 	var insertedFields string
 	if softDeletedColumn != "" {
 		// Source is soft-delete mode
@@ -100,8 +108,8 @@ func (m *Migrator) CopyTableToHistoryMode(ctx context.Context, schema, table, fr
 		insertedFields = "$now AS _fivetran_start, $end_max AS _fivetran_end, true AS _fivetran_active, *"
 	}
 
-	// ID transformation: [pk...] -> [pk..., _fivetran_start]
-	idExpression := "array::add(record::id(id), _fivetran_start)"
+	// ID transformation: [pk...] or pk -> [pk..., _fivetran_start]
+	idExpression := historyIDExpression
 
 	// Use BatchCopyRecordsWithNewIDs with additional variables for $now and $end_max
 	additionalVars := map[string]any{
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // DropColumn removes a column from non-history-mode tables.
@@ -16,14 +14,14 @@ import (
 func (m *Migrator) DropColumn(ctx context.Context, schema, table, column string) error {
 	// 1. Remove the field definition from the table schema
 	removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", column, table)
-	_, err := surrealdb.Query[any](ctx, m.db, removeQuery, nil)
+	_, err := write[any](ctx, m, table, removeQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to drop column %s from table %s: %w", column, table, err)
 	}
 
 	// 2. Remove the field values from all existing records
 	updateQuery := fmt.Sprintf("UPDATE %s UNSET %s", table, column)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, nil)
+	_, err = write[any](ctx, m, table, updateQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to remove values for column %s in table %s: %w", column, table, err)
 	}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -30,7 +29,7 @@ func (m *Migrator) DropColumnInHistoryMode(ctx context.Context, schema, table, c
 		Max *models.CustomDateTime `cbor:"max"`
 	}
 	maxQuery := fmt.Sprintf("SELECT time::max(_fivetran_start) AS max FROM %s WHERE _fivetran_active = true GROUP ALL", table)
-	maxResults, err := surrealdb.Query[[]MaxStartResult](ctx, m.db, maxQuery, nil)
+	maxResults, err := read[[]MaxStartResult](ctx, m, maxQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get max _fivetran_start from table %s: %w", table, err)
 	}
@@ -72,7 +71,7 @@ func (m *Migrator) DropColumnInHistoryMode(ctx context.Context, schema, table, c
 			*
 		FROM %s WHERE _fivetran_active = true AND %s IS NOT NONE
 	`, table, column, table, column)
-	_, err = surrealdb.Query[any](ctx, m.db, insertQuery, map[string]any{
+	_, err = write[any](ctx, m, table, insertQuery, map[string]any{
 		"operation_timestamp": operationTimestamp,
 	})
 	if err != nil {
@@ -86,7 +85,7 @@ func (m *Migrator) DropColumnInHistoryMode(ctx context.Context, schema, table, c
 			_fivetran_active = false
 		WHERE _fivetran_active = true AND %s IS NOT NONE
 	`, table, column)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, map[string]any{
+	_, err = write[any](ctx, m, table, updateQuery, map[string]any{
 		"end_time_prev": models.CustomDateTime{Time: endTimePrev},
 	})
 	if err != nil {
@@ -96,7 +95,7 @@ func (m *Migrator) DropColumnInHistoryMode(ctx context.Context, schema, table, c
 	// 5. Remove the field definition from the table schema
 	// This hides the column from DescribeTable results while preserving all historical values
 	removeFieldQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", column, table)
-	_, err = surrealdb.Query[any](ctx, m.db, removeFieldQuery, nil)
+	_, err = write[any](ctx, m, table, removeFieldQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to remove field definition for column %s: %w", column, err)
 	}
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // DropTable removes a table from the database.
@@ -15,7 +13,7 @@ import (
 // - Execute: DROP TABLE <schema.table>
 func (m *Migrator) DropTable(ctx context.Context, schema, table string) error {
 	removeQuery := fmt.Sprintf("REMOVE TABLE %s", table)
-	_, err := surrealdb.Query[any](ctx, m.db, removeQuery, nil)
+	_, err := write[any](ctx, m, table, removeQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to drop table %s: %w", table, err)
 	}
@@ -0,0 +1,147 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// Step is a statement a migration runs, as recorded in dry-run mode.
+type Step struct {
+	// Table is the table whose records the statement goes over
+	Table string
+	// Records is the number of records in Table when the step was planned,
+	// which bounds the number of records the statement changes
+	Records int
+	Query   string
+	Vars    map[string]any
+}
+
+// Plan is the statements a migration would run in order, as recorded in dry-run mode.
+type Plan struct {
+	Steps []Step
+
+	// records caches the number of records of each table, which never changes in dry-run mode
+	records map[string]int
+}
+
+// String returns the plan as a numbered list of statements, each with the number of records it may change.
+func (p *Plan) String() string {
+	if len(p.Steps) == 0 {
+		return "The migration changes nothing."
+	}
+
+	var b strings.Builder
+	for i, step := range p.Steps {
+		fmt.Fprintf(&b, "%d. [%s: %d records] %s", i+1, step.Table, step.Records, strings.Join(strings.Fields(step.Query), " "))
+		if len(step.Vars) > 0 {
+			fmt.Fprintf(&b, " with %v", step.Vars)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// read runs the query, which never changes the database, even in dry-run mode.
+func read[T any](ctx context.Context, m *Migrator, query string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	return tracing.Query[T](ctx, m.db, query, vars)
+}
+
+// write runs the query changing the database, whose records of the table bound what it changes.
+//
+// In dry-run mode, the query is recorded to the plan instead, and a single zero result is returned,
+// which the batch loops take as no more records to process, so that each batch query is planned once.
+func write[T any](ctx context.Context, m *Migrator, table, query string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	if m.plan == nil {
+		return tracing.Query[T](ctx, m.db, query, vars)
+	}
+
+	records, err := m.plan.countRecords(ctx, m.db, table)
+	if err != nil {
+		return nil, err
+	}
+	m.plan.Steps = append(m.plan.Steps, Step{Table: table, Records: records, Query: query, Vars: vars})
+
+	return &[]surrealdb.QueryResult[T]{{Status: "OK"}}, nil
+}
+
+// writeBatch is like write, but retries the query on transient failures with the retry policy of the Migrator.
+//
+// It is used by the batch loops selecting the records to process by a condition, whose queries are safe to run again:
+// each processes its batch atomically, and the records it processed no longer match the condition.
+func writeBatch[T any](ctx context.Context, m *Migrator, table, query string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	var results *[]surrealdb.QueryResult[T]
+	err := m.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = write[T](ctx, m, table, query, vars)
+		return err
+	})
+	return results, err
+}
+
+// writeCursorBatch is like writeBatch, for the batch loops inserting the records after the cursor $start_id,
+// whose queries fail on the records they already inserted when run again after committing.
+//
+// A batch whose connection dropped may have been committed or not, so it is only retried
+// in a journaled step, which saves its cursor in the transaction of each batch:
+// each retry starts after the saved cursor, which skips the batch if it was committed.
+// Otherwise, the batch is not retried.
+func writeCursorBatch[T any](ctx context.Context, m *Migrator, table, query string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	if m.journal == nil || m.journal.current == "" {
+		return write[T](ctx, m, table, query, vars)
+	}
+
+	vars = maps.Clone(vars)
+
+	var results *[]surrealdb.QueryResult[T]
+	var attempted bool
+	err := m.retry.Do(ctx, func(ctx context.Context) error {
+		if attempted {
+			cursor, err := m.savedCursor(ctx)
+			if err != nil {
+				return err
+			}
+			if cursor != nil {
+				vars["start_id"] = *cursor
+			}
+		}
+		attempted = true
+
+		var err error
+		results, err = write[T](ctx, m, table, query, vars)
+		return err
+	})
+	return results, err
+}
+
+// countRecords returns the number of records in the table, which is 0 if the table does not exist.
+func (p *Plan) countRecords(ctx context.Context, db *surrealdb.DB, table string) (int, error) {
+	if n, ok := p.records[table]; ok {
+		return n, nil
+	}
+
+	type CountResult struct {
+		Count int `cbor:"count"`
+	}
+	results, err := tracing.Query[[]CountResult](ctx, db, "SELECT count() AS count FROM type::table($tb) GROUP ALL", map[string]any{
+		"tb": table,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count records in %s: %w", table, err)
+	}
+
+	var n int
+	if results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
+		n = (*results)[0].Result[0].Count
+	}
+
+	if p.records == nil {
+		p.records = map[string]int{}
+	}
+	p.records[table] = n
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+)
+
+func TestPlan_String(t *testing.T) {
+	plan := &Plan{Steps: []Step{
+		{Table: "users", Records: 3, Query: "\n\t\tDELETE FROM users\n\t\tWHERE _fivetran_active = false\n\t"},
+		{Table: "users", Records: 3, Query: "UPDATE users SET name = $value", Vars: map[string]any{"value": "x"}},
+	}}
+
+	assert.Equal(t, "1. [users: 3 records] DELETE FROM users WHERE _fivetran_active = false\n"+
+		"2. [users: 3 records] UPDATE users SET name = $value with map[value:x]\n", plan.String())
+	assert.Equal(t, "The migration changes nothing.", (&Plan{}).String())
+}
+
+func TestDryRun_ModeHistoryToLive(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, m := testSetup(t, namespace)
+	dryRun := NewDryRun(db, m.Logging)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE products SCHEMAFULL;
+		DEFINE FIELD id ON products TYPE array<any>;
+		DEFINE FIELD name ON products TYPE option<string>;
+		DEFINE FIELD _fivetran_start ON products TYPE option<datetime>;
+		DEFINE FIELD _fivetran_end ON products TYPE option<datetime>;
+		DEFINE FIELD _fivetran_active ON products TYPE option<bool>;
+		CREATE products:['prod1', d'2024-01-01T00:00:00Z'] SET name = 'Active', _fivetran_start = d'2024-01-01T00:00:00Z', _fivetran_active = true;
+		CREATE products:['prod2', d'2024-01-01T00:00:00Z'] SET name = 'Deleted', _fivetran_start = d'2024-01-01T00:00:00Z', _fivetran_active = false;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	err = dryRun.ModeHistoryToLive(ctx, namespace, "products", false)
+	require.NoError(t, err)
+
+	// The plan starts with deleting the inactive records, and nothing is changed
+	plan := dryRun.Plan()
+	require.NotEmpty(t, plan.Steps)
+	assert.Equal(t, Step{Table: "products", Records: 2, Query: "DELETE FROM products WHERE _fivetran_active = false"}, plan.Steps[0])
+	assert.Nil(t, m.Plan(), "a migrator not in dry-run mode has no plan")
+
+	type CountResult struct {
+		Count int `cbor:"count"`
+	}
+	counts, err := surrealdb.Query[[]CountResult](ctx, db, "SELECT count() AS count FROM products GROUP ALL", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, (*counts)[0].Result[0].Count)
+}
@@ -0,0 +1,268 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// journalTable is the table of the database of each migrated table journaling the progress of its migration.
+const journalTable = "_fivetran_migration"
+
+// journal is the progress of the migration of a table, as recorded in the record of the table in journalTable,
+// so that re-running a migration interrupted midway resumes it instead of running its completed steps twice.
+type journal struct {
+	id          models.RecordID
+	table       string
+	operation   string
+	fingerprint string
+
+	// created is whether the journal record exists, which is created by the first step
+	// so that a migration failing before changing anything leaves no journal behind
+	created bool
+	// steps are the names of the completed steps
+	steps map[string]bool
+	// current is the name of the step being run
+	current string
+	// cursor is the ID of the last record the step named cursorStep processed before the interruption
+	cursor     *models.RecordID
+	cursorStep string
+}
+
+type journalRecord struct {
+	Operation   string         `cbor:"operation"`
+	Fingerprint string         `cbor:"fingerprint"`
+	Steps       []string       `cbor:"steps"`
+	Cursor      *journalCursor `cbor:"cursor,omitempty"`
+}
+
+type journalCursor struct {
+	Step string          `cbor:"step"`
+	ID   models.RecordID `cbor:"id"`
+}
+
+// BeginJournal makes the Migrator journal the progress of the migration of the table to journalTable,
+// resuming the migration from where it left off if a previous run of the same migration was interrupted.
+//
+// The operation names the migration in logs and errors, while the fingerprint identifies it,
+// so that only retrying the very same migration resumes it.
+// It fails if an interrupted migration of the table is another one, which has to be completed first.
+//
+// Nothing is journaled in dry-run mode.
+func (m *Migrator) BeginJournal(ctx context.Context, table, operation, fingerprint string) error {
+	if m.plan != nil {
+		return nil
+	}
+
+	j := &journal{
+		id:          models.NewRecordID(journalTable, table),
+		table:       table,
+		operation:   operation,
+		fingerprint: fingerprint,
+		steps:       map[string]bool{},
+	}
+
+	results, err := read[[]journalRecord](ctx, m, "SELECT * FROM $journal", map[string]any{
+		"journal": j.id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the migration journal of %s: %w", table, err)
+	}
+
+	if results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
+		rec := (*results)[0].Result[0]
+		if rec.Fingerprint != fingerprint {
+			return fmt.Errorf("table %s has an interrupted %s migration, which must be completed by retrying it before migrating the table again, "+
+				"or abandoned by deleting the record of the table in %s", table, rec.Operation, journalTable)
+		}
+
+		j.created = true
+		for _, step := range rec.Steps {
+			j.steps[step] = true
+		}
+		if rec.Cursor != nil {
+			id := rec.Cursor.ID
+			j.cursor = &id
+			j.cursorStep = rec.Cursor.Step
+		}
+
+		m.LogInfo("Resuming interrupted migration",
+			"table", table,
+			"operation", operation,
+			"completed_steps", len(rec.Steps),
+		)
+	}
+
+	m.journal = j
+	return nil
+}
+
+// EndJournal removes the journal of the completed migration.
+func (m *Migrator) EndJournal(ctx context.Context) error {
+	j := m.journal
+	m.journal = nil
+	if j == nil || !j.created {
+		return nil
+	}
+
+	if _, err := tracing.Query[any](ctx, m.db, "DELETE $journal", map[string]any{"journal": j.id}); err != nil {
+		return fmt.Errorf("failed to remove the migration journal of %s: %w", j.table, err)
+	}
+	return nil
+}
+
+// create writes the journal record unless it exists.
+func (j *journal) create(ctx context.Context, m *Migrator) error {
+	if j.created {
+		return nil
+	}
+
+	now := models.CustomDateTime{Time: time.Now().UTC()}
+	_, err := tracing.Query[any](ctx, m.db, fmt.Sprintf(`
+		DEFINE TABLE IF NOT EXISTS %s SCHEMALESS;
+		CREATE $journal CONTENT $content RETURN NONE;
+	`, journalTable), map[string]any{
+		"journal": j.id,
+		"content": map[string]any{
+			"table":       j.table,
+			"operation":   j.operation,
+			"fingerprint": j.fingerprint,
+			"steps":       []string{},
+			"saved":       []any{},
+			"started_at":  now,
+			"updated_at":  now,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the migration journal of %s: %w", j.table, err)
+	}
+
+	j.created = true
+	return nil
+}
+
+// step runs the named step of the migration, unless the journal records it as completed by an interrupted run.
+//
+// A step is skipped once completed, but is run again from the start if it was interrupted midway,
+// so each step must either be atomic, or be able to continue the work of the interrupted run,
+// like the batch loops moving the remaining records, or paginating from the journaled cursor.
+func (m *Migrator) step(ctx context.Context, name string, run func() error) error {
+	j := m.journal
+	if j == nil {
+		return run()
+	}
+
+	if j.steps[name] {
+		m.LogInfo("Skipping migration step completed before the interruption",
+			"table", j.table,
+			"step", name,
+		)
+		return nil
+	}
+
+	if err := j.create(ctx, m); err != nil {
+		return err
+	}
+
+	parent := j.current
+	j.current = name
+	err := run()
+	j.current = parent
+	if err != nil {
+		return err
+	}
+
+	_, err = tracing.Query[any](ctx, m.db, "UPDATE $journal SET steps += $step, cursor = NONE, updated_at = time::now() RETURN NONE", map[string]any{
+		"journal": j.id,
+		"step":    name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to journal migration step %q of %s: %w", name, j.table, err)
+	}
+	j.steps[name] = true
+	j.cursor = nil
+
+	return nil
+}
+
+// recall returns the value saved under the name by an interrupted run of the migration, if any,
+// or saves v otherwise, so that a resumed migration uses the same values as the interrupted run,
+// like the timestamp written to the records.
+func recall[T any](ctx context.Context, m *Migrator, name string, v T) (T, error) {
+	j := m.journal
+	if j == nil {
+		return v, nil
+	}
+
+	if err := j.create(ctx, m); err != nil {
+		return v, err
+	}
+
+	vars := map[string]any{
+		"journal": j.id,
+		"name":    name,
+	}
+	results, err := tracing.Query[[]T](ctx, m.db, "RETURN (SELECT VALUE saved FROM ONLY $journal)[WHERE name = $name].data", vars)
+	if err != nil {
+		return v, fmt.Errorf("failed to read %s from the migration journal of %s: %w", name, j.table, err)
+	}
+	if results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
+		return (*results)[0].Result[0], nil
+	}
+
+	vars["data"] = v
+	if _, err := tracing.Query[any](ctx, m.db, "UPDATE $journal SET saved += { name: $name, data: $data }, updated_at = time::now() RETURN NONE", vars); err != nil {
+		return v, fmt.Errorf("failed to save %s to the migration journal of %s: %w", name, j.table, err)
+	}
+	return v, nil
+}
+
+// cursor returns the ID of the last record the current step processed before the interruption, if any,
+// along with a statement to run in the transaction of each batch of the step,
+// which saves the ID of the last record of $selected as the cursor.
+//
+// The variables of the statement are added to vars.
+// The statement is empty if the migration is not journaled.
+func (m *Migrator) cursor(vars map[string]any) (*models.RecordID, string) {
+	j := m.journal
+	if j == nil || j.current == "" {
+		return nil, ""
+	}
+
+	vars["journal"] = j.id
+	vars["journal_step"] = j.current
+
+	var resumed *models.RecordID
+	if j.cursor != nil && j.cursorStep == j.current {
+		resumed = j.cursor
+	}
+	return resumed, `IF array::len($selected) > 0 {
+			UPDATE $journal SET cursor = { step: $journal_step, id: array::last($selected).id }, updated_at = time::now() RETURN NONE;
+		};`
+}
+
+// savedCursor returns the ID of the last record the current step processed, as saved by its last committed batch, if any.
+//
+// Unlike the cursor of an interrupted run, it tells whether a batch whose connection dropped before it responded was committed.
+func (m *Migrator) savedCursor(ctx context.Context) (*models.RecordID, error) {
+	j := m.journal
+
+	results, err := read[*journalCursor](ctx, m, "SELECT VALUE cursor FROM ONLY $journal", map[string]any{
+		"journal": j.id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cursor from the migration journal of %s: %w", j.table, err)
+	}
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+
+	cursor := (*results)[0].Result
+	if cursor == nil || cursor.Step != j.current {
+		return nil, nil
+	}
+	return &cursor.ID, nil
+}
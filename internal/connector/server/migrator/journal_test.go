@@ -0,0 +1,175 @@
+package migrator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestJournal_ResumesBatchUpdateIDs(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	// Simulate a run interrupted after moving 2 of the 5 records to the temp table with new IDs
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE items SCHEMAFULL;
+		DEFINE FIELD value ON items TYPE option<int>;
+		DEFINE TABLE _temp_items SCHEMAFULL;
+		DEFINE FIELD value ON _temp_items TYPE option<int>;
+		CREATE items:1 SET value = 10;
+		CREATE items:2 SET value = 20;
+		CREATE items:3 SET value = 30;
+		CREATE _temp_items:v2_4 SET value = 40;
+		CREATE _temp_items:v2_5 SET value = 50;
+		CREATE _fivetran_migration:items CONTENT {
+			operation: 'test',
+			fingerprint: 'f1',
+			steps: ['create _temp_items'],
+			saved: []
+		};
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	require.NoError(t, migrator.BeginJournal(ctx, "items", "test", "f1"))
+	err = migrator.BatchUpdateIDs(ctx, "items", "*", `"v2_" + <string>record::id(id)`, "value", 2, nil)
+	require.NoError(t, err, "BatchUpdateIDs failed")
+	require.NoError(t, migrator.EndJournal(ctx))
+
+	// Every record has its ID updated exactly once
+	results, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM items ORDER BY value", nil)
+	require.NoError(t, err)
+	records := (*results)[0].Result
+	require.Len(t, records, 5)
+	for i, record := range records {
+		assert.Equal(t, models.NewRecordID("items", fmt.Sprintf("v2_%d", i+1)), record["id"])
+	}
+
+	journals, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM _fivetran_migration", nil)
+	require.NoError(t, err)
+	assert.Empty(t, (*journals)[0].Result, "the journal of a completed migration is removed")
+}
+
+func TestJournal_ResumesBatchCopyRecordsWithNewIDs(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	// Simulate a run interrupted after copying the first 2 records
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE source SCHEMALESS;
+		DEFINE TABLE dest SCHEMALESS;
+		CREATE source:1 SET value = 10;
+		CREATE source:2 SET value = 20;
+		CREATE source:3 SET value = 30;
+		CREATE dest:[1] SET value = 10;
+		CREATE dest:[2] SET value = 20;
+		CREATE _fivetran_migration:source CONTENT {
+			operation: 'test',
+			fingerprint: 'f1',
+			steps: [],
+			saved: [],
+			cursor: { step: 'copy source to dest', id: source:2 }
+		};
+	`, nil)
+	require.NoError(t, err, "Failed to create tables")
+
+	require.NoError(t, migrator.BeginJournal(ctx, "source", "test", "f1"))
+	err = migrator.BatchCopyRecordsWithNewIDs(ctx, "source", "*", "dest", "[record::id(id)]", "*", 1, nil)
+	require.NoError(t, err, "copying the copied records again would fail on their existing IDs")
+	require.NoError(t, migrator.EndJournal(ctx))
+
+	type CountResult struct {
+		Count int `cbor:"count"`
+	}
+	counts, err := surrealdb.Query[[]CountResult](ctx, db, "SELECT count() AS count FROM dest GROUP ALL", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, (*counts)[0].Result[0].Count)
+}
+
+func TestJournal_SavedCursor(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE source SCHEMALESS;
+		CREATE source:a SET value = 10;
+		CREATE source:b SET value = 20;
+		CREATE source:c SET value = 30;
+		DEFINE TABLE dest SCHEMALESS;
+	`, nil)
+	require.NoError(t, err, "Failed to create tables")
+
+	require.NoError(t, migrator.BeginJournal(ctx, "source", "test", "f1"))
+	require.NoError(t, migrator.step(ctx, "copy", func() error {
+		cursor, err := migrator.savedCursor(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, cursor, "no batch of the step was committed yet")
+
+		// A batch committed right before its connection dropped saves the cursor a retry starts after
+		require.NoError(t, migrator.batchCopyRecordsWithNewIDs(ctx, "source", "*", "dest", "[record::id(id)]", "*", 2, nil))
+
+		cursor, err = migrator.savedCursor(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, cursor)
+		assert.Equal(t, models.NewRecordID("source", "c"), *cursor)
+		return nil
+	}))
+	require.NoError(t, migrator.EndJournal(ctx))
+}
+
+func TestJournal_Recall(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	saved := models.CustomDateTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	require.NoError(t, migrator.BeginJournal(ctx, "items", "test", "f1"))
+	v, err := recall(ctx, migrator, "now", saved)
+	require.NoError(t, err)
+	assert.Equal(t, saved, v)
+
+	// The migration is interrupted, and a retry recalls the value of the interrupted run
+	resumed := New(db, migrator.Logging)
+	require.NoError(t, resumed.BeginJournal(ctx, "items", "test", "f1"))
+	v, err = recall(ctx, resumed, "now", models.CustomDateTime{Time: time.Now().UTC()})
+	require.NoError(t, err)
+	assert.True(t, saved.Equal(v.Time), "got %v", v)
+}
+
+func TestJournal_AnotherMigrationInterrupted(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	require.NoError(t, migrator.BeginJournal(ctx, "items", "TableSyncModeMigration", "f1"))
+	require.NoError(t, migrator.step(ctx, "step", func() error { return nil }))
+
+	other := New(db, migrator.Logging)
+	err := other.BeginJournal(ctx, "items", "Drop", "f2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table items has an interrupted TableSyncModeMigration migration")
+
+	// Retrying the interrupted migration skips its completed steps
+	resumed := New(db, migrator.Logging)
+	require.NoError(t, resumed.BeginJournal(ctx, "items", "TableSyncModeMigration", "f1"))
+	require.NoError(t, resumed.step(ctx, "step", func() error {
+		t.Fatal("a completed step is run again")
+		return nil
+	}))
+	require.NoError(t, resumed.EndJournal(ctx))
+
+	require.NoError(t, other.BeginJournal(ctx, "items", "Drop", "f2"))
+}
@@ -1,13 +1,28 @@
 package migrator
 
 import (
+	"context"
+	"fmt"
+	"math"
+
 	"github.com/surrealdb/fivetran-destination/internal/connector/log"
+	"github.com/surrealdb/fivetran-destination/internal/connector/retry"
 	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
 type Migrator struct {
 	db *surrealdb.DB
 
+	// plan records the statements changing the database instead of running them in dry-run mode
+	plan *Plan
+
+	// journal records the progress of the migration, if journaled, so that it can be resumed once interrupted
+	journal *journal
+
+	// retry is how the batch queries are retried on transient failures, which is never by default
+	retry retry.Policy
+
 	*log.Logging
 }
 
@@ -17,3 +32,80 @@ func New(db *surrealdb.DB, logger *log.Logging) *Migrator {
 		Logging: logger,
 	}
 }
+
+// NewDryRun returns a Migrator that only reads the database,
+// and records the statements that would change it to the plan returned by Plan.
+func NewDryRun(db *surrealdb.DB, logger *log.Logging) *Migrator {
+	return &Migrator{
+		db:      db,
+		plan:    &Plan{},
+		Logging: logger,
+	}
+}
+
+// WithRetry makes the Migrator retry the queries of its batch loops on transient failures,
+// like transaction conflicts, with the policy, so that a conflict does not fail a long migration.
+func (m *Migrator) WithRetry(p retry.Policy) *Migrator {
+	m.retry = p
+	return m
+}
+
+// Plan returns the statements recorded in dry-run mode, or nil if the Migrator is not in dry-run mode.
+func (m *Migrator) Plan() *Plan {
+	return m.plan
+}
+
+// firstRecordID returns the record ID that paginating over the table with `id > $start_id` starts from.
+//
+// SurrealDB orders record IDs by their kind first, integers coming before strings and arrays,
+// so that it precedes both the array record IDs and the scalar record IDs of the table.
+func firstRecordID(table string) models.RecordID {
+	return models.NewRecordID(table, int64(math.MinInt64))
+}
+
+// repeatBatch runs the query, which processes up to $batch_size records and returns
+// the number of processed records, until no records are processed.
+// Each run is a separate transaction to keep WAL entries small.
+//
+// op is the name of the operation, like "delete" or "update", used in logs and errors.
+// It returns the total number of processed records.
+func (m *Migrator) repeatBatch(ctx context.Context, op, table, query string, batchSize int, additionalVars map[string]any) (int, error) {
+	// Build query parameters by merging additionalVars with batch_size
+	queryParams := map[string]any{
+		"batch_size": batchSize,
+	}
+	for k, v := range additionalVars {
+		queryParams[k] = v
+	}
+
+	var total int
+	for {
+		results, err := writeBatch[int](ctx, m, table, query, queryParams)
+		if err != nil {
+			return total, fmt.Errorf("batch %s failed: %w", op, err)
+		}
+
+		// Check results are valid - should always have exactly 1 result
+		if results == nil {
+			return total, fmt.Errorf("unexpected nil results during batch %s of %s", op, table)
+		}
+		if len(*results) != 1 {
+			return total, fmt.Errorf("unexpected result count %d during batch %s of %s, expected 1", len(*results), op, table)
+		}
+
+		n := (*results)[0].Result
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+
+		if m.Debugging() {
+			m.LogDebug("Batch processed records",
+				"operation", op,
+				"table", table,
+				"batch", n,
+				"total", total,
+			)
+		}
+	}
+}
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // ModeHistoryToLive converts a history-mode table back to live mode.
@@ -25,19 +23,18 @@ func (m *Migrator) ModeHistoryToLive(ctx context.Context, schema, table string,
 	// When converting to live mode, both would map to the same ID [pk1, pk2] which is not allowed.
 	// Therefore, we must remove the inactive records to maintain unique IDs in live mode.
 	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE _fivetran_active = false", table)
-	_, err := surrealdb.Query[any](ctx, m.db, deleteQuery, nil)
+	err := m.step(ctx, "delete inactive records", func() error {
+		_, err := write[any](ctx, m, table, deleteQuery, nil)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete inactive records from %s: %w", table, err)
 	}
 	// }
 
 	// 2. Remove history mode field definitions from schema
-	for _, field := range []string{"_fivetran_start", "_fivetran_end", "_fivetran_active"} {
-		removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", field, table)
-		_, err := surrealdb.Query[any](ctx, m.db, removeQuery, nil)
-		if err != nil {
-			return fmt.Errorf("failed to remove field %s from %s: %w", field, table, err)
-		}
+	if err := m.step(ctx, "remove history fields", func() error { return m.removeHistoryFields(ctx, table) }); err != nil {
+		return err
 	}
 
 	// 3. Update IDs to remove _fivetran_start component and omit history fields from data
@@ -55,3 +52,15 @@ func (m *Migrator) ModeHistoryToLive(ctx context.Context, schema, table string,
 
 	return nil
 }
+
+// removeHistoryFields removes the definitions of the history mode fields from the table.
+func (m *Migrator) removeHistoryFields(ctx context.Context, table string) error {
+	for _, field := range []string{"_fivetran_start", "_fivetran_end", "_fivetran_active"} {
+		removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", field, table)
+		_, err := write[any](ctx, m, table, removeQuery, nil)
+		if err != nil {
+			return fmt.Errorf("failed to remove field %s from %s: %w", field, table, err)
+		}
+	}
+	return nil
+}
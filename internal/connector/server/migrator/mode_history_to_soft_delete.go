@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -71,13 +70,48 @@ func (m *Migrator) ModeHistoryToSoftDelete(ctx context.Context, schema, table, s
 
 	// 1. Add soft delete column
 	defineFieldQuery := fmt.Sprintf("DEFINE FIELD %s ON %s TYPE option<bool>", softDeletedColumn, table)
-	_, err := surrealdb.Query[any](ctx, m.db, defineFieldQuery, nil)
+	err := m.step(ctx, "define soft delete column", func() error {
+		_, err := write[any](ctx, m, table, defineFieldQuery, nil)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to add soft delete column %s: %w", softDeletedColumn, err)
 	}
 
 	// 2. Delete historical versions (non-latest) in batches
 	// For each primary key, keep only the record with the highest _fivetran_start
+	if err := m.step(ctx, "delete historical versions", func() error {
+		return m.deleteHistoricalVersions(ctx, table, batchSize, maxIterations)
+	}); err != nil {
+		return err
+	}
+
+	// 3. Remove history mode field definitions from schema
+	if err := m.step(ctx, "remove history fields", func() error { return m.removeHistoryFields(ctx, table) }); err != nil {
+		return err
+	}
+
+	// 4. Update IDs to remove _fivetran_start component and convert _fivetran_active to soft delete column
+	// ID transformation: [pk1, pk2, ..., _fivetran_start] -> [pk1, pk2, ...]
+	// Also: softDeletedColumn = NOT _fivetran_active
+	idExpression := "array::slice(record::id(id), 0, array::len(record::id(id)) - 1)"
+	insertedFields := fmt.Sprintf("NOT(_fivetran_active) AS %s, * OMIT _fivetran_start, _fivetran_end, _fivetran_active", softDeletedColumn)
+
+	err = m.BatchUpdateIDs(ctx, table, "*", idExpression, insertedFields, batchSize, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update record IDs: %w", err)
+	}
+
+	m.LogInfo("Converted table from history to soft delete mode",
+		"table", table,
+		"soft_delete_column", softDeletedColumn,
+	)
+
+	return nil
+}
+
+// deleteHistoricalVersions deletes all but the latest version of each record of the history mode table in batches.
+func (m *Migrator) deleteHistoricalVersions(ctx context.Context, table string, batchSize, maxIterations int) error {
 	deleteQuery := fmt.Sprintf(`
 		BEGIN;
 		LET $res = SELECT
@@ -117,7 +151,7 @@ func (m *Migrator) ModeHistoryToSoftDelete(ctx context.Context, schema, table, s
 	type CountResult struct {
 		Count int `cbor:"count"`
 	}
-	countResults, err := surrealdb.Query[[]CountResult](ctx, m.db, fmt.Sprintf("SELECT count() AS count FROM %s GROUP ALL", table), nil)
+	countResults, err := read[[]CountResult](ctx, m, fmt.Sprintf("SELECT count() AS count FROM %s GROUP ALL", table), nil)
 	if err != nil {
 		return fmt.Errorf("failed to count records in %s: %w", table, err)
 	}
@@ -134,7 +168,7 @@ func (m *Migrator) ModeHistoryToSoftDelete(ctx context.Context, schema, table, s
 			ToDelete    []map[string]any `cbor:"to_delete"`
 			Deleted     []map[string]any `cbor:"deleted"`
 		}
-		results, err := surrealdb.Query[DeleteResult](ctx, m.db, deleteQuery, map[string]any{
+		results, err := writeBatch[DeleteResult](ctx, m, table, deleteQuery, map[string]any{
 			"start_id":   startID,
 			"batch_size": batchSize,
 		})
@@ -183,30 +217,5 @@ func (m *Migrator) ModeHistoryToSoftDelete(ctx context.Context, schema, table, s
 		return fmt.Errorf("exceeded maximum iterations (%d) while deleting historical versions from %s", maxIterations, table)
 	}
 
-	// 3. Remove history mode field definitions from schema
-	for _, field := range []string{"_fivetran_start", "_fivetran_end", "_fivetran_active"} {
-		removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", field, table)
-		_, err := surrealdb.Query[any](ctx, m.db, removeQuery, nil)
-		if err != nil {
-			return fmt.Errorf("failed to remove field %s from %s: %w", field, table, err)
-		}
-	}
-
-	// 4. Update IDs to remove _fivetran_start component and convert _fivetran_active to soft delete column
-	// ID transformation: [pk1, pk2, ..., _fivetran_start] -> [pk1, pk2, ...]
-	// Also: softDeletedColumn = NOT _fivetran_active
-	idExpression := "array::slice(record::id(id), 0, array::len(record::id(id)) - 1)"
-	insertedFields := fmt.Sprintf("NOT(_fivetran_active) AS %s, * OMIT _fivetran_start, _fivetran_end, _fivetran_active", softDeletedColumn)
-
-	err = m.BatchUpdateIDs(ctx, table, "*", idExpression, insertedFields, batchSize, nil)
-	if err != nil {
-		return fmt.Errorf("failed to update record IDs: %w", err)
-	}
-
-	m.LogInfo("Converted table from history to soft delete mode",
-		"table", table,
-		"soft_delete_column", softDeletedColumn,
-	)
-
 	return nil
 }
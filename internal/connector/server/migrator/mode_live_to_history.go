@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -30,11 +29,16 @@ func (m *Migrator) ModeLiveToHistory(ctx context.Context, schema, table string)
 		fmt.Sprintf("DEFINE FIELD _fivetran_end ON %s TYPE option<datetime>", table),
 		fmt.Sprintf("DEFINE FIELD _fivetran_active ON %s TYPE option<bool>", table),
 	}
-	for _, fieldDef := range historyFields {
-		_, err := surrealdb.Query[any](ctx, m.db, fieldDef, nil)
-		if err != nil {
-			return fmt.Errorf("failed to add history field: %w", err)
+	err := m.step(ctx, "define history fields", func() error {
+		for _, fieldDef := range historyFields {
+			if _, err := write[any](ctx, m, table, fieldDef, nil); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add history field: %w", err)
 	}
 
 	// 2. Initialize all existing records with history values
@@ -42,17 +46,23 @@ func (m *Migrator) ModeLiveToHistory(ctx context.Context, schema, table string)
 		_fivetran_start = $now,
 		_fivetran_end = $end_max,
 		_fivetran_active = true`, table)
-	_, err := surrealdb.Query[any](ctx, m.db, updateQuery, map[string]any{
-		"now":     now,
-		"end_max": endTimeMax,
+	err = m.step(ctx, "initialize history fields", func() error {
+		_, err := write[any](ctx, m, table, updateQuery, map[string]any{
+			"now":     now,
+			"end_max": endTimeMax,
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize history fields: %w", err)
 	}
 
 	// 3. Update IDs to include _fivetran_start component
-	// ID transformation: [pk1, pk2, ...] -> [pk1, pk2, ..., _fivetran_start]
-	idExpression := "array::add(record::id(id), _fivetran_start)"
+	// ID transformation: [pk1, pk2, ...] or pk1 -> [pk1, pk2, ..., _fivetran_start]
+	if err := m.useArrayRecordIDs(ctx, table); err != nil {
+		return err
+	}
+	idExpression := historyIDExpression
 	insertedFields := "*"
 
 	err = m.BatchUpdateIDs(ctx, table, "*", idExpression, insertedFields, batchSize, nil)
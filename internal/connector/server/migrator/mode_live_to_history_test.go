@@ -235,3 +235,45 @@ func TestModeLiveToHistory_MultipleRecords(t *testing.T) {
 		assert.Len(t, idArr, 2, "Record %d ID should have 2 elements", i)
 	}
 }
+
+func TestModeLiveToHistory_ScalarRecordIDs(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	// Create live-mode table with scalar IDs, like the connector does with record_ids=scalar
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE scalar_users SCHEMAFULL;
+		DEFINE FIELD id ON scalar_users TYPE int COMMENT '{"ft_index":0,"ft_data_type":3,"ft_primary_key":true,"scalar_record_id":true}';
+		DEFINE FIELD name ON scalar_users TYPE option<string> COMMENT '{"ft_index":1,"ft_data_type":13,"ft_primary_key":false}';
+		CREATE scalar_users:1 SET name = 'Alice';
+		CREATE scalar_users:2 SET name = 'Bob';
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	err = migrator.ModeLiveToHistory(ctx, namespace, "scalar_users")
+	require.NoError(t, err, "ModeLiveToHistory failed")
+
+	results, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM scalar_users ORDER BY id", nil)
+	require.NoError(t, err)
+	require.NotNil(t, results)
+	records := (*results)[0].Result
+	require.Len(t, records, 2, "Expected 2 records")
+
+	// Verify the scalar IDs became [pk, _fivetran_start]
+	id0Arr := records[0]["id"].(models.RecordID).ID.([]any)
+	assert.Len(t, id0Arr, 2, "ID should have 2 elements [pk, _fivetran_start]")
+	assert.Equal(t, uint64(1), id0Arr[0])
+	assert.Equal(t, "Alice", records[0]["name"])
+
+	// Verify the table is no longer defined to use scalar record IDs
+	type InfoForTableResult struct {
+		Fields map[string]string `cbor:"fields"`
+	}
+	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, db, "INFO FOR TABLE scalar_users", nil)
+	require.NoError(t, err)
+	idField := (*infoResults)[0].Result.Fields["id"]
+	assert.Contains(t, idField, "array<any>")
+	assert.NotContains(t, idField, "scalar_record_id")
+}
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // ModeLiveToSoftDelete converts a live-mode table to soft-delete mode.
@@ -15,7 +13,7 @@ import (
 func (m *Migrator) ModeLiveToSoftDelete(ctx context.Context, schema, table, softDeletedColumn string) error {
 	// 1. Add soft delete column with option<bool> for compatibility between modes
 	defineFieldQuery := fmt.Sprintf("DEFINE FIELD %s ON %s TYPE option<bool>", softDeletedColumn, table)
-	_, err := surrealdb.Query[any](ctx, m.db, defineFieldQuery, nil)
+	_, err := write[any](ctx, m, table, defineFieldQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to add soft delete column %s to table %s: %w", softDeletedColumn, table, err)
 	}
@@ -24,7 +22,7 @@ func (m *Migrator) ModeLiveToSoftDelete(ctx context.Context, schema, table, soft
 	// Using parameterized query for the table name to prevent SQL injection
 	// Note: Field name in SET clause must be literal, not parameterized
 	updateQuery := fmt.Sprintf("UPDATE type::table($tb) SET %s = false", softDeletedColumn)
-	_, err = surrealdb.Query[any](ctx, m.db, updateQuery, map[string]any{
+	_, err = write[any](ctx, m, table, updateQuery, map[string]any{
 		"tb": table,
 	})
 	if err != nil {
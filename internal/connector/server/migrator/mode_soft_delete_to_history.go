@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
@@ -26,20 +25,28 @@ func (m *Migrator) ModeSoftDeleteToHistory(ctx context.Context, schema, table, s
 		Max *models.CustomDateTime `cbor:"max"`
 	}
 	maxQuery := fmt.Sprintf("SELECT time::max(_fivetran_synced) AS max FROM %s GROUP ALL", table)
-	maxResults, err := surrealdb.Query[[]MaxResult](ctx, m.db, maxQuery, nil)
+	maxResults, err := read[[]MaxResult](ctx, m, maxQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get max _fivetran_synced from table %s: %w", table, err)
 	}
 
 	// Check if we have records with valid _fivetran_synced values
+	var maxPtr *models.CustomDateTime
+	if maxResults != nil && len(*maxResults) > 0 && len((*maxResults)[0].Result) > 0 {
+		maxPtr = (*maxResults)[0].Result[0].Max
+	}
+	// A resumed migration uses the max of the interrupted run,
+	// as some records may be in the temp table of BatchUpdateIDs by now
+	maxPtr, err = recall(ctx, m, "max_fivetran_synced", maxPtr)
+	if err != nil {
+		return err
+	}
+
 	var maxFivetranSynced models.CustomDateTime
 	hasRecordsToUpdate := false
-	if maxResults != nil && len(*maxResults) > 0 && len((*maxResults)[0].Result) > 0 {
-		maxPtr := (*maxResults)[0].Result[0].Max
-		if maxPtr != nil {
-			maxFivetranSynced = *maxPtr
-			hasRecordsToUpdate = true
-		}
+	if maxPtr != nil {
+		maxFivetranSynced = *maxPtr
+		hasRecordsToUpdate = true
 	}
 
 	// 2. Add history mode field definitions
@@ -48,7 +55,10 @@ func (m *Migrator) ModeSoftDeleteToHistory(ctx context.Context, schema, table, s
 		DEFINE FIELD _fivetran_end ON %s TYPE option<datetime>;
 		DEFINE FIELD _fivetran_active ON %s TYPE option<bool>;
 	`, table, table, table)
-	_, err = surrealdb.Query[any](ctx, m.db, defineFieldsQuery, nil)
+	err = m.step(ctx, "define history fields", func() error {
+		_, err := write[any](ctx, m, table, defineFieldsQuery, nil)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to define history mode fields on table %s: %w", table, err)
 	}
@@ -56,17 +66,23 @@ func (m *Migrator) ModeSoftDeleteToHistory(ctx context.Context, schema, table, s
 	// 3. Remove the soft delete column definition before transforming records
 	// This must be done before BatchUpdateIDs to avoid schema validation errors
 	removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", softDeletedColumn, table)
-	_, err = surrealdb.Query[any](ctx, m.db, removeQuery, nil)
+	err = m.step(ctx, "remove soft delete column", func() error {
+		_, err := write[any](ctx, m, table, removeQuery, nil)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to remove soft delete column %s from table %s: %w", softDeletedColumn, table, err)
 	}
 
 	// 4. Transform IDs and set history mode fields based on soft delete status
 	// Only run if there are records to update
+	if err := m.useArrayRecordIDs(ctx, table); err != nil {
+		return err
+	}
 	if hasRecordsToUpdate {
 		const batchSize = 1000
-		// ID transformation: [pk...] -> [pk..., _fivetran_start]
-		idExpression := "array::add(record::id(id), _fivetran_start)"
+		// ID transformation: [pk...] or pk -> [pk..., _fivetran_start]
+		idExpression := historyIDExpression
 
 		// Set history mode fields based on soft delete status, omit soft delete column
 		// For deleted records: start=0001-01-01, end=0001-01-01, active=false
@@ -3,8 +3,6 @@ package migrator
 import (
 	"context"
 	"fmt"
-
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // ModeSoftDeleteToLive converts a soft-delete mode table to live mode.
@@ -15,7 +13,7 @@ import (
 func (m *Migrator) ModeSoftDeleteToLive(ctx context.Context, schema, table, softDeletedColumn string) error {
 	// 1. Delete all soft-deleted records (where softDeletedColumn = true)
 	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %s = true", table, softDeletedColumn)
-	_, err := surrealdb.Query[any](ctx, m.db, deleteQuery, nil)
+	_, err := write[any](ctx, m, table, deleteQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete soft-deleted records from %s: %w", table, err)
 	}
@@ -29,14 +27,14 @@ func (m *Migrator) ModeSoftDeleteToLive(ctx context.Context, schema, table, soft
 	type InfoForTableResult struct {
 		Fields map[string]string `cbor:"fields"`
 	}
-	infoResults, err := surrealdb.Query[InfoForTableResult](ctx, m.db, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
+	infoResults, err := read[InfoForTableResult](ctx, m, fmt.Sprintf("INFO FOR TABLE %s", table), nil)
 	if err != nil {
 		return fmt.Errorf("failed to get table info for %s: %w", table, err)
 	}
 
 	if _, ok := (*infoResults)[0].Result.Fields[softDeletedColumn]; softDeletedColumn == "_fivetran_deleted" && ok {
 		removeQuery := fmt.Sprintf("REMOVE FIELD %s ON %s", softDeletedColumn, table)
-		_, err = surrealdb.Query[any](ctx, m.db, removeQuery, nil)
+		_, err = write[any](ctx, m, table, removeQuery, nil)
 		if err != nil {
 			return fmt.Errorf("failed to remove soft delete column %s from table %s: %w", softDeletedColumn, table, err)
 		}
@@ -45,7 +43,7 @@ func (m *Migrator) ModeSoftDeleteToLive(ctx context.Context, schema, table, soft
 	// 3. Update all remaining records to unset the soft delete column data
 	// Now that the field is not in the schema, we can safely unset it
 	unsetQuery := fmt.Sprintf("UPDATE %s UNSET %s", table, softDeletedColumn)
-	_, err = surrealdb.Query[any](ctx, m.db, unsetQuery, nil)
+	_, err = write[any](ctx, m, table, unsetQuery, nil)
 	if err != nil {
 		return fmt.Errorf("failed to unset soft delete column %s data: %w", softDeletedColumn, err)
 	}
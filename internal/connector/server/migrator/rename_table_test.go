@@ -268,3 +268,45 @@ func TestRenameTable_LargeDataset(t *testing.T) {
 	records := (*results)[0].Result
 	assert.Len(t, records, 100, "Expected 100 records in new table")
 }
+
+func TestRenameTable_WithTableDefinitionAndEvents(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE audit SCHEMALESS;
+		DEFINE TABLE orders SCHEMAFULL CHANGEFEED 1d COMMENT 'customer orders';
+		DEFINE FIELD total ON orders TYPE number;
+		DEFINE EVENT order_created ON orders WHEN $event = 'CREATE' THEN (CREATE audit SET order = $after.id);
+		CREATE orders:1 SET total = 10;
+		CREATE orders:2 SET total = 20;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	err = migrator.RenameTable(ctx, namespace, "", "orders", "purchases")
+	require.NoError(t, err, "RenameTable failed")
+
+	type DBInfo struct {
+		Tables map[string]string `cbor:"tables"`
+	}
+	dbInfo, err := surrealdb.Query[DBInfo](ctx, db, "INFO FOR DB", nil)
+	require.NoError(t, err)
+	tables := (*dbInfo)[0].Result.Tables
+	assert.NotContains(t, tables, "orders", "the old table is removed once the copy is verified")
+	assert.Contains(t, tables["purchases"], "CHANGEFEED 1d")
+	assert.Contains(t, tables["purchases"], "COMMENT 'customer orders'")
+
+	type InfoForTableResult struct {
+		Events map[string]string `cbor:"events"`
+	}
+	tableInfo, err := surrealdb.Query[InfoForTableResult](ctx, db, "INFO FOR TABLE purchases", nil)
+	require.NoError(t, err)
+	assert.Contains(t, (*tableInfo)[0].Result.Events, "order_created")
+
+	// The event is defined after the records are copied, so it never fires for them
+	audit, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM audit", nil)
+	require.NoError(t, err)
+	assert.Len(t, (*audit)[0].Result, 2, "only the records created in orders are audited")
+}
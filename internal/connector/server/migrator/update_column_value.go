@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
-	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
 // UpdateColumnValue updates all values in a specified column with a new value.
@@ -26,6 +25,9 @@ func (m *Migrator) UpdateColumnValue(ctx context.Context, schema, table, column,
 	if err != nil {
 		return fmt.Errorf("UpdateColumnValue: failed to get table info for %s: %w", table, err)
 	}
+	if err := tm.ResolveLinks(ctx, table, &tableInfo); err != nil {
+		return fmt.Errorf("UpdateColumnValue: %w", err)
+	}
 
 	var columnInfo *tablemapper.ColumnInfo
 	for _, col := range tableInfo.Columns {
@@ -56,7 +58,7 @@ func (m *Migrator) UpdateColumnValue(ctx context.Context, schema, table, column,
 		}
 	}
 
-	_, err = surrealdb.Query[[]map[string]any](ctx, m.db, query, params)
+	_, err = write[[]map[string]any](ctx, m, table, query, params)
 	if err != nil {
 		return fmt.Errorf("UpdateColumnValue: failed to update column value: %w", err)
 	}
@@ -0,0 +1,75 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+)
+
+// verifySampleSize is the number of records whose contents VerifyCopy compares.
+const verifySampleSize = 1000
+
+// tableSummary is the number of records of a table, and the checksum of a sample of its records.
+type tableSummary struct {
+	Count    int    `cbor:"count"`
+	Checksum string `cbor:"checksum"`
+}
+
+// VerifyCopy checks that toTable has the same records as fromTable, which it was copied from,
+// by comparing the numbers of records of the tables, and the checksums of their first verifySampleSize records.
+//
+// The records are compared with their IDs stripped of the table name, so that the same records are
+// equal in both tables. Nothing is verified in dry-run mode, where nothing is copied.
+func (m *Migrator) VerifyCopy(ctx context.Context, fromTable, toTable string) error {
+	if m.plan != nil {
+		return nil
+	}
+
+	from, err := m.summarizeTable(ctx, fromTable)
+	if err != nil {
+		return err
+	}
+	to, err := m.summarizeTable(ctx, toTable)
+	if err != nil {
+		return err
+	}
+
+	if from.Count != to.Count {
+		return fmt.Errorf("verification of the copy of %s to %s failed: %s has %d records, but %s has %d",
+			fromTable, toTable, fromTable, from.Count, toTable, to.Count)
+	}
+	if from.Checksum != to.Checksum {
+		return fmt.Errorf("verification of the copy of %s to %s failed: the first %d records differ",
+			fromTable, toTable, min(from.Count, verifySampleSize))
+	}
+
+	m.LogInfo("Verified table copy",
+		"from_table", fromTable,
+		"to_table", toTable,
+		"records", from.Count,
+	)
+
+	return nil
+}
+
+// summarizeTable returns the number of records of the table, and the checksum of its first verifySampleSize records.
+func (m *Migrator) summarizeTable(ctx context.Context, table string) (tableSummary, error) {
+	results, err := read[tableSummary](ctx, m, `
+		RETURN {
+			count: (SELECT count() AS count FROM type::table($tb) GROUP ALL)[0].count ?? 0,
+			checksum: crypto::sha256(<string>[
+				(SELECT VALUE record::id(id) FROM type::table($tb) LIMIT $sample_size),
+				(SELECT * OMIT id FROM type::table($tb) LIMIT $sample_size)
+			])
+		}
+	`, map[string]any{
+		"tb":          table,
+		"sample_size": verifySampleSize,
+	})
+	if err != nil {
+		return tableSummary{}, fmt.Errorf("failed to summarize table %s: %w", table, err)
+	}
+	if results == nil || len(*results) == 0 {
+		return tableSummary{}, fmt.Errorf("unexpected empty results when summarizing table %s", table)
+	}
+	return (*results)[0].Result, nil
+}
@@ -0,0 +1,65 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+)
+
+func TestVerifyCopy(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE source SCHEMALESS;
+		DEFINE TABLE same SCHEMALESS;
+		DEFINE TABLE missing SCHEMALESS;
+		DEFINE TABLE changed SCHEMALESS;
+		CREATE source:1 SET name = 'a', owner = source:2;
+		CREATE source:2 SET name = 'b';
+		CREATE same:1 SET name = 'a', owner = source:2;
+		CREATE same:2 SET name = 'b';
+		CREATE missing:1 SET name = 'a', owner = source:2;
+		CREATE changed:1 SET name = 'a', owner = source:2;
+		CREATE changed:2 SET name = 'c';
+	`, nil)
+	require.NoError(t, err, "Failed to create tables")
+
+	require.NoError(t, migrator.VerifyCopy(ctx, "source", "same"))
+
+	err = migrator.VerifyCopy(ctx, "source", "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "source has 2 records, but missing has 1")
+
+	err = migrator.VerifyCopy(ctx, "source", "changed")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the first 2 records differ")
+}
+
+func TestRenameTable_VerificationFailureKeepsOriginal(t *testing.T) {
+	ctx := t.Context()
+	namespace := testNamespace(t)
+
+	db, migrator := testSetup(t, namespace)
+
+	// The VALUE clause changes the values again when copied, so the copy differs from the original
+	_, err := surrealdb.Query[any](ctx, db, `
+		DEFINE TABLE users SCHEMAFULL;
+		DEFINE FIELD visits ON users TYPE int VALUE $value + 1;
+		CREATE users:1 SET visits = 1;
+	`, nil)
+	require.NoError(t, err, "Failed to create table")
+
+	err = migrator.RenameTable(ctx, namespace, "", "users", "people")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "verification of the copy of users to people failed")
+
+	results, err := surrealdb.Query[[]map[string]any](ctx, db, "SELECT * FROM users", nil)
+	require.NoError(t, err)
+	require.Len(t, (*results)[0].Result, 1, "the original table stays untouched")
+	assert.Equal(t, uint64(2), (*results)[0].Result[0]["visits"])
+}
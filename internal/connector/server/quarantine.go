@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// rejectedTable is the table of the database of each batch the rows quarantined in quarantine mode are written to.
+const rejectedTable = "_fivetran_rejected"
+
+// invalidRowError is an error in a single row of a batch file, like a malformed value,
+// which never affects the other rows.
+type invalidRowError struct {
+	err error
+}
+
+func (e *invalidRowError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidRowError) Unwrap() error {
+	return e.err
+}
+
+// invalidRow marks err as an error in the row being processed,
+// so that the row is quarantined instead of failing the batch in quarantine mode.
+func invalidRow(err error) error {
+	return &invalidRowError{err: err}
+}
+
+// batchRow is a row as read from a batch file, kept so that it can be quarantined as is.
+type batchRow struct {
+	file string
+	// row is the 1-based number of the row in the file
+	row     int64
+	columns []string
+	values  []any
+}
+
+// quarantine writes the rows of a batch that can't be written to rejectedTable,
+// so that the batch continues without them rather than failing on every retry.
+//
+// A row is quarantined when it fails the conversion to SurrealDB values,
+// or when SurrealDB rejects it in a bulk write, in which case the rows of the chunk are retried one by one
+// so that only the rejected rows are quarantined.
+type quarantine struct {
+	s     *Server
+	db    *surrealdb.DB
+	table string
+
+	// reading is the row processBatchFiles is processing, which is only accessed by the reading goroutine
+	reading *batchRow
+
+	mu       sync.Mutex
+	defined  bool
+	rejected int
+}
+
+func (s *Server) newQuarantine(db *surrealdb.DB, table string) *quarantine {
+	return &quarantine{s: s, db: db, table: table}
+}
+
+type quarantineKey struct{}
+
+// withQuarantine returns a context carrying the quarantine of the batch,
+// which makes processBatchFiles and the row writers quarantine the rows that can't be written.
+func withQuarantine(ctx context.Context, q *quarantine) context.Context {
+	if q == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, quarantineKey{}, q)
+}
+
+// quarantineFrom returns the quarantine carried by ctx, or nil if the batch is not in quarantine mode.
+func quarantineFrom(ctx context.Context) *quarantine {
+	q, _ := ctx.Value(quarantineKey{}).(*quarantine)
+	return q
+}
+
+// source returns the batch file row being processed, so that a bulk row can be quarantined once written,
+// or nil if the batch is not in quarantine mode.
+func (q *quarantine) source() *batchRow {
+	if q == nil {
+		return nil
+	}
+	return q.reading
+}
+
+// Rejected returns the number of rows quarantined so far.
+func (q *quarantine) Rejected() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.rejected
+}
+
+// reject writes the row to rejectedTable along with the error.
+//
+// Quarantined rows are identified by the table, file name and row number,
+// so that retrying the same batch never quarantines a row twice.
+// The file name excludes the directory, because Fivetran passes the batch files
+// in a different temporary directory on each retry.
+func (q *quarantine) reject(ctx context.Context, row *batchRow, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.defined {
+		if _, err := tracing.Query[any](ctx, q.db, fmt.Sprintf("DEFINE TABLE IF NOT EXISTS %s SCHEMALESS", rejectedTable), nil); err != nil {
+			return fmt.Errorf("unable to define table %s: %w", rejectedTable, err)
+		}
+		q.defined = true
+	}
+
+	values := make(map[string]any, len(row.columns))
+	for i, column := range row.columns {
+		values[column] = row.values[i]
+	}
+
+	_, err := tracing.Query[any](ctx, q.db, "UPSERT $thing CONTENT $content RETURN NONE", map[string]any{
+		"thing": models.NewRecordID(rejectedTable, []any{q.table, filepath.Base(row.file), row.row}),
+		"content": map[string]any{
+			"table":       q.table,
+			"file":        row.file,
+			"row":         row.row,
+			"operation":   metrics.LabelsFromContext(ctx).Operation,
+			"values":      values,
+			"error":       cause.Error(),
+			"rejected_at": models.CustomDateTime{Time: time.Now().UTC()},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to quarantine row %d of batch file %s: %w", row.row, row.file, err)
+	}
+
+	q.rejected++
+
+	q.s.LogWarning("Quarantined a row that can't be written", cause, "table", q.table, "file", row.file, "row", row.row)
+
+	return nil
+}
+
+// writeOneByOne writes the rows of a chunk SurrealDB rejected one by one,
+// passing the rows SurrealDB rejects again to reject, so that the other rows of the chunk are still written.
+//
+// Rewriting the rows written before the rejection is harmless, because every bulk write is idempotent.
+func writeOneByOne[T any](rows []T, source func(T) *batchRow, write func(rows []T) error, reject func(row *batchRow, err error) error) error {
+	for _, row := range rows {
+		err := write([]T{row})
+		if err == nil {
+			continue
+		}
+
+		src := source(row)
+		if src == nil || !errors.Is(err, &surrealdb.QueryError{}) {
+			return err
+		}
+		if err := reject(src, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rejectedWarning returns the message of the warning returned for a batch whose rows were quarantined.
+func rejectedWarning(table string, rejected int) string {
+	return fmt.Sprintf("%d rows of table %s can't be written and were quarantined to table %s", rejected, table, rejectedTable)
+}
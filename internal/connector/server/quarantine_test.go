@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+func TestInvalidRow(t *testing.T) {
+	err := fmt.Errorf("failed to process row 2: %w", invalidRow(errors.New("invalid int")))
+
+	var invalid *invalidRowError
+	require.True(t, errors.As(err, &invalid))
+	assert.EqualError(t, invalid, "invalid int")
+}
+
+func TestWriteOneByOne(t *testing.T) {
+	source := func(row int) *batchRow {
+		return &batchRow{file: "replace.csv", row: int64(row)}
+	}
+
+	var written []int
+	var rejected []int64
+	err := writeOneByOne([]int{1, 2, 3}, source, func(rows []int) error {
+		if rows[0] == 2 {
+			return fmt.Errorf("unable to write 1 records in bulk: %w", &surrealdb.QueryError{Message: "Found 'x' for field `age`"})
+		}
+		written = append(written, rows...)
+		return nil
+	}, func(row *batchRow, err error) error {
+		rejected = append(rejected, row.row)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, written)
+	assert.Equal(t, []int64{2}, rejected)
+
+	// Errors other than query errors, like connection errors, still fail the chunk
+	err = writeOneByOne([]int{1, 2}, source, func(rows []int) error {
+		return errors.New("connection closed")
+	}, func(row *batchRow, err error) error {
+		t.Fatal("unexpected rejection")
+		return nil
+	})
+	require.EqualError(t, err, "connection closed")
+}
+
+func TestParseConfig_Quarantine(t *testing.T) {
+	srv := New(zerolog.Nop())
+	configuration := map[string]string{"url": "ws://localhost:8000", "ns": "test", "user": "root", "pass": "root"}
+
+	cfg, err := srv.parseConfig(configuration)
+	require.NoError(t, err)
+	assert.False(t, cfg.quarantine)
+
+	configuration["quarantine_rejected_rows"] = "true"
+	cfg, err = srv.parseConfig(configuration)
+	require.NoError(t, err)
+	assert.True(t, cfg.quarantine)
+
+	configuration["quarantine_rejected_rows"] = "maybe"
+	_, err = srv.parseConfig(configuration)
+	assert.ErrorContains(t, err, "invalid quarantine_rejected_rows")
+}
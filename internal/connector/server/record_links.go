@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+)
+
+// recordLinks maps Fivetran tables to their columns linking to other Fivetran tables in the same schema,
+// like {"orders": {"customer_id": "customers"}}.
+//
+// Tables can be qualified with the schema, like "sales.orders",
+// which takes precedence over the unqualified table.
+type recordLinks map[string]map[string]string
+
+// parseRecordLinks parses the record_links configuration, which is a JSON object like recordLinks.
+func parseRecordLinks(s string) (recordLinks, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var links recordLinks
+	if err := json.Unmarshal([]byte(s), &links); err != nil {
+		return nil, fmt.Errorf("invalid record_links: expected a JSON object like {\"orders\": {\"customer_id\": \"customers\"}}: %w", err)
+	}
+
+	for table, columns := range links {
+		if table == "" {
+			return nil, fmt.Errorf("invalid record_links: empty table name")
+		}
+		for column, linked := range columns {
+			if column == "" || linked == "" {
+				return nil, fmt.Errorf("invalid record_links: empty column or linked table name in table %s", table)
+			}
+		}
+	}
+
+	return links, nil
+}
+
+// recordLinksFor returns the columns of the Fivetran table linking to other tables,
+// mapped to the SurrealDB tables they link to.
+func (cfg config) recordLinksFor(schema, table string) map[string]string {
+	columns, ok := cfg.recordLinks[schema+"."+table]
+	if !ok {
+		columns = cfg.recordLinks[table]
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	links := make(map[string]string, len(columns))
+	for column, linked := range columns {
+		links[column] = cfg.schemaMapping.tableFor(schema, linked)
+	}
+	return links
+}
+
+// alteredRecordLinks returns the links of the existing table once altered.
+//
+// Like the record ID layout, whether a column is a link is decided when the column is created,
+// because the existing values would otherwise be left of the other type.
+// So only the new columns link to the configured tables.
+func alteredRecordLinks(current tablemapper.TableInfo, configured map[string]string) map[string]string {
+	existing := make(map[string]bool, len(current.Columns))
+	links := make(map[string]string)
+	for _, c := range current.Columns {
+		existing[c.Name] = true
+		if c.LinkTable != "" {
+			links[c.Name] = c.LinkTable
+		}
+	}
+
+	for column, linked := range configured {
+		if !existing[column] {
+			links[column] = linked
+		}
+	}
+	return links
+}
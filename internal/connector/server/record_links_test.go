@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+)
+
+func TestRecordLinks(t *testing.T) {
+	links, err := parseRecordLinks(`{"orders": {"customer_id": "customers"}, "sales.orders": {"customer_id": "clients"}}`)
+	require.NoError(t, err)
+
+	cfg := config{recordLinks: links}
+	assert.Equal(t, map[string]string{"customer_id": "customers"}, cfg.recordLinksFor("shop", "orders"))
+	assert.Equal(t, map[string]string{"customer_id": "clients"}, cfg.recordLinksFor("sales", "orders"), "the table qualified with the schema takes precedence")
+	assert.Nil(t, cfg.recordLinksFor("shop", "customers"))
+
+	prefix, err := parseSchemaMapping(map[string]string{"schema_mapping": "prefix", "target_database": "fivetran"})
+	require.NoError(t, err)
+	cfg.schemaMapping = prefix
+	assert.Equal(t, map[string]string{"customer_id": "shop_customers"}, cfg.recordLinksFor("shop", "orders"), "links follow the schema mapping")
+}
+
+func TestRecordLinks_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "not an object", value: `["orders"]`, wantErr: "expected a JSON object"},
+		{name: "empty linked table", value: `{"orders": {"customer_id": ""}}`, wantErr: "empty column or linked table name in table orders"},
+		{name: "empty table", value: `{"": {"customer_id": "customers"}}`, wantErr: "empty table name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRecordLinks(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestAlteredRecordLinks(t *testing.T) {
+	current := tablemapper.TableInfo{Columns: []tablemapper.ColumnInfo{
+		{Name: "customer_id", ColumnMeta: tablemapper.ColumnMeta{LinkTable: "customers"}},
+		{Name: "seller_id"},
+	}}
+
+	links := alteredRecordLinks(current, map[string]string{
+		"seller_id":  "sellers",
+		"product_id": "products",
+	})
+	assert.Equal(t, map[string]string{
+		"customer_id": "customers",
+		"product_id":  "products",
+	}, links, "existing columns keep whether they are links, and new columns link as configured")
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// relationEnd is a column identifying the records of a Fivetran table related by the edges of a relation table.
+type relationEnd struct {
+	Column string `json:"column"`
+	Table  string `json:"table"`
+}
+
+// relationTable declares a Fivetran table as a relation table,
+// whose records are the edges from the in records to the out records.
+type relationTable struct {
+	In  relationEnd `json:"in"`
+	Out relationEnd `json:"out"`
+}
+
+// relationTables maps Fivetran tables to their relations, like
+// {"order_items": {"in": {"column": "order_id", "table": "orders"}, "out": {"column": "product_id", "table": "products"}}}.
+//
+// Like recordLinks, tables can be qualified with the schema,
+// which takes precedence over the unqualified table.
+type relationTables map[string]relationTable
+
+// parseRelationTables parses the relation_tables configuration, which is a JSON object like relationTables.
+func parseRelationTables(s string) (relationTables, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var relations relationTables
+	if err := json.Unmarshal([]byte(s), &relations); err != nil {
+		return nil, fmt.Errorf(`invalid relation_tables: expected a JSON object like {"order_items": {"in": {"column": "order_id", "table": "orders"}, "out": {"column": "product_id", "table": "products"}}}: %w`, err)
+	}
+
+	for table, r := range relations {
+		if table == "" {
+			return nil, fmt.Errorf("invalid relation_tables: empty table name")
+		}
+		if r.In.Column == "" || r.In.Table == "" || r.Out.Column == "" || r.Out.Table == "" {
+			return nil, fmt.Errorf("invalid relation_tables: table %s needs the column and table of both in and out", table)
+		}
+	}
+
+	return relations, nil
+}
+
+// relationFor returns the relation the Fivetran table is defined as, with the related tables
+// mapped to SurrealDB tables, or nil if the table is not declared as a relation table.
+func (cfg config) relationFor(schema, table string) *tablemapper.Relation {
+	r, ok := cfg.relationTables[schema+"."+table]
+	if !ok {
+		r, ok = cfg.relationTables[table]
+	}
+	if !ok {
+		return nil
+	}
+
+	return &tablemapper.Relation{
+		InColumn:  r.In.Column,
+		InTable:   cfg.schemaMapping.tableFor(schema, r.In.Table),
+		OutColumn: r.Out.Column,
+		OutTable:  cfg.schemaMapping.tableFor(schema, r.Out.Table),
+	}
+}
+
+// edgeColumns are the columns identifying the records related by the edges of a relation table.
+type edgeColumns struct {
+	in, out tablemapper.ColumnInfo
+	// fields are the fields of the relation table, which are all set when an edge is replaced
+	fields []string
+}
+
+// relationColumns returns the relation columns of the table with the fields,
+// or nil if the table is not a relation table.
+func relationColumns(fields map[string]tablemapper.ColumnInfo) *edgeColumns {
+	info := tablemapper.TableInfo{Columns: make([]tablemapper.ColumnInfo, 0, len(fields))}
+	for _, f := range fields {
+		info.Columns = append(info.Columns, f)
+	}
+
+	in, out, ok := info.Relation()
+	if !ok {
+		return nil
+	}
+	return &edgeColumns{in: in, out: out, fields: tableFields(fields)}
+}
+
+// edge returns the endpoints of the edge whose content, converted by ValueToSurrealType,
+// includes the values of the relation columns.
+func (e *edgeColumns) edge(content map[string]any) (*bulkEdge, error) {
+	in, ok := content[e.in.Name]
+	if !ok || in == models.None {
+		return nil, fmt.Errorf("relation column %s has no value", e.in.Name)
+	}
+	out, ok := content[e.out.Name]
+	if !ok || out == models.None {
+		return nil, fmt.Errorf("relation column %s has no value", e.out.Name)
+	}
+
+	return &bulkEdge{
+		in:  e.in.RelatedRecordID(in),
+		out: e.out.RelatedRecordID(out),
+	}, nil
+}
+
+// upsertContentQuery returns the query replacing the content of the record, creating it if it does not exist,
+// and its parameters.
+//
+// Edges of relation tables can't be upserted, so they are written by INSERT RELATION like in bulk writes.
+func upsertContentQuery(edges *edgeColumns, thing models.RecordID, content map[string]any) (string, map[string]any, error) {
+	if edges == nil {
+		return "UPSERT $thing CONTENT $content RETURN NONE", map[string]any{
+			"thing":   thing,
+			"content": content,
+		}, nil
+	}
+
+	edge, err := edges.edge(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("record %s: %w", thing, err)
+	}
+	query, vars := bulkWriteQuery(edges.fields)([]bulkRow{{id: thing, content: content, kind: bulkReplace, edge: edge}})
+	return query, vars, nil
+}
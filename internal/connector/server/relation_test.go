@@ -0,0 +1,91 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+)
+
+func TestRelationTables(t *testing.T) {
+	relations, err := parseRelationTables(`{"order_items": {"in": {"column": "order_id", "table": "orders"}, "out": {"column": "product_id", "table": "products"}}}`)
+	require.NoError(t, err)
+
+	cfg := config{relationTables: relations}
+	assert.Equal(t, &tablemapper.Relation{
+		InColumn:  "order_id",
+		InTable:   "orders",
+		OutColumn: "product_id",
+		OutTable:  "products",
+	}, cfg.relationFor("shop", "order_items"))
+	assert.Nil(t, cfg.relationFor("shop", "orders"))
+
+	prefix, err := parseSchemaMapping(map[string]string{"schema_mapping": "prefix", "target_database": "fivetran"})
+	require.NoError(t, err)
+	cfg.schemaMapping = prefix
+	r := cfg.relationFor("shop", "order_items")
+	require.NotNil(t, r)
+	assert.Equal(t, "shop_orders", r.InTable, "related tables follow the schema mapping")
+	assert.Equal(t, "shop_products", r.OutTable, "related tables follow the schema mapping")
+}
+
+func TestRelationTables_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "not an object", value: `["order_items"]`, wantErr: "expected a JSON object"},
+		{name: "missing out", value: `{"order_items": {"in": {"column": "order_id", "table": "orders"}}}`, wantErr: "table order_items needs the column and table of both in and out"},
+		{name: "empty table", value: `{"": {"in": {"column": "a", "table": "b"}, "out": {"column": "c", "table": "d"}}}`, wantErr: "empty table name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRelationTables(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRelationColumns(t *testing.T) {
+	assert.Nil(t, relationColumns(map[string]tablemapper.ColumnInfo{"id": {Name: "id"}}))
+
+	edges := relationColumns(map[string]tablemapper.ColumnInfo{
+		"order_id":   {Name: "order_id", ColumnMeta: tablemapper.ColumnMeta{Relation: tablemapper.RelationIn, RelationTable: "orders"}},
+		"product_id": {Name: "product_id", ColumnMeta: tablemapper.ColumnMeta{Relation: tablemapper.RelationOut, RelationTable: "products"}, LinkScalarRecordIDs: true},
+		"quantity":   {Name: "quantity"},
+	})
+	require.NotNil(t, edges)
+
+	edge, err := edges.edge(map[string]any{"order_id": 1, "product_id": "p1", "quantity": 2})
+	require.NoError(t, err)
+	assert.Equal(t, &bulkEdge{
+		in:  models.NewRecordID("orders", []any{1}),
+		out: models.NewRecordID("products", "p1"),
+	}, edge)
+
+	_, err = edges.edge(map[string]any{"order_id": 1, "product_id": models.None})
+	assert.ErrorContains(t, err, "relation column product_id has no value")
+}
+
+func TestBulkWriteQuery_Relation(t *testing.T) {
+	id := models.NewRecordID("order_items", []any{1, "p1"})
+	edge := &bulkEdge{in: models.NewRecordID("orders", []any{1}), out: models.NewRecordID("products", []any{"p1"})}
+
+	query, vars := bulkWriteQuery(nil)([]bulkRow{{id: id, content: map[string]any{"quantity": 2}, kind: bulkReplace, edge: edge}})
+	require.Equal(t, "INSERT RELATION INTO `order_items` $rows0 ON DUPLICATE KEY UPDATE `quantity` = $input.`quantity` RETURN NONE;\n", query)
+	require.Equal(t, map[string]any{
+		"rows0": []map[string]any{
+			{"id": id, "in": edge.in, "out": edge.out, "quantity": 2},
+		},
+	}, vars, "the endpoints are only set when the edge is created")
+
+	query, _, err := upsertContentQuery(nil, id, map[string]any{"quantity": 2})
+	require.NoError(t, err)
+	require.Equal(t, "UPSERT $thing CONTENT $content RETURN NONE", query)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/log"
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/retry"
+)
+
+// retryPolicyFromEnv returns the default retry policy overridden by environment variables.
+//
+// SURREAL_FIVETRAN_RETRY_MAX_ATTEMPTS=1 disables retries.
+func retryPolicyFromEnv(logging *log.Logging) retry.Policy {
+	p := retry.DefaultPolicy()
+
+	if v := os.Getenv("SURREAL_FIVETRAN_RETRY_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			p.MaxAttempts = n
+		} else {
+			logging.LogWarning("Invalid retry max attempts, falling back to the default", err, "value", v, "default", p.MaxAttempts)
+		}
+	}
+
+	for _, d := range []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"SURREAL_FIVETRAN_RETRY_INITIAL_BACKOFF", &p.InitialBackoff},
+		{"SURREAL_FIVETRAN_RETRY_MAX_BACKOFF", &p.MaxBackoff},
+	} {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			logging.LogWarning("Invalid retry backoff, falling back to the default", err, "env", d.env, "value", v, "default", *d.value)
+			continue
+		}
+		*d.value = parsed
+	}
+
+	return p
+}
+
+// onRetry logs and counts each retry of a write to SurrealDB.
+func (s *Server) onRetry(ctx context.Context, attempt int, err error, delay time.Duration) {
+	s.LogWarning("Retrying a write to SurrealDB after a transient failure", err, "attempt", attempt, "delay", delay)
+	if s.metrics != nil {
+		s.metrics.DBWriteRetried(metrics.LabelsFromContext(ctx))
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/log"
+	"github.com/surrealdb/fivetran-destination/internal/connector/retry"
+)
+
+func TestRetryPolicyFromEnv(t *testing.T) {
+	logging := &log.Logging{Logger: zerolog.New(os.Stdout)}
+
+	t.Run("defaults", func(t *testing.T) {
+		require.Equal(t, retry.DefaultPolicy(), retryPolicyFromEnv(logging))
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("SURREAL_FIVETRAN_RETRY_MAX_ATTEMPTS", "1")
+		t.Setenv("SURREAL_FIVETRAN_RETRY_INITIAL_BACKOFF", "1s")
+		t.Setenv("SURREAL_FIVETRAN_RETRY_MAX_BACKOFF", "1m")
+
+		p := retryPolicyFromEnv(logging)
+		require.Equal(t, 1, p.MaxAttempts)
+		require.Equal(t, time.Second, p.InitialBackoff)
+		require.Equal(t, time.Minute, p.MaxBackoff)
+	})
+
+	t.Run("invalid values fall back to the defaults", func(t *testing.T) {
+		t.Setenv("SURREAL_FIVETRAN_RETRY_MAX_ATTEMPTS", "0")
+		t.Setenv("SURREAL_FIVETRAN_RETRY_INITIAL_BACKOFF", "soon")
+
+		require.Equal(t, retry.DefaultPolicy(), retryPolicyFromEnv(logging))
+	})
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Schema mappings selectable via the schema_mapping configuration.
+const (
+	// SchemaMappingDatabase maps each Fivetran schema to the SurrealDB database of the same name.
+	SchemaMappingDatabase = "database"
+	// SchemaMappingPrefix maps every Fivetran schema to a single SurrealDB database,
+	// prefixing the table names with the schema, like `<schema>_<table>`.
+	SchemaMappingPrefix = "prefix"
+	// SchemaMappingTemplate maps each Fivetran schema to the SurrealDB database
+	// named after the template, like `{schema}_prod`.
+	SchemaMappingTemplate = "template"
+)
+
+// schemaPlaceholder is replaced with the Fivetran schema in database templates.
+const schemaPlaceholder = "{schema}"
+
+// schemaMapping decides which SurrealDB database and table a Fivetran schema and table are written to.
+//
+// The zero value maps each schema to the database of the same name,
+// keeping the table names as they are.
+type schemaMapping struct {
+	mode string
+	// database is the database every schema is mapped to in prefix mode
+	database string
+	// template is the database name template in template mode
+	template string
+	// overrides maps schemas to databases regardless of the mode.
+	// Tables of the overridden schemas keep their names.
+	overrides map[string]string
+}
+
+// parseSchemaMapping parses the schema mapping from the Fivetran connector configuration.
+func parseSchemaMapping(configuration map[string]string) (schemaMapping, error) {
+	m := schemaMapping{
+		mode:     strings.TrimSpace(configuration["schema_mapping"]),
+		database: strings.TrimSpace(configuration["target_database"]),
+		template: strings.TrimSpace(configuration["database_template"]),
+	}
+
+	switch m.mode {
+	case "":
+		m.mode = SchemaMappingDatabase
+	case SchemaMappingDatabase:
+	case SchemaMappingPrefix:
+		if m.database == "" {
+			return schemaMapping{}, fmt.Errorf("target_database is required for the %s schema mapping", SchemaMappingPrefix)
+		}
+	case SchemaMappingTemplate:
+		// Without the placeholder, every schema would land in the same database with unprefixed tables,
+		// so that tables of the same name in different schemas would overwrite each other.
+		if !strings.Contains(m.template, schemaPlaceholder) {
+			return schemaMapping{}, fmt.Errorf("database_template must contain %s for the %s schema mapping", schemaPlaceholder, SchemaMappingTemplate)
+		}
+	default:
+		return schemaMapping{}, fmt.Errorf("unknown schema mapping: %s", m.mode)
+	}
+
+	overrides, err := parseSchemaOverrides(configuration["schema_overrides"])
+	if err != nil {
+		return schemaMapping{}, err
+	}
+	m.overrides = overrides
+
+	return m, nil
+}
+
+// parseSchemaOverrides parses comma-separated `schema=database` pairs.
+func parseSchemaOverrides(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	overrides := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		schema, database, ok := strings.Cut(pair, "=")
+		schema, database = strings.TrimSpace(schema), strings.TrimSpace(database)
+		if !ok || schema == "" || database == "" {
+			return nil, fmt.Errorf("invalid schema override %q: expected schema=database", strings.TrimSpace(pair))
+		}
+		if _, dup := overrides[schema]; dup {
+			return nil, fmt.Errorf("duplicate schema override for schema %s", schema)
+		}
+		overrides[schema] = database
+	}
+	return overrides, nil
+}
+
+// databaseFor returns the SurrealDB database the Fivetran schema is mapped to.
+func (m schemaMapping) databaseFor(schema string) string {
+	if database, ok := m.overrides[schema]; ok {
+		return database
+	}
+
+	switch m.mode {
+	case SchemaMappingPrefix:
+		return m.database
+	case SchemaMappingTemplate:
+		return strings.ReplaceAll(m.template, schemaPlaceholder, schema)
+	default:
+		return schema
+	}
+}
+
+// tableFor returns the SurrealDB table the Fivetran table in the schema is mapped to.
+func (m schemaMapping) tableFor(schema, table string) string {
+	if m.prefixed(schema) {
+		return schema + "_" + table
+	}
+	return table
+}
+
+// prefixed reports whether the tables of the schema are prefixed with the schema.
+func (m schemaMapping) prefixed(schema string) bool {
+	_, overridden := m.overrides[schema]
+	return m.mode == SchemaMappingPrefix && !overridden
+}
+
+// withTargetTable returns the request, or a copy of it whose table is renamed
+// to the SurrealDB table the Fivetran table is mapped to.
+//
+// The request itself is never modified, so that the responses
+// and the logs can keep referring to the Fivetran table.
+func withTargetTable[T interface {
+	proto.Message
+	GetSchemaName() string
+	GetTable() *pb.Table
+}](cfg config, req T) T {
+	name := cfg.schemaMapping.tableFor(req.GetSchemaName(), req.GetTable().GetName())
+	if name == req.GetTable().GetName() {
+		return req
+	}
+
+	req = proto.Clone(req).(T)
+	req.GetTable().Name = name
+	return req
+}
+
+// withTargetMigrationTables is like withTargetTable, but renames every table
+// referenced by the migration, including the source and destination of table renames and copies.
+func withTargetMigrationTables(cfg config, req *pb.MigrateRequest) *pb.MigrateRequest {
+	schema := req.GetDetails().GetSchema()
+	if !cfg.schemaMapping.prefixed(schema) {
+		return req
+	}
+
+	req = proto.Clone(req).(*pb.MigrateRequest)
+	d := req.Details
+	d.Table = cfg.schemaMapping.tableFor(schema, d.Table)
+
+	switch v := d.Operation.(type) {
+	case *pb.MigrationDetails_Rename:
+		if t, ok := v.Rename.Entity.(*pb.RenameOperation_RenameTable); ok {
+			t.RenameTable.FromTable = cfg.schemaMapping.tableFor(schema, t.RenameTable.FromTable)
+			t.RenameTable.ToTable = cfg.schemaMapping.tableFor(schema, t.RenameTable.ToTable)
+		}
+	case *pb.MigrationDetails_Copy:
+		switch t := v.Copy.Entity.(type) {
+		case *pb.CopyOperation_CopyTable:
+			t.CopyTable.FromTable = cfg.schemaMapping.tableFor(schema, t.CopyTable.FromTable)
+			t.CopyTable.ToTable = cfg.schemaMapping.tableFor(schema, t.CopyTable.ToTable)
+		case *pb.CopyOperation_CopyTableToHistoryMode:
+			t.CopyTableToHistoryMode.FromTable = cfg.schemaMapping.tableFor(schema, t.CopyTableToHistoryMode.FromTable)
+			t.CopyTableToHistoryMode.ToTable = cfg.schemaMapping.tableFor(schema, t.CopyTableToHistoryMode.ToTable)
+		}
+	}
+
+	return req
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestSchemaMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration map[string]string
+		schema        string
+		wantDatabase  string
+		wantTable     string
+	}{
+		{
+			name:          "default",
+			configuration: map[string]string{},
+			schema:        "sales",
+			wantDatabase:  "sales",
+			wantTable:     "orders",
+		},
+		{
+			name:          "database",
+			configuration: map[string]string{"schema_mapping": "database"},
+			schema:        "sales",
+			wantDatabase:  "sales",
+			wantTable:     "orders",
+		},
+		{
+			name:          "prefix",
+			configuration: map[string]string{"schema_mapping": "prefix", "target_database": "fivetran"},
+			schema:        "sales",
+			wantDatabase:  "fivetran",
+			wantTable:     "sales_orders",
+		},
+		{
+			name:          "template",
+			configuration: map[string]string{"schema_mapping": "template", "database_template": "{schema}_prod"},
+			schema:        "sales",
+			wantDatabase:  "sales_prod",
+			wantTable:     "orders",
+		},
+		{
+			name: "override in prefix mode",
+			configuration: map[string]string{
+				"schema_mapping":   "prefix",
+				"target_database":  "fivetran",
+				"schema_overrides": "hr=people, sales = crm",
+			},
+			schema:       "sales",
+			wantDatabase: "crm",
+			wantTable:    "orders",
+		},
+		{
+			name: "schema without override in prefix mode",
+			configuration: map[string]string{
+				"schema_mapping":   "prefix",
+				"target_database":  "fivetran",
+				"schema_overrides": "hr=people",
+			},
+			schema:       "sales",
+			wantDatabase: "fivetran",
+			wantTable:    "sales_orders",
+		},
+		{
+			name:          "override in template mode",
+			configuration: map[string]string{"schema_mapping": "template", "database_template": "{schema}_prod", "schema_overrides": "sales=crm"},
+			schema:        "sales",
+			wantDatabase:  "crm",
+			wantTable:     "orders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseSchemaMapping(tt.configuration)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDatabase, m.databaseFor(tt.schema))
+			assert.Equal(t, tt.wantTable, m.tableFor(tt.schema, "orders"))
+		})
+	}
+}
+
+func TestSchemaMapping_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration map[string]string
+		wantErr       string
+	}{
+		{
+			name:          "unknown mode",
+			configuration: map[string]string{"schema_mapping": "namespace"},
+			wantErr:       "unknown schema mapping: namespace",
+		},
+		{
+			name:          "prefix without target database",
+			configuration: map[string]string{"schema_mapping": "prefix"},
+			wantErr:       "target_database is required",
+		},
+		{
+			name:          "template without placeholder",
+			configuration: map[string]string{"schema_mapping": "template", "database_template": "prod"},
+			wantErr:       "database_template must contain {schema}",
+		},
+		{
+			name:          "override without database",
+			configuration: map[string]string{"schema_overrides": "sales="},
+			wantErr:       `invalid schema override "sales="`,
+		},
+		{
+			name:          "override without separator",
+			configuration: map[string]string{"schema_overrides": "sales=crm,hr"},
+			wantErr:       `invalid schema override "hr"`,
+		},
+		{
+			name:          "duplicate override",
+			configuration: map[string]string{"schema_overrides": "sales=crm,sales=other"},
+			wantErr:       "duplicate schema override for schema sales",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSchemaMapping(tt.configuration)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestWithTargetTable(t *testing.T) {
+	prefix, err := parseSchemaMapping(map[string]string{"schema_mapping": "prefix", "target_database": "fivetran"})
+	require.NoError(t, err)
+
+	req := &pb.WriteBatchRequest{
+		SchemaName: "sales",
+		Table:      &pb.Table{Name: "orders"},
+	}
+
+	mapped := withTargetTable(config{schemaMapping: prefix}, req)
+	assert.Equal(t, "sales_orders", mapped.Table.Name)
+	assert.Equal(t, "orders", req.Table.Name, "the original request must be left as is")
+
+	assert.Same(t, req, withTargetTable(config{}, req), "the request must be reused when the table is not renamed")
+}
+
+func TestWithTargetMigrationTables(t *testing.T) {
+	prefix, err := parseSchemaMapping(map[string]string{"schema_mapping": "prefix", "target_database": "fivetran"})
+	require.NoError(t, err)
+
+	req := &pb.MigrateRequest{
+		Details: &pb.MigrationDetails{
+			Schema: "sales",
+			Table:  "orders",
+			Operation: &pb.MigrationDetails_Rename{
+				Rename: &pb.RenameOperation{
+					Entity: &pb.RenameOperation_RenameTable{
+						RenameTable: &pb.RenameTable{FromTable: "orders", ToTable: "purchases"},
+					},
+				},
+			},
+		},
+	}
+
+	mapped := withTargetMigrationTables(config{schemaMapping: prefix}, req)
+	assert.Equal(t, "sales_orders", mapped.Details.Table)
+	rename := mapped.Details.GetRename().GetRenameTable()
+	assert.Equal(t, "sales_orders", rename.FromTable)
+	assert.Equal(t, "sales_purchases", rename.ToTable)
+	assert.Equal(t, "orders", req.Details.Table, "the original request must be left as is")
+}
@@ -2,16 +2,19 @@ package server
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/surrealdb/fivetran-destination/internal/connector/log"
 	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/retry"
 	"github.com/surrealdb/fivetran-destination/internal/connector/server/migrator"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	_ "google.golang.org/grpc/encoding/gzip"
 )
@@ -29,11 +32,89 @@ func New(logger zerolog.Logger) *Server {
 		}
 	}
 
-	return &Server{
-		mu:      &sync.Mutex{},
-		Logging: logging,
-		metrics: metrics.NewCollector(logging, metricsInterval),
+	// Get batch file format from environment variable
+	batchFileFormat := pb.BatchFileFormat_CSV
+	if format := os.Getenv("SURREAL_FIVETRAN_BATCH_FILE_FORMAT"); format != "" {
+		switch strings.ToLower(format) {
+		case "csv":
+		case "parquet":
+			batchFileFormat = pb.BatchFileFormat_PARQUET
+		default:
+			logging.LogWarning("Unknown batch file format, falling back to csv", nil, "format", format)
+		}
+	}
+
+	// Get the number of records written per statement from environment variable
+	writeChunkSize := defaultWriteChunkSize
+	if size := os.Getenv("SURREAL_FIVETRAN_WRITE_CHUNK_SIZE"); size != "" {
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			writeChunkSize = n
+		} else {
+			logging.LogWarning("Invalid write chunk size, falling back to the default", err, "size", size, "default", defaultWriteChunkSize)
+		}
+	}
+
+	// Get the number of concurrent writers per batch from environment variable
+	writeConcurrency := 1
+	if concurrency := os.Getenv("SURREAL_FIVETRAN_WRITE_CONCURRENCY"); concurrency != "" {
+		if n, err := strconv.Atoi(concurrency); err == nil && n > 0 {
+			writeConcurrency = n
+		} else {
+			logging.LogWarning("Invalid write concurrency, falling back to 1", err, "concurrency", concurrency)
+		}
+	}
+
+	// Get whether to commit batch rows in transactions from environment variable
+	var writeTransactions bool
+	if transactions := os.Getenv("SURREAL_FIVETRAN_WRITE_TRANSACTIONS"); transactions != "" {
+		if b, err := strconv.ParseBool(transactions); err == nil {
+			writeTransactions = b
+		} else {
+			logging.LogWarning("Invalid write transactions flag, falling back to false", err, "transactions", transactions)
+		}
 	}
+
+	// Get the number of rows committed per transaction from environment variable
+	transactionChunkSize := defaultTransactionChunkSize
+	if size := os.Getenv("SURREAL_FIVETRAN_TRANSACTION_CHUNK_SIZE"); size != "" {
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			transactionChunkSize = n
+		} else {
+			logging.LogWarning("Invalid transaction chunk size, falling back to the default", err, "size", size, "default", defaultTransactionChunkSize)
+		}
+	}
+
+	// Get whether migrations only plan their statements from environment variable
+	var migrationDryRun bool
+	if dryRun := os.Getenv("SURREAL_FIVETRAN_MIGRATION_DRY_RUN"); dryRun != "" {
+		if b, err := strconv.ParseBool(dryRun); err == nil {
+			migrationDryRun = b
+		} else {
+			logging.LogWarning("Invalid migration dry run flag, falling back to false", err, "dry_run", dryRun)
+		}
+	}
+
+	poolOpts := connPoolOptionsFromEnv(logging)
+	retryPolicy := retryPolicyFromEnv(logging)
+
+	s := &Server{
+		mu:               &sync.Mutex{},
+		Logging:          logging,
+		metrics:          metrics.NewCollector(logging, metricsInterval),
+		batchFileFormat:  batchFileFormat,
+		writeChunkSize:   writeChunkSize,
+		writeConcurrency: writeConcurrency,
+
+		writeTransactions:    writeTransactions,
+		transactionChunkSize: transactionChunkSize,
+
+		migrationDryRun: migrationDryRun,
+	}
+	s.pool = s.newServerConnPool(poolOpts)
+	s.retry = retryPolicy
+	s.retry.OnRetry = s.onRetry
+
+	return s
 }
 
 type Server struct {
@@ -43,6 +124,31 @@ type Server struct {
 
 	*log.Logging
 	metrics *metrics.Collector
+
+	// batchFileFormat is the format of the batch files we ask Fivetran to send
+	batchFileFormat pb.BatchFileFormat
+
+	// writeChunkSize is the number of records written by a single statement
+	writeChunkSize int
+
+	// writeConcurrency is the number of workers, each with its own connection,
+	// writing the rows of a batch concurrently
+	writeConcurrency int
+
+	// writeTransactions is true when the rows of a batch are committed in transactions
+	// of transactionChunkSize rows, instead of being written right away
+	writeTransactions    bool
+	transactionChunkSize int
+
+	// migrationDryRun is true when every migration only plans its statements, like the migration_dry_run option
+	migrationDryRun bool
+
+	// pool is the pool of connections reused across RPCs
+	pool *connPool
+
+	// retry is how idempotent writes are retried on transient failures, like transaction conflicts.
+	// The zero policy never retries.
+	retry retry.Policy
 }
 
 // Start initializes and starts the server components
@@ -52,6 +158,26 @@ func (s *Server) Start(ctx context.Context) {
 		s.metrics.Start(ctx)
 		s.LogInfo("Metrics collection started", "interval", s.metrics.LogInterval)
 	}
+
+	// Start evicting idle connections
+	if s.pool != nil {
+		s.pool.Start(ctx)
+		s.LogInfo("Connection pool started",
+			"max_connections", s.pool.opts.maxConnections,
+			"max_idle_time", s.pool.opts.maxIdleTime,
+		)
+	}
+}
+
+// Close logs a final metrics snapshot, and releases the server resources, like the pooled connections.
+// It should be called once the server has stopped serving RPCs.
+func (s *Server) Close(ctx context.Context) {
+	if s.metrics != nil {
+		s.metrics.Flush()
+	}
+	if s.pool != nil {
+		s.pool.Close(ctx)
+	}
 }
 
 // ConfigurationForm implements the ConfigurationForm method required by the DestinationConnectorServer interface
@@ -118,11 +244,106 @@ func (s *Server) ConfigurationForm(ctx context.Context, req *pb.ConfigurationFor
 		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
 	})
 
+	fields = append(fields, &pb.FormField{
+		Name:        "schema_mapping",
+		Label:       "Schema Mapping",
+		Description: stringPtr("Select how Fivetran schemas map to SurrealDB databases. \"database\" writes each schema to the database of the same name, \"prefix\" writes every schema to the target database with tables named <schema>_<table>, and \"template\" writes each schema to the database named after the database template. Defaults to \"database\"."),
+		Required:    boolPtr(false),
+		Type: &pb.FormField_DropdownField{DropdownField: &pb.DropdownField{
+			DropdownField: []string{SchemaMappingDatabase, SchemaMappingPrefix, SchemaMappingTemplate},
+		}},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "target_database",
+		Label:       "Target Database",
+		Placeholder: stringPtr("fivetran"),
+		Description: stringPtr("The database every schema is written to with the \"prefix\" schema mapping."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "database_template",
+		Label:       "Database Template",
+		Placeholder: stringPtr("{schema}_prod"),
+		Description: stringPtr("The database name for each schema with the \"template\" schema mapping, where {schema} is replaced with the schema."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "schema_overrides",
+		Label:       "Schema Overrides",
+		Placeholder: stringPtr("sales=crm,hr_data=hr"),
+		Description: stringPtr("Comma-separated schema=database pairs writing the schemas to the databases regardless of the schema mapping, without prefixing their tables."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "record_ids",
+		Label:       "Record IDs",
+		Description: stringPtr("Select the record IDs of the tables created from now on. \"array\" uses the array of the primary key values, like user:[42], and \"scalar\" uses the primary key value itself, like user:42, for tables with a single integer or string primary key column that are not in history mode. Existing tables keep their record IDs. Defaults to \"array\"."),
+		Required:    boolPtr(false),
+		Type: &pb.FormField_DropdownField{DropdownField: &pb.DropdownField{
+			DropdownField: []string{RecordIDsArray, RecordIDsScalar},
+		}},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "relation_tables",
+		Label:       "Relation Tables",
+		Placeholder: stringPtr(`{"order_items": {"in": {"column": "order_id", "table": "orders"}, "out": {"column": "product_id", "table": "products"}}}`),
+		Description: stringPtr("A JSON object mapping join tables to the primary key columns and the tables of the records they relate. The tables created from now on are relation tables, whose records are graph edges from the in records to the out records. Tables can be qualified with the schema, like \"sales.order_items\"."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "record_links",
+		Label:       "Record Links",
+		Placeholder: stringPtr(`{"orders": {"customer_id": "customers"}}`),
+		Description: stringPtr("A JSON object mapping tables to their foreign key columns and the tables they reference. The columns created from now on are stored as record links, like customers:[42], so that the referenced records can be traversed. Tables can be qualified with the schema, like \"sales.orders\"."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "quarantine_rejected_rows",
+		Label:       "Quarantine Rejected Rows",
+		Description: stringPtr("Write the rows that can't be converted to SurrealDB values or are rejected by SurrealDB to the _fivetran_rejected table, along with the error, instead of failing the whole batch. The batch then succeeds with a warning summarizing the number of quarantined rows."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_ToggleField{ToggleField: &pb.ToggleField{}},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "migration_dry_run",
+		Label:       "Migration Dry Run",
+		Description: stringPtr("Plan schema migrations without changing any data. Each migration returns a task listing the statements it would run and the number of records of the tables they go over, instead of running them. Turn it off and resync to apply the migrations."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_ToggleField{ToggleField: &pb.ToggleField{}},
+	})
+
+	fields = append(fields, &pb.FormField{
+		Name:        "verify_table",
+		Label:       "Verify Table",
+		Placeholder: stringPtr("sales.orders"),
+		Description: stringPtr("A table, qualified with the schema, whose consistency is checked by the Table Consistency test: the record IDs match the primary key columns, the field types match the column metadata, and history mode tables have at most one active record per primary key with non-overlapping intervals. The test passes without checking anything if empty."),
+		Required:    boolPtr(false),
+		Type:        &pb.FormField_TextField{TextField: pb.TextField_PlainText},
+	})
+
 	tests = append(tests, &pb.ConfigurationTest{
 		Name:  "database-connection",
 		Label: "Database Connection",
 	})
 
+	tests = append(tests, &pb.ConfigurationTest{
+		Name:  tableConsistencyTest,
+		Label: "Table Consistency",
+	})
+
 	if s.Debugging() {
 		s.LogDebug("ConfigurationForm called")
 	}
@@ -138,8 +359,7 @@ func (s *Server) Capabilities(ctx context.Context, req *pb.CapabilitiesRequest)
 		s.LogDebug("Capabilities called")
 	}
 	return &pb.CapabilitiesResponse{
-		// TODO: Parquet support?
-		BatchFileFormat: pb.BatchFileFormat_CSV,
+		BatchFileFormat: s.batchFileFormat,
 	}, nil
 }
 
@@ -169,12 +389,8 @@ func (s *Server) Test(ctx context.Context, req *pb.TestRequest) (*pb.TestRespons
 		s.LogSevere("Failed to connect to database", err,
 			"config_name", req.Name)
 
-		// For token expiration, provide a more helpful failure message with guidance
-		failureMsg := err.Error()
-		if errors.Is(err, ErrTokenExpired) {
-			failureMsg = "Authentication token has expired.\n\n" + TokenExpiredTaskMessage()
-		}
-
+		// For failures the user can fix, like expired tokens, provide a more helpful failure message with guidance
+		failureMsg, err := s.testFailure(err)
 		return &pb.TestResponse{
 			Response: &pb.TestResponse_Failure{
 				Failure: failureMsg,
@@ -182,6 +398,26 @@ func (s *Server) Test(ctx context.Context, req *pb.TestRequest) (*pb.TestRespons
 		}, err
 	}
 
+	if req.Name == tableConsistencyTest && cfg.verifyTable != "" {
+		report, err := s.Verify(ctx, req.Configuration, cfg.verifySchema, cfg.verifyTable)
+		if err != nil {
+			s.LogSevere("Failed to verify table", err,
+				"config_name", req.Name)
+			return &pb.TestResponse{
+				Response: &pb.TestResponse_Failure{
+					Failure: fmt.Sprintf("failed verifying table %s.%s: %v", cfg.verifySchema, cfg.verifyTable, err),
+				},
+			}, err
+		}
+		if !report.OK {
+			return &pb.TestResponse{
+				Response: &pb.TestResponse_Failure{
+					Failure: report.Summary(),
+				},
+			}, nil
+		}
+	}
+
 	s.LogDebug("Finished configuration test",
 		"config_name", req.Name,
 		"duration_ms", time.Since(startTime).Milliseconds())
@@ -208,29 +444,23 @@ func (s *Server) DescribeTable(ctx context.Context, req *pb.DescribeTableRequest
 			}, nil
 		}
 
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
-			return &pb.DescribeTableResponse{
-				Response: &pb.DescribeTableResponse_Task{
-					Task: NewTokenExpiredTask(),
-				},
-			}, err
-		}
-
-		return &pb.DescribeTableResponse{
-			Response: &pb.DescribeTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.describeTableFailure(err)
 	}
 
 	if s.Debugging() {
 		s.LogDebug("infoForTable result", "table_info", tb)
 	}
 
+	if len(tb.Unmapped) > 0 {
+		// The table was not created by the connector, and has fields Fivetran cannot sync to.
+		var fields []string
+		for _, c := range tb.Unmapped {
+			fields = append(fields, fmt.Sprintf("%s (%s)", c.Name, c.SDBType))
+		}
+		err := fmt.Errorf("table %s has fields of types not mappable to Fivetran data types: %s", req.TableName, strings.Join(fields, ", "))
+		return s.describeTableFailure(err)
+	}
+
 	if len(tb.Columns) == 0 {
 		// SurrealDB `INFO FOR TABLE` returns table info with empty events/fields/indexes/lives if
 		// the table does not exist yet, or the table has no fields defined yet.
@@ -246,14 +476,7 @@ func (s *Server) DescribeTable(ctx context.Context, req *pb.DescribeTableRequest
 
 	ftColumns, err := s.columnsFromSurrealToFivetran(tb.Columns)
 	if err != nil {
-		return &pb.DescribeTableResponse{
-			// notfound, table, warning, task
-			Response: &pb.DescribeTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.describeTableFailure(err)
 	}
 
 	return &pb.DescribeTableResponse{
@@ -268,7 +491,7 @@ func (s *Server) DescribeTable(ctx context.Context, req *pb.DescribeTableRequest
 }
 
 // CreateTable implements the CreateTable method required by the DestinationConnectorServer interface
-func (s *Server) CreateTable(ctx context.Context, req *pb.CreateTableRequest) (*pb.CreateTableResponse, error) {
+func (s *Server) CreateTable(ctx context.Context, req *pb.CreateTableRequest) (_ *pb.CreateTableResponse, err error) {
 	if s.Debugging() {
 		s.LogDebug("CreateTable called", "schema", req.SchemaName, "table", req.Table.Name)
 	}
@@ -284,53 +507,27 @@ func (s *Server) CreateTable(ctx context.Context, req *pb.CreateTableRequest) (*
 		}, err
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, req.SchemaName)
+	// Links and relations are configured by Fivetran table names, which the target table may not have.
+	opts := tablemapper.DefineOptions{
+		ScalarRecordIDs: cfg.scalarRecordIDs,
+		Links:           cfg.recordLinksFor(req.SchemaName, req.Table.Name),
+		Relation:        cfg.relationFor(req.SchemaName, req.Table.Name),
+	}
+	req = withTargetTable(cfg, req)
+
+	db, release, err := s.acquireDB(ctx, cfg, req.SchemaName)
 	if err != nil {
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
-			return &pb.CreateTableResponse{
-				Response: &pb.CreateTableResponse_Task{
-					Task: NewTokenExpiredTask(),
-				},
-			}, err
-		}
-		return &pb.CreateTableResponse{
-			// success, warning, task
-			Response: &pb.CreateTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.createTableFailure(err)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
-	if err := s.defineTable(ctx, db, req.Table); err != nil {
-		return &pb.CreateTableResponse{
-			// success, warning, task
-			Response: &pb.CreateTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	if err := s.defineTable(ctx, db, req.Table, opts); err != nil {
+		return s.createTableFailure(err)
 	}
 
-	tbInfo, err := s.infoForTable(ctx, req.SchemaName, req.Table.Name, req.Configuration)
+	tbInfo, err := s.tableInfo(ctx, db, req.Table.Name)
 	if err != nil {
-		return &pb.CreateTableResponse{
-			// success, warning, task
-			Response: &pb.CreateTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.createTableFailure(err)
 	}
 
 	if s.Debugging() {
@@ -346,7 +543,7 @@ func (s *Server) CreateTable(ctx context.Context, req *pb.CreateTableRequest) (*
 }
 
 // AlterTable implements the AlterTable method required by the DestinationConnectorServer interface
-func (s *Server) AlterTable(ctx context.Context, req *pb.AlterTableRequest) (*pb.AlterTableResponse, error) {
+func (s *Server) AlterTable(ctx context.Context, req *pb.AlterTableRequest) (_ *pb.AlterTableResponse, err error) {
 	if s.Debugging() {
 		s.LogDebug("AlterTable called", "schema", req.SchemaName, "table", req.Table.Name)
 	}
@@ -361,67 +558,49 @@ func (s *Server) AlterTable(ctx context.Context, req *pb.AlterTableRequest) (*pb
 		}, err
 	}
 
+	links := cfg.recordLinksFor(req.SchemaName, req.Table.Name)
+	req = withTargetTable(cfg, req)
+
 	if s.Debugging() {
 		s.LogDebug("AlterTable config", "config", cfg)
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, req.SchemaName)
+	db, release, err := s.acquireDB(ctx, cfg, req.SchemaName)
 	if err != nil {
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
-			return &pb.AlterTableResponse{
-				Response: &pb.AlterTableResponse_Task{
-					Task: NewTokenExpiredTask(),
-				},
-			}, err
-		}
-		return &pb.AlterTableResponse{
-			Response: &pb.AlterTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.alterTableFailure(err)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
-	if err := s.defineTable(ctx, db, req.Table); err != nil {
-		return &pb.AlterTableResponse{
-			Response: &pb.AlterTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	current, err := s.tableInfo(ctx, db, req.Table.Name)
+	if err != nil {
+		return s.alterTableFailure(err)
+	}
+
+	scalarRecordIDs, err := alteredScalarRecordIDs(current, req.Table)
+	if err != nil {
+		return s.alterTableFailure(err)
+	}
+
+	// Whether the table is a relation table is decided when the table is created, like the record ID layout.
+	opts := tablemapper.DefineOptions{
+		ScalarRecordIDs: scalarRecordIDs,
+		Links:           alteredRecordLinks(current, links),
+		Relation:        current.DefinedRelation(),
+	}
+	if err := s.defineTable(ctx, db, req.Table, opts); err != nil {
+		return s.alterTableFailure(err)
 	}
 
 	if req.DropColumns {
 		m := migrator.New(db, s.Logging)
 		if err := m.RemoveSurrealDBFieldsNotInFivetranTable(ctx, db, req.SchemaName, req.Table); err != nil {
-			return &pb.AlterTableResponse{
-				Response: &pb.AlterTableResponse_Warning{
-					Warning: &pb.Warning{
-						Message: err.Error(),
-					},
-				},
-			}, err
+			return s.alterTableFailure(err)
 		}
 	}
 
-	tbInfo, err := s.infoForTable(ctx, req.SchemaName, req.Table.Name, req.Configuration)
+	tbInfo, err := s.tableInfo(ctx, db, req.Table.Name)
 	if err != nil {
-		return &pb.AlterTableResponse{
-			Response: &pb.AlterTableResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.alterTableFailure(err)
 	}
 
 	if s.Debugging() {
@@ -450,8 +629,18 @@ func (s *Server) WriteHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 }
 
 func (s *Server) Migrate(ctx context.Context, req *pb.MigrateRequest) (*pb.MigrateResponse, error) {
-	if err := s.migrate(ctx, req); err != nil {
-		return nil, fmt.Errorf("migration failed: %w", err)
+	plan, err := s.migrate(ctx, req)
+	if err != nil {
+		return s.migrateFailure(fmt.Errorf("migration failed: %w", err))
+	}
+	if plan != nil {
+		return &pb.MigrateResponse{
+			Response: &pb.MigrateResponse_Task{
+				Task: &pb.Task{
+					Message: migrationPlanMessage(plan),
+				},
+			},
+		}, nil
 	}
 	return &pb.MigrateResponse{
 		Response: &pb.MigrateResponse_Success{
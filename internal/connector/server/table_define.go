@@ -2,13 +2,31 @@ package server
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	"github.com/surrealdb/surrealdb.go"
 )
 
-func (s *Server) defineTable(ctx context.Context, db *surrealdb.DB, table *pb.Table) error {
+func (s *Server) defineTable(ctx context.Context, db *surrealdb.DB, table *pb.Table, opts tablemapper.DefineOptions) error {
 	tm := tablemapper.New(db, s.Logging)
-	return tm.DefineTable(ctx, table)
+	return tm.DefineTable(ctx, table, opts)
+}
+
+// alteredScalarRecordIDs reports whether the existing table described by current, once altered to the definition,
+// keeps using scalar record IDs.
+//
+// The layout of the record IDs is decided when the table is created, regardless of the current configuration,
+// because the existing records would otherwise be left with the IDs of the other layout.
+func alteredScalarRecordIDs(current tablemapper.TableInfo, table *pb.Table) (bool, error) {
+	if !current.ScalarRecordIDs() {
+		return false, nil
+	}
+
+	if _, ok := tablemapper.ScalarRecordIDColumn(table); !ok {
+		return false, fmt.Errorf("table %s uses scalar record IDs, which require a single primary key column of an integer or string type", table.Name)
+	}
+
+	return true, nil
 }
@@ -5,31 +5,48 @@ import (
 
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"github.com/surrealdb/surrealdb.go"
 )
 
 // ErrTableNotFound is returned when a table is not found.
 var ErrTableNotFound = tablemapper.ErrTableNotFound
 
-func (s *Server) infoForTable(ctx context.Context, schemaName string, tableName string, configuration map[string]string) (tablemapper.TableInfo, error) {
+func (s *Server) infoForTable(ctx context.Context, schemaName string, tableName string, configuration map[string]string) (_ tablemapper.TableInfo, err error) {
 	cfg, err := s.parseConfig(configuration)
 	if err != nil {
 		return tablemapper.TableInfo{}, err
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, schemaName)
+	db, release, err := s.acquireDB(ctx, cfg, schemaName)
 	if err != nil {
 		return tablemapper.TableInfo{}, err
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
+	return s.tableInfo(ctx, db, cfg.schemaMapping.tableFor(schemaName, tableName))
+}
+
+// tableInfo returns the table info using the connection the caller already has,
+// so that we don't need another connection just for reading the table info.
+func (s *Server) tableInfo(ctx context.Context, db *surrealdb.DB, tableName string) (tablemapper.TableInfo, error) {
 	tm := tablemapper.New(db, s.Logging)
 	return tm.InfoForTable(ctx, tableName)
 }
 
+// writeTableInfo is like tableInfo, but also resolves the tables the link columns link to,
+// so that the values of the columns are written as the record IDs of the linked records.
+func (s *Server) writeTableInfo(ctx context.Context, db *surrealdb.DB, tableName string) (tablemapper.TableInfo, error) {
+	tm := tablemapper.New(db, s.Logging)
+	tb, err := tm.InfoForTable(ctx, tableName)
+	if err != nil {
+		return tablemapper.TableInfo{}, err
+	}
+	if err := tm.ResolveLinks(ctx, tableName, &tb); err != nil {
+		return tablemapper.TableInfo{}, err
+	}
+	return tb, nil
+}
+
 func (s *Server) columnsFromSurrealToFivetran(sColumns []tablemapper.ColumnInfo) ([]*pb.Column, error) {
 	return tablemapper.ColumnsFromSurrealToFivetran(sColumns)
 }
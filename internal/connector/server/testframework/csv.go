@@ -57,12 +57,18 @@ func CreateEncryptedCSV(t *testing.T, tempDir, filename string, columns []string
 	csvData, err := WriteCSVContent(columns, records)
 	require.NoError(t, err, "Failed to write CSV content")
 
+	return writeEncryptedFile(t, tempDir, filename, csvData, key)
+}
+
+// writeEncryptedFile compresses data with Zstd, encrypts it with AES-CBC, and writes it to a file
+// Following Fivetran's file format: IV (16 bytes) + AES-CBC encrypted (Zstd compressed data)
+func writeEncryptedFile(t *testing.T, tempDir, filename string, data []byte, key []byte) string {
 	// Compress with Zstd
 	compressed := bytes.NewBuffer(nil)
 	writer, err := zstd.NewWriter(compressed)
 	require.NoError(t, err, "Failed to create zstd writer")
 
-	_, err = writer.Write(csvData)
+	_, err = writer.Write(data)
 	require.NoError(t, err, "Failed to write data to zstd writer")
 
 	require.NoError(t, writer.Close(), "Failed to close zstd writer")
@@ -99,12 +105,12 @@ func CreateEncryptedCSV(t *testing.T, tempDir, filename string, columns []string
 
 	filePath := filepath.Join(tempDir, filename)
 	err = os.WriteFile(filePath, fileContent, 0644)
-	require.NoError(t, err, "Failed to write encrypted CSV file")
+	require.NoError(t, err, "Failed to write encrypted file")
 
 	return filePath
 }
 
-// CreateUnencryptedCSV creates a plain CSV file (for testing error cases)
+// CreateUnencryptedCSV creates a plain CSV file, which is read with GetUnencryptedFileParams
 func CreateUnencryptedCSV(t *testing.T, tempDir, filename string, columns []string, records [][]string) string {
 	csvData, err := WriteCSVContent(columns, records)
 	require.NoError(t, err, "Failed to write CSV content")
@@ -0,0 +1,23 @@
+package testframework
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+// CreateEncryptedParquet creates an AES-encrypted, Zstd-compressed Parquet file
+// with the same file format as CreateEncryptedCSV.
+//
+// The Parquet schema is derived from T, so declare the fields in the column order
+// with `parquet:"<column>,optional"` tags for nullable columns.
+// Use pointer fields to write nulls, and string fields for columns holding
+// the unmodified string.
+func CreateEncryptedParquet[T any](t *testing.T, tempDir, filename string, rows []T, key []byte) string {
+	var buf bytes.Buffer
+	require.NoError(t, parquet.Write(&buf, rows), "Failed to write Parquet content")
+
+	return writeEncryptedFile(t, tempDir, filename, buf.Bytes(), key)
+}
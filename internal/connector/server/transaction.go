@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// defaultTransactionChunkSize is the default number of rows committed by a single transaction
+// in transactional write mode.
+const defaultTransactionChunkSize = 1000
+
+// txQuery wraps the statements in a single transaction,
+// so that SurrealDB applies either all of them or none of them.
+func txQuery(statements string) string {
+	return "BEGIN TRANSACTION;\n" + statements + "\nCOMMIT TRANSACTION;"
+}
+
+// txWriter buffers write statements and commits them in transactions of about size rows.
+//
+// It is used by both writeBatch and writeHistoryBatch in transactional write mode,
+// so that a failure rolls back the rows written since the last commit,
+// and the rows committed before can be reported to Fivetran.
+//
+// SurrealDB transactions span a single query, so the buffered statements
+// are sent all at once on commit. Reads made by separate queries never see the buffered statements,
+// which is why history mode rows read the previous versions of their records within their own statements.
+type txWriter struct {
+	s    *Server
+	db   *surrealdb.DB
+	size int
+
+	statements []string
+	vars       map[string]any
+	// rows is the number of rows written by the buffered statements
+	rows int
+	// committed is the number of rows committed so far
+	committed int
+}
+
+func (s *Server) newTxWriter(db *surrealdb.DB) *txWriter {
+	return &txWriter{
+		s:    s,
+		db:   db,
+		size: s.transactionChunkSize,
+		vars: map[string]any{},
+	}
+}
+
+// txParamPattern matches the parameters in a statement, like $tb.
+var txParamPattern = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// Add buffers the statement with its parameters.
+//
+// The parameters are renamed so that they never collide with the other statements in the transaction.
+// Parameters not in vars, like $input, are left as they are.
+func (t *txWriter) Add(statement string, vars map[string]any) {
+	prefix := fmt.Sprintf("s%d_", len(t.statements))
+
+	statement = txParamPattern.ReplaceAllStringFunc(statement, func(param string) string {
+		if _, ok := vars[param[1:]]; !ok {
+			return param
+		}
+		return "$" + prefix + param[1:]
+	})
+	for k, v := range vars {
+		t.vars[prefix+k] = v
+	}
+
+	t.statements = append(t.statements, strings.TrimSpace(statement))
+}
+
+// Done records that the buffered statements have written n more rows,
+// and commits the transaction once it holds size rows or more.
+func (t *txWriter) Done(ctx context.Context, n int) error {
+	t.rows += n
+	if t.rows < t.size {
+		return nil
+	}
+	return t.Commit(ctx)
+}
+
+// Commit commits the buffered statements, if any, in a single transaction.
+//
+// On failure, SurrealDB rolls back the whole transaction,
+// and the buffered statements are discarded.
+// The transaction is retried on transient failures, like conflicts with concurrent transactions,
+// as it either rolled back or wrote the same records with the same values.
+func (t *txWriter) Commit(ctx context.Context) error {
+	if len(t.statements) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	statements, vars, rows := t.statements, t.vars, t.rows
+	t.Rollback()
+
+	query := txQuery(strings.Join(statements, "\n"))
+	err := t.s.retry.Do(ctx, func(ctx context.Context) error {
+		_, err := tracing.Query[any](ctx, t.db, query, vars)
+		return err
+	})
+	if err != nil {
+		if t.s.metrics != nil {
+			t.s.metrics.DBWriteError(metrics.LabelsFromContext(ctx))
+		}
+		return fmt.Errorf("transaction of %d statements writing %d rows rolled back: %w", len(statements), rows, err)
+	}
+
+	t.committed += rows
+
+	if t.s.metrics != nil {
+		t.s.metrics.DBBatchWriteCompleted(metrics.LabelsFromContext(ctx), int64(rows), time.Since(start))
+	}
+
+	if t.s.Debugging() {
+		t.s.LogDebug("Committed transaction", "statements", len(statements), "rows", rows, "committed", t.committed, "duration", time.Since(start))
+	}
+
+	return nil
+}
+
+// Rollback discards the buffered statements, which were never sent to SurrealDB.
+func (t *txWriter) Rollback() {
+	t.statements = nil
+	t.vars = map[string]any{}
+	t.rows = 0
+}
+
+// txErr discards the statements buffered to tx, if any,
+// and wraps the error failing the batch with how far the batch was committed,
+// so that the response to Fivetran tells exactly which rows were written.
+func txErr(tx *txWriter, err error) error {
+	if tx == nil {
+		return err
+	}
+	tx.Rollback()
+	return fmt.Errorf("%d rows were committed before the failure, and the rows after them were rolled back: %w", tx.committed, err)
+}
+
+// txRows wraps process so that tx, if any, counts each processed row,
+// and commits once it holds enough rows.
+func txRows(ctx context.Context, tx *txWriter, process func(columns []string, record []any) error) func(columns []string, record []any) error {
+	if tx == nil {
+		return process
+	}
+	return func(columns []string, record []any) error {
+		if err := process(columns, record); err != nil {
+			return err
+		}
+		return tx.Done(ctx, 1)
+	}
+}
+
+// execWrite runs the write statement right away, or buffers it to tx in transactional write mode.
+//
+// The statement is retried on transient failures. The callers only write records identified by their IDs
+// with values read from batch files, or delete them, which is the same when done again.
+func (s *Server) execWrite(ctx context.Context, db *surrealdb.DB, tx *txWriter, statement string, vars map[string]any) error {
+	if tx != nil {
+		tx.Add(statement, vars)
+		return nil
+	}
+
+	return s.retry.Do(ctx, func(ctx context.Context) error {
+		_, err := tracing.Query[any](ctx, db, statement, vars)
+		return err
+	})
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestTxWriter_AddRenamesParameters(t *testing.T) {
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	tx := srv.newTxWriter(nil)
+
+	tx.Add("UPSERT $thing CONTENT $content", map[string]any{"thing": "a", "content": 1})
+	tx.Add("INSERT INTO users $rows ON DUPLICATE KEY UPDATE name = $input.name", map[string]any{"rows": 2})
+
+	require.Equal(t, []string{
+		"UPSERT $s0_thing CONTENT $s0_content",
+		"INSERT INTO users $s1_rows ON DUPLICATE KEY UPDATE name = $input.name",
+	}, tx.statements)
+	require.Equal(t, map[string]any{"s0_thing": "a", "s0_content": 1, "s1_rows": 2}, tx.vars)
+
+	tx.Rollback()
+	require.Empty(t, tx.statements)
+	require.Empty(t, tx.vars)
+}
+
+func TestTxQuery(t *testing.T) {
+	require.Equal(t, "BEGIN TRANSACTION;\nDELETE $ids;\nCOMMIT TRANSACTION;", txQuery("DELETE $ids;"))
+}
+
+func TestWriteBatch_TransactionalRollsBackFailedChunk(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.writeTransactions = true
+	srv.transactionChunkSize = 2
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	// The first chunk of 2 rows is committed, and the row 4 fails the second chunk
+	columns := []string{"_fivetran_id", "name", "age", "active"}
+	records := [][]string{
+		{"user1", "Alice", "25", "true"},
+		{"user2", "Bob", "30", "false"},
+		{"user3", "Charlie", "35", "true"},
+		{"user4", "Dave", "not-a-number", "true"},
+	}
+	key, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	csvFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, records, key)
+
+	resp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{csvFile},
+		Keys:          map[string][]byte{csvFile: key},
+		FileParams:    testframework.GetTestFileParams(),
+	})
+	require.Error(t, err)
+	warning, ok := resp.Response.(*pb.WriteBatchResponse_Warning)
+	require.True(t, ok, "Expected WriteBatch warning response")
+	require.Contains(t, warning.Warning.Message, "2 rows were committed before the failure")
+	require.Contains(t, warning.Warning.Message, "failed to process row 4 of batch file")
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 2)
+	testframework.AssertRecordNotExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user3"})
+}
+
+func TestWriteBatch_SuccessTransactional(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.writeTransactions = true
+	srv.transactionChunkSize = 2
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	columns, records := createTestRecords()
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, records, replaceKey)
+
+	deleteKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	deleteFile := testframework.CreateEncryptedCSV(t, tempDir, "delete.csv", []string{"_fivetran_id"}, [][]string{{"user1"}}, deleteKey)
+
+	resp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		DeleteFiles:   []string{deleteFile},
+		Keys:          map[string][]byte{replaceFile: replaceKey, deleteFile: deleteKey},
+		FileParams:    testframework.GetTestFileParams(),
+	})
+	require.NoError(t, err)
+	success, ok := resp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+	require.True(t, success.Success)
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, len(records)-1)
+	testframework.AssertRecordNotExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user1"})
+}
+
+func TestWriteHistoryBatch_TransactionalRollsBackFailedChunk(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := buildHistoryTable()
+	schema := "test_writehistorybatch"
+	fileParams := testframework.GetTestFileParams()
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	endTime := "9999-12-31T23:59:59Z"
+	syncTime := time.Now().UTC().Format(time.RFC3339)
+	columns := []string{"_fivetran_id", "_fivetran_start", "_fivetran_end", "_fivetran_active", "_fivetran_synced", "name", "age", "active"}
+	startTime1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	startTime2 := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	versions := [][]string{
+		{"user1", startTime1.Format(time.RFC3339), startTime2.Add(-time.Millisecond).Format(time.RFC3339Nano), "false", syncTime, "Alice v1", "25", "true"},
+		{"user1", startTime2.Format(time.RFC3339), endTime, "true", syncTime, "Alice v2", "26", "true"},
+	}
+	key, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	versionsFile := testframework.CreateEncryptedCSV(t, tempDir, "versions.csv", columns, versions, key)
+
+	_, err = srv.WriteHistoryBatch(t.Context(), &pb.WriteHistoryBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{versionsFile},
+		Keys:          map[string][]byte{versionsFile: key},
+		FileParams:    fileParams,
+	})
+	require.NoError(t, err)
+
+	// The earliest start row removes the second version and deactivates the first one,
+	// but the replaced row fails the transaction, which rolls back both
+	srv.writeTransactions = true
+	srv.transactionChunkSize = 10
+
+	earliestKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	earliestFile := testframework.CreateEncryptedCSV(t, tempDir, "earliest.csv", []string{"_fivetran_id", "_fivetran_start"},
+		[][]string{{"user1", startTime2.Format(time.RFC3339)}}, earliestKey)
+
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns,
+		[][]string{{"user2", startTime1.Format(time.RFC3339), endTime, "true", syncTime, "Bob", "not-a-number", "true"}}, replaceKey)
+
+	resp, err := srv.WriteHistoryBatch(t.Context(), &pb.WriteHistoryBatchRequest{
+		Configuration:      config,
+		SchemaName:         schema,
+		Table:              table,
+		EarliestStartFiles: []string{earliestFile},
+		ReplaceFiles:       []string{replaceFile},
+		Keys:               map[string][]byte{earliestFile: earliestKey, replaceFile: replaceKey},
+		FileParams:         fileParams,
+	})
+	require.Error(t, err)
+	warning, ok := resp.Response.(*pb.WriteBatchResponse_Warning)
+	require.True(t, ok, "Expected WriteHistoryBatch warning response")
+	require.Contains(t, warning.Warning.Message, "0 rows were committed before the failure")
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 2)
+	active := assertActiveRecord(t, config, "test", schema, table.Name, "user1", models.CustomDateTime{Time: startTime2})
+	require.Equal(t, "Alice v2", active["name"])
+}
@@ -6,11 +6,22 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/migrator"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	"github.com/surrealdb/surrealdb.go"
+	"google.golang.org/protobuf/proto"
 )
 
-func (s *Server) truncate(ctx context.Context, req *pb.TruncateRequest) (*pb.TruncateResponse, error) {
+func (s *Server) truncate(ctx context.Context, req *pb.TruncateRequest) (_ *pb.TruncateResponse, err error) {
+	ctx = metrics.WithLabels(ctx, metrics.Labels{
+		RPC:    "Truncate",
+		Schema: req.SchemaName,
+		Table:  req.TableName,
+	})
+
 	if s.Debugging() {
 		s.LogDebug("Truncate called",
 			"schema", req.SchemaName,
@@ -33,55 +44,65 @@ func (s *Server) truncate(ctx context.Context, req *pb.TruncateRequest) (*pb.Tru
 	}
 	cfg, err := s.parseConfig(req.Configuration)
 	if err != nil {
-		return &pb.TruncateResponse{
-			Response: &pb.TruncateResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.truncateFailure(err)
+	}
+
+	if table := cfg.schemaMapping.tableFor(req.SchemaName, req.TableName); table != req.TableName {
+		req = proto.Clone(req).(*pb.TruncateRequest)
+		req.TableName = table
+	}
+
+	// Without UtcDeleteBefore, every record would match the truncation condition,
+	// so we treat it as an invalid request rather than deleting everything.
+	if req.UtcDeleteBefore == nil {
+		err = errors.New("truncate request is missing utc_delete_before")
+		return s.truncateFailure(err)
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, req.SchemaName)
+	db, release, err := s.acquireDB(ctx, cfg, req.SchemaName)
 	if err != nil {
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
+		return s.truncateFailure(err)
+	}
+	defer func() { release(err) }()
+
+	historyMode, err := s.isHistoryModeTable(ctx, db, req.TableName)
+	if err != nil {
+		if errors.Is(err, ErrTableNotFound) {
+			// Nothing to truncate
+			s.LogInfo("Skipping truncation of non-existent table", "schema", req.SchemaName, "table", req.TableName)
 			return &pb.TruncateResponse{
-				Response: &pb.TruncateResponse_Task{
-					Task: NewTokenExpiredTask(),
+				Response: &pb.TruncateResponse_Success{
+					Success: true,
 				},
-			}, err
+			}, nil
 		}
-		return &pb.TruncateResponse{
-			Response: &pb.TruncateResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.truncateFailure(err)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
 
-	if req.Soft != nil {
+	switch {
+	case req.Soft != nil && historyMode:
+		s.LogInfo("Doing a soft truncation of a history mode table",
+			"table", req.TableName,
+		)
+
+		err = s.softTruncateHistoryMode(ctx, db, req)
+	case req.Soft != nil:
 		// DeletedColumn is e.g. `_sivetran_deleted` which is bool-like column/field
 		s.LogInfo("Doing a soft truncation",
 			"soft.deletedColumn", req.Soft.DeletedColumn,
 		)
 
-		if err := s.softTruncate(ctx, db, req); err != nil {
-			return &pb.TruncateResponse{
-				Response: &pb.TruncateResponse_Warning{
-					Warning: &pb.Warning{
-						Message: err.Error(),
-					},
-				},
-			}, err
-		}
+		err = s.softTruncate(ctx, db, req)
+	default:
+		s.LogInfo("Doing a hard truncation",
+			"table", req.TableName,
+			"history_mode", historyMode,
+		)
+
+		err = s.hardTruncate(ctx, db, req)
+	}
+	if err != nil {
+		return s.truncateFailure(err)
 	}
 
 	return &pb.TruncateResponse{
@@ -91,10 +112,90 @@ func (s *Server) truncate(ctx context.Context, req *pb.TruncateRequest) (*pb.Tru
 	}, nil
 }
 
+// isHistoryModeTable returns true if the table has the _fivetran_start field,
+// which only exists in history mode tables.
+func (s *Server) isHistoryModeTable(ctx context.Context, db *surrealdb.DB, table string) (bool, error) {
+	tm := tablemapper.New(db, s.Logging)
+	tb, err := tm.InfoForTable(ctx, table)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range tb.Columns {
+		if c.Name == "_fivetran_start" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// truncateBatchSize is the number of records deleted or updated per transaction
+// by the hard truncation and the soft truncation of history mode tables.
+const truncateBatchSize = 1000
+
+// truncateCondition returns the condition matching the records synced before UtcDeleteBefore,
+// and the query parameters used by the condition.
+// The caller must ensure that UtcDeleteBefore is set.
+func truncateCondition(req *pb.TruncateRequest) (string, map[string]any) {
+	return "type::field($sc) <= type::datetime($utc)", map[string]any{
+		"sc":  req.SyncedColumn,
+		"utc": req.UtcDeleteBefore.AsTime().Format(time.RFC3339Nano),
+	}
+}
+
+// hardTruncate deletes the records synced before UtcDeleteBefore.
+//
+// In history mode, this deletes both active and inactive records,
+// as the history of the truncated records is no longer needed.
+//
+// Records are deleted in batches so that truncating a large table
+// does not end up with a single huge transaction.
+func (s *Server) hardTruncate(ctx context.Context, db *surrealdb.DB, req *pb.TruncateRequest) error {
+	condition, vars := truncateCondition(req)
+
+	m := migrator.New(db, s.Logging).WithRetry(s.retry)
+	deleted, err := m.BatchDeleteRecords(ctx, req.TableName, condition, truncateBatchSize, vars)
+	if err != nil {
+		return fmt.Errorf("failed to hard truncate: %w", err)
+	}
+
+	if s.Debugging() {
+		s.LogDebug("HardTruncate result", "deleted", deleted)
+	}
+
+	return nil
+}
+
+// softTruncateHistoryMode deactivates the active records synced before UtcDeleteBefore.
+//
+// History mode tables have no deleted column.
+// Instead, the active records are marked as inactive,
+// and their _fivetran_end is set to 1 millisecond before UtcDeleteBefore,
+// so that the history of the truncated records is kept.
+func (s *Server) softTruncateHistoryMode(ctx context.Context, db *surrealdb.DB, req *pb.TruncateRequest) error {
+	condition, vars := truncateCondition(req)
+	condition = "_fivetran_active = true AND " + condition
+
+	vars["end"] = req.UtcDeleteBefore.AsTime().Add(-time.Millisecond).Format(time.RFC3339Nano)
+
+	m := migrator.New(db, s.Logging).WithRetry(s.retry)
+	updated, err := m.BatchUpdateRecords(ctx, req.TableName, condition, "_fivetran_active = false, _fivetran_end = type::datetime($end)", truncateBatchSize, vars)
+	if err != nil {
+		return fmt.Errorf("failed to soft truncate history mode table: %w", err)
+	}
+
+	if s.Debugging() {
+		s.LogDebug("SoftTruncateHistoryMode result", "updated", updated)
+	}
+
+	return nil
+}
+
 func (s *Server) softTruncate(ctx context.Context, db *surrealdb.DB, req *pb.TruncateRequest) error {
 	deletedColumn := req.Soft.DeletedColumn
 
-	res, err := surrealdb.Query[any](ctx, db, "UPDATE type::table($tb) SET "+deletedColumn+" = true WHERE type::field($sc) <= type::datetime($utc)", map[string]interface{}{
+	res, err := tracing.Query[any](ctx, db, "UPDATE type::table($tb) SET "+deletedColumn+" = true WHERE type::field($sc) <= type::datetime($utc)", map[string]interface{}{
 		"tb":  req.TableName,
 		"dc":  deletedColumn,
 		"sc":  req.SyncedColumn,
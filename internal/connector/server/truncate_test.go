@@ -0,0 +1,141 @@
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/server/testframework"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestTruncate_HardTruncate(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := testframework.NewTableDefinition("users", map[string]pb.DataType{
+		"_fivetran_id":     pb.DataType_STRING,
+		"_fivetran_synced": pb.DataType_UTC_DATETIME,
+		"name":             pb.DataType_STRING,
+	}, []string{"_fivetran_id"})
+	schema := "test_truncate"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	columns := []string{"_fivetran_id", "_fivetran_synced", "name"}
+	records := [][]string{
+		{"user1", "2024-01-01T00:00:00Z", "Alice"},
+		{"user2", "2024-01-02T00:00:00Z", "Bob"},
+		{"user3", "2024-03-01T00:00:00Z", "Charlie"},
+	}
+	key, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	csvFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, records, key)
+
+	_, err = srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{csvFile},
+		Keys:          map[string][]byte{csvFile: key},
+		FileParams:    testframework.GetTestFileParams(),
+	})
+	require.NoError(t, err)
+
+	resp, err := srv.Truncate(t.Context(), &pb.TruncateRequest{
+		Configuration:   config,
+		SchemaName:      schema,
+		TableName:       table.Name,
+		SyncedColumn:    "_fivetran_synced",
+		UtcDeleteBefore: timestamppb.New(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+	})
+	require.NoError(t, err)
+	success, ok := resp.Response.(*pb.TruncateResponse_Success)
+	require.True(t, ok, "Expected Truncate success response")
+	require.True(t, success.Success)
+
+	// Only the record synced after the cutoff remains
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 1)
+	testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user3"},
+		map[string]interface{}{"name": "Charlie"})
+}
+
+func TestTruncate_SoftTruncateHistoryMode(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := buildHistoryTable()
+	schema := "test_truncate"
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	columns, records := createHistoryRecords(startTime)
+	key, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	csvFile := testframework.CreateEncryptedCSV(t, tempDir, "replace.csv", columns, records, key)
+
+	_, err = srv.WriteHistoryBatch(t.Context(), &pb.WriteHistoryBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{csvFile},
+		Keys:          map[string][]byte{csvFile: key},
+		FileParams:    testframework.GetTestFileParams(),
+	})
+	require.NoError(t, err)
+
+	deleteBefore := time.Now().UTC().Add(time.Hour)
+	resp, err := srv.Truncate(t.Context(), &pb.TruncateRequest{
+		Configuration:   config,
+		SchemaName:      schema,
+		TableName:       table.Name,
+		SyncedColumn:    "_fivetran_synced",
+		UtcDeleteBefore: timestamppb.New(deleteBefore),
+		Soft:            &pb.SoftTruncate{DeletedColumn: "_fivetran_deleted"},
+	})
+	require.NoError(t, err)
+	_, ok := resp.Response.(*pb.TruncateResponse_Success)
+	require.True(t, ok, "Expected Truncate success response")
+
+	// History is kept, but no record is active anymore
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 3)
+	for _, record := range testframework.QueryTable(t, config, "test", schema, table.Name) {
+		require.Equal(t, false, record["_fivetran_active"], "record %+v should be inactive", record)
+	}
+}
+
+func TestTruncate_FailureMissingUtcDeleteBefore(t *testing.T) {
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+
+	resp, err := srv.Truncate(t.Context(), &pb.TruncateRequest{
+		Configuration: config,
+		SchemaName:    "test_truncate",
+		TableName:     "users",
+		SyncedColumn:  "_fivetran_synced",
+	})
+	require.Error(t, err)
+	warning, ok := resp.Response.(*pb.TruncateResponse_Warning)
+	require.True(t, ok, "Expected Truncate warning response")
+	require.Contains(t, warning.Warning.Message, "utc_delete_before")
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+const (
+	// verifyBatchSize is the number of records read per query when verifying a table
+	verifyBatchSize = 1000
+	// verifyMaxExamples is the number of violations reported as examples per check
+	verifyMaxExamples = 10
+)
+
+// tableConsistencyTest is the name of the configuration test verifying the table of the verify_table option.
+const tableConsistencyTest = "table-consistency"
+
+// The names of the checks of a VerifyReport.
+const (
+	checkFieldTypes       = "field_types"
+	checkRecordIDs        = "record_ids"
+	checkActiveRows       = "active_rows"
+	checkHistoryIntervals = "history_intervals"
+)
+
+// VerifyReport is the result of checking that a table satisfies the invariants the connector maintains.
+type VerifyReport struct {
+	Schema   string `json:"schema"`
+	Table    string `json:"table"`
+	Database string `json:"database"`
+	// HistoryMode is whether the table is in history mode, which is checked for the history mode invariants
+	HistoryMode bool           `json:"history_mode"`
+	Records     int            `json:"records"`
+	OK          bool           `json:"ok"`
+	Checks      []*VerifyCheck `json:"checks"`
+}
+
+// VerifyCheck is the result of checking a single invariant.
+type VerifyCheck struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Violations int    `json:"violations"`
+	// Examples describe up to verifyMaxExamples violations, like the IDs of the offending records
+	Examples []string `json:"examples,omitempty"`
+}
+
+func (c *VerifyCheck) violate(format string, args ...any) {
+	c.OK = false
+	c.Violations++
+	if len(c.Examples) < verifyMaxExamples {
+		c.Examples = append(c.Examples, fmt.Sprintf(format, args...))
+	}
+}
+
+// Summary returns a single line describing the failed checks, or that all checks passed.
+func (r *VerifyReport) Summary() string {
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, fmt.Sprintf("%s (%d violations, like %s)", c.Name, c.Violations, c.Examples[0]))
+		}
+	}
+	if len(failed) == 0 {
+		return fmt.Sprintf("table %s passed all checks over %d records", r.Table, r.Records)
+	}
+	return fmt.Sprintf("table %s failed checks over %d records: %s", r.Table, r.Records, strings.Join(failed, "; "))
+}
+
+// Verify checks that the table of the schema satisfies the invariants the connector maintains:
+//   - the fields have the types the connector defines for their ColumnMeta
+//   - the record IDs are made of the values of the primary key columns
+//   - in history mode, each primary key has at most one active record,
+//     and the _fivetran_start/_fivetran_end intervals of its records never overlap
+//
+// The records are read in batches, in the order of their IDs, so that the records of a primary key
+// are read in the order of their _fivetran_start in history mode.
+func (s *Server) Verify(ctx context.Context, configuration map[string]string, schema, table string) (_ *VerifyReport, err error) {
+	cfg, err := s.parseConfig(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing verify config: %w", err)
+	}
+
+	db, release, err := s.acquireDB(ctx, cfg, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() { release(err) }()
+
+	tableName := cfg.schemaMapping.tableFor(schema, table)
+	info, err := s.tableInfo(ctx, db, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	v := newTableVerifier(info)
+	report := &VerifyReport{
+		Schema:      schema,
+		Table:       tableName,
+		Database:    cfg.schemaMapping.databaseFor(schema),
+		HistoryMode: v.historyMode,
+	}
+
+	fieldTypes := &VerifyCheck{Name: checkFieldTypes, OK: true}
+	for _, c := range info.Columns {
+		if c.Name != "id" && !fieldTypeMatches(c) {
+			fieldTypes.violate("field %s has type %s, but its Fivetran type is %s", c.Name, c.SDBType, c.FtType)
+		}
+	}
+	report.Checks = append(report.Checks, fieldTypes)
+
+	startID := models.NewRecordID(tableName, int64(math.MinInt64))
+	for {
+		results, err := tracing.Query[[]map[string]any](ctx, db, "SELECT * FROM type::table($tb) WHERE id > $start_id LIMIT $batch_size", map[string]any{
+			"tb":         tableName,
+			"start_id":   startID,
+			"batch_size": verifyBatchSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read records of %s: %w", tableName, err)
+		}
+		if results == nil || len(*results) == 0 || len((*results)[0].Result) == 0 {
+			break
+		}
+
+		for _, record := range (*results)[0].Result {
+			id, ok := record["id"].(models.RecordID)
+			if !ok {
+				return nil, fmt.Errorf("unexpected id type %T in %s", record["id"], tableName)
+			}
+			v.verifyRecord(id, record)
+			startID = id
+		}
+		report.Records += len((*results)[0].Result)
+	}
+
+	report.Checks = append(report.Checks, v.recordIDs)
+	if v.historyMode {
+		report.Checks = append(report.Checks, v.activeRows, v.historyIntervals)
+	}
+
+	report.OK = true
+	for _, c := range report.Checks {
+		report.OK = report.OK && c.OK
+	}
+
+	return report, nil
+}
+
+// fieldTypeMatches reports whether the SurrealDB type of the field is one the connector defines
+// for the Fivetran type of its ColumnMeta.
+func fieldTypeMatches(c tablemapper.ColumnInfo) bool {
+	if c.LinkTable != "" {
+		return c.SDBType == fmt.Sprintf("record<%s>", c.LinkTable)
+	}
+
+	sdb, _, _ := strings.Cut(c.SDBType, "<")
+	for _, m := range tablemapper.TypeMappings {
+		if m.FT == c.FtType && m.SDB == sdb && m.MaxDecimalPrecision >= c.DecimalPrecision {
+			return true
+		}
+	}
+	return false
+}
+
+// tableVerifier checks the records of a table one by one, in the order of their IDs.
+type tableVerifier struct {
+	pkColumns   []string
+	scalarIDs   bool
+	historyMode bool
+
+	recordIDs        *VerifyCheck
+	activeRows       *VerifyCheck
+	historyIntervals *VerifyCheck
+
+	// The primary key values, the number of active records, and the _fivetran_end of the last record read,
+	// whose records of the same primary key are read in the order of their _fivetran_start in history mode
+	lastPK     []any
+	lastActive int
+	lastEnd    time.Time
+}
+
+func newTableVerifier(info tablemapper.TableInfo) *tableVerifier {
+	v := &tableVerifier{
+		scalarIDs:        info.ScalarRecordIDs(),
+		recordIDs:        &VerifyCheck{Name: checkRecordIDs, OK: true},
+		activeRows:       &VerifyCheck{Name: checkActiveRows, OK: true},
+		historyIntervals: &VerifyCheck{Name: checkHistoryIntervals, OK: true},
+	}
+
+	historyFields := 0
+	for _, c := range info.Columns {
+		if c.FtPrimaryKey && c.Name != "_fivetran_start" {
+			v.pkColumns = append(v.pkColumns, c.Name)
+		}
+		switch c.Name {
+		case "_fivetran_start", "_fivetran_end", "_fivetran_active":
+			historyFields++
+		}
+	}
+	v.historyMode = historyFields == 3
+
+	// The record IDs of history mode tables end with _fivetran_start,
+	// which is not a primary key column of the tables migrated to history mode
+	if v.historyMode {
+		v.pkColumns = append(v.pkColumns, "_fivetran_start")
+	}
+
+	return v
+}
+
+func (v *tableVerifier) verifyRecord(id models.RecordID, record map[string]any) {
+	components, ok := id.ID.([]any)
+	if v.scalarIDs {
+		components, ok = []any{id.ID}, true
+	}
+	if !ok {
+		v.recordIDs.violate("record %s has no array record ID", id.String())
+		return
+	}
+
+	if len(components) != len(v.pkColumns) {
+		v.recordIDs.violate("record %s has %d record ID values, but the table has %d primary key columns %v",
+			id.String(), len(components), len(v.pkColumns), v.pkColumns)
+		return
+	}
+	for i, column := range v.pkColumns {
+		// The id column is never written as a field, as it is the record ID itself
+		if column == "id" {
+			continue
+		}
+		if !reflect.DeepEqual(components[i], record[column]) {
+			v.recordIDs.violate("record %s has %s = %v, which differs from its record ID", id.String(), column, record[column])
+			break
+		}
+	}
+
+	if v.historyMode {
+		v.verifyHistory(id, components[:len(components)-1], record)
+	}
+}
+
+func (v *tableVerifier) verifyHistory(id models.RecordID, pk []any, record map[string]any) {
+	samePK := v.lastPK != nil && reflect.DeepEqual(pk, v.lastPK)
+	if !samePK {
+		v.lastPK = pk
+		v.lastActive = 0
+	}
+
+	if active, _ := record["_fivetran_active"].(bool); active {
+		v.lastActive++
+		if v.lastActive == 2 {
+			v.activeRows.violate("primary key %v has more than one active record, like %s", pk, id.String())
+		}
+	}
+
+	start, startOK := historyTime(record["_fivetran_start"])
+	end, endOK := historyTime(record["_fivetran_end"])
+	if !startOK || !endOK {
+		v.historyIntervals.violate("record %s lacks _fivetran_start or _fivetran_end", id.String())
+		v.lastEnd = time.Time{}
+		return
+	}
+
+	switch {
+	case end.Before(start):
+		v.historyIntervals.violate("record %s ends at %s before it starts at %s", id.String(), end, start)
+	case samePK && !v.lastEnd.IsZero() && !v.lastEnd.Before(start):
+		v.historyIntervals.violate("record %s starts at %s before the previous record of primary key %v ends at %s", id.String(), start, pk, v.lastEnd)
+	}
+	v.lastEnd = end
+}
+
+// historyTime returns the time of a _fivetran_start or _fivetran_end value.
+func historyTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case models.CustomDateTime:
+		return t.Time, true
+	case time.Time:
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func historyTableInfo() tablemapper.TableInfo {
+	return tablemapper.TableInfo{Columns: []tablemapper.ColumnInfo{
+		{Name: "order_id", SDBType: "int", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 0, FtType: pb.DataType_INT, FtPrimaryKey: true}},
+		{Name: "status", SDBType: "string", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 1, FtType: pb.DataType_STRING}},
+		{Name: "_fivetran_start", SDBType: "datetime", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 2, FtType: pb.DataType_UTC_DATETIME, FtPrimaryKey: true}},
+		{Name: "_fivetran_end", SDBType: "datetime", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 3, FtType: pb.DataType_UTC_DATETIME}},
+		{Name: "_fivetran_active", SDBType: "bool", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 4, FtType: pb.DataType_BOOLEAN}},
+	}}
+}
+
+func historyRecord(orderID uint64, start, end time.Time, active bool) (models.RecordID, map[string]any) {
+	id := models.NewRecordID("orders", []any{orderID, models.CustomDateTime{Time: start}})
+	return id, map[string]any{
+		"id":               id,
+		"order_id":         orderID,
+		"_fivetran_start":  models.CustomDateTime{Time: start},
+		"_fivetran_end":    models.CustomDateTime{Time: end},
+		"_fivetran_active": active,
+	}
+}
+
+func TestTableVerifier_History(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	forever := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	v := newTableVerifier(historyTableInfo())
+	require.True(t, v.historyMode)
+	assert.Equal(t, []string{"order_id", "_fivetran_start"}, v.pkColumns)
+
+	// Order 1 has consistent versions
+	v.verifyRecord(historyRecord(1, t1, t2.Add(-time.Millisecond), false))
+	v.verifyRecord(historyRecord(1, t2, forever, true))
+	// Order 2 has two active versions, whose intervals overlap
+	v.verifyRecord(historyRecord(2, t1, forever, true))
+	v.verifyRecord(historyRecord(2, t2, forever, true))
+	// Order 3 has a record whose order_id differs from its record ID
+	id, record := historyRecord(3, t1, forever, true)
+	record["order_id"] = uint64(4)
+	v.verifyRecord(id, record)
+
+	assert.Equal(t, 1, v.activeRows.Violations)
+	assert.Equal(t, 1, v.historyIntervals.Violations)
+	assert.Equal(t, 1, v.recordIDs.Violations)
+	assert.Contains(t, v.recordIDs.Examples[0], "order_id = 4")
+}
+
+func TestTableVerifier_ScalarRecordIDs(t *testing.T) {
+	v := newTableVerifier(tablemapper.TableInfo{Columns: []tablemapper.ColumnInfo{
+		{Name: "id", SDBType: "int", ColumnMeta: tablemapper.ColumnMeta{FtType: pb.DataType_LONG, FtPrimaryKey: true, ScalarRecordID: true}},
+		{Name: "name", SDBType: "string", ColumnMeta: tablemapper.ColumnMeta{FtIndex: 1, FtType: pb.DataType_STRING}},
+	}})
+	require.False(t, v.historyMode)
+
+	id := models.NewRecordID("users", uint64(42))
+	v.verifyRecord(id, map[string]any{"id": id, "name": "a"})
+
+	assert.True(t, v.recordIDs.OK)
+}
+
+func TestFieldTypeMatches(t *testing.T) {
+	assert.True(t, fieldTypeMatches(tablemapper.ColumnInfo{Name: "n", SDBType: "int", ColumnMeta: tablemapper.ColumnMeta{FtType: pb.DataType_LONG}}))
+	assert.False(t, fieldTypeMatches(tablemapper.ColumnInfo{Name: "n", SDBType: "string", ColumnMeta: tablemapper.ColumnMeta{FtType: pb.DataType_LONG}}))
+	assert.True(t, fieldTypeMatches(tablemapper.ColumnInfo{Name: "c", SDBType: "record<customers>", ColumnMeta: tablemapper.ColumnMeta{FtType: pb.DataType_LONG, LinkTable: "customers"}}))
+	assert.False(t, fieldTypeMatches(tablemapper.ColumnInfo{Name: "c", SDBType: "int", ColumnMeta: tablemapper.ColumnMeta{FtType: pb.DataType_LONG, LinkTable: "customers"}}))
+}
+
+func TestVerifyReport_Summary(t *testing.T) {
+	report := &VerifyReport{Table: "orders", Records: 3, Checks: []*VerifyCheck{{Name: checkFieldTypes, OK: true}}}
+	assert.Equal(t, "table orders passed all checks over 3 records", report.Summary())
+
+	check := &VerifyCheck{Name: checkActiveRows, OK: true}
+	check.violate("primary key %v has more than one active record", []any{2})
+	report.Checks = append(report.Checks, check)
+	assert.Equal(t, "table orders failed checks over 3 records: active_rows (1 violations, like primary key [2] has more than one active record)", report.Summary())
+}
+
+func TestParseConfig_VerifyTable(t *testing.T) {
+	srv := New(zerolog.Nop())
+	configuration := map[string]string{"url": "ws://localhost:8000", "ns": "test", "user": "root", "pass": "root", "verify_table": "sales.orders"}
+
+	cfg, err := srv.parseConfig(configuration)
+	require.NoError(t, err)
+	assert.Equal(t, "sales", cfg.verifySchema)
+	assert.Equal(t, "orders", cfg.verifyTable)
+
+	configuration["verify_table"] = "orders"
+	_, err = srv.parseConfig(configuration)
+	assert.EqualError(t, err, "invalid verify_table: orders, expected schema.table")
+}
@@ -4,21 +4,30 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
-	"github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
-// Reads CSV files and replaces existing records accordingly.
-func (s *Server) handleReplaceFiles(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, replaceFiles []string, fileParams *pb.FileParams, keys map[string][]byte, table *pb.Table) error {
+// Reads batch files and replaces existing records accordingly.
+func (s *Server) handleReplaceFiles(ctx context.Context, conns *writeConns, tx *txWriter, fields map[string]tablemapper.ColumnInfo, replaceFiles []string, fileParams *pb.FileParams, keys map[string][]byte, table *pb.Table) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationReplace)
+
 	unmodifiedString := fileParams.UnmodifiedString
-	return s.processCSVRecords(replaceFiles, fileParams, keys, func(columns []string, record []string) error {
+
+	w := newRowWriter(ctx, s, conns, tx, bulkRowID, bulkRowSource, bulkWriteQuery(tableFields(fields)))
+
+	scalarIDs := scalarRecordIDs(fields)
+	edges := relationColumns(fields)
+	q := quarantineFrom(ctx)
+
+	err := s.processBatchFiles(ctx, replaceFiles, fileParams, keys, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Replacing record", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -28,9 +37,10 @@ func (s *Server) handleReplaceFiles(ctx context.Context, db *surrealdb.DB, field
 			return fmt.Errorf("unable to get primary key columns and values for record %v: %w", values, err)
 		}
 
-		// Always use array-based IDs (even for single-column primary keys)
-		// because our connector defines all table IDs as TYPE array<any>
-		thing := models.NewRecordID(table.Name, vals)
+		thing, err := newRecordID(table.Name, vals, scalarIDs)
+		if err != nil {
+			return err
+		}
 
 		vars := map[string]interface{}{}
 		for k, v := range values {
@@ -53,44 +63,68 @@ func (s *Server) handleReplaceFiles(ctx context.Context, db *surrealdb.DB, field
 				return fmt.Errorf("soft delete mode replace file: column %s not found in the table info: %v", k, fields)
 			}
 
-			if v == fileParams.NullString {
+			if isNullValue(v, fileParams) {
 				vars[k] = models.None
 				continue
 			}
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return err
+				return invalidRow(err)
 			}
 
 			vars[k] = typedV
 		}
 
-		res, err := surrealdb.Upsert[any](ctx, db, thing, vars)
-		if err != nil {
-			if s.metrics != nil {
-				s.metrics.DBWriteError()
-			}
-			s.LogDebug("Failed to upsert record", "thing", thing, "vars", fmt.Sprintf("%+v", vars), "error", err)
-			return fmt.Errorf("unable to upsert record %s: %w", thing, err)
-		}
-
-		// Track successful DB write
-		if s.metrics != nil {
-			s.metrics.DBWriteCompleted(1)
+		if s.Debugging() {
+			s.LogDebug("Replacing record", "commaSeparatedStringValues", values, "thing", thing, "vars", fmt.Sprintf("%+v", vars))
 		}
 
-		if s.Debugging() {
-			s.LogDebug("Replaced record", "commaSeparatedStringValues", values, "thing", thing, "vars", fmt.Sprintf("%+v", vars), "result", fmt.Sprintf("%+v", *res))
+		row := bulkRow{id: thing, content: vars, kind: bulkReplace, source: q.source()}
+		if edges != nil {
+			if row.edge, err = edges.edge(vars); err != nil {
+				return invalidRow(fmt.Errorf("replace file: record %s: %w", thing, err))
+			}
 		}
 
-		return nil
+		return w.Add(row)
 	})
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	return w.Flush()
+}
+
+// newRecordID returns the ID of the record with the primary key values.
+//
+// Tables defined with scalar record IDs use the only primary key value as is, like users:42,
+// while the others use the array of the values even for a single primary key column, like users:[42].
+func newRecordID(table string, pkValues []any, scalar bool) (models.RecordID, error) {
+	if !scalar {
+		return models.NewRecordID(table, pkValues), nil
+	}
+
+	if len(pkValues) != 1 {
+		return models.RecordID{}, fmt.Errorf("table %s uses scalar record IDs, which require a single primary key value, but got %d: %v", table, len(pkValues), pkValues)
+	}
+	return models.NewRecordID(table, pkValues[0]), nil
+}
+
+// scalarRecordIDs reports whether the table with the fields was defined to use scalar record IDs.
+func scalarRecordIDs(fields map[string]tablemapper.ColumnInfo) bool {
+	for _, f := range fields {
+		if f.ScalarRecordID {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *Server) getPKColumnsAndValues(strValues map[string]string, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]string, []any, error) {
+func (s *Server) getPKColumnsAndValues(strValues map[string]any, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]string, []any, error) {
 	var pkColumns []string
 	for _, c := range table.Columns {
 		if c.PrimaryKey {
@@ -122,9 +156,9 @@ func (s *Server) getPKColumnsAndValues(strValues map[string]string, table *pb.Ta
 			return nil, nil, fmt.Errorf("getPKColumnsAndValues: column %s not found in the table info: %v", pkColumn, fields)
 		}
 
-		typedV, err := f.StrToSurrealType(v)
+		typedV, err := f.ValueToSurrealType(v)
 		if err != nil {
-			return nil, nil, fmt.Errorf("getPKColumnsAndValues: %w", err)
+			return nil, nil, invalidRow(fmt.Errorf("getPKColumnsAndValues: %w", err))
 		}
 
 		pkValues = append(pkValues, typedV)
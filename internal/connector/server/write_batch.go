@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	"github.com/surrealdb/surrealdb.go"
-	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
-func (s *Server) writeBatch(ctx context.Context, req *pb.WriteBatchRequest) (*pb.WriteBatchResponse, error) {
+func (s *Server) writeBatch(ctx context.Context, req *pb.WriteBatchRequest) (_ *pb.WriteBatchResponse, err error) {
+	ctx = metrics.WithLabels(ctx, metrics.Labels{
+		RPC:    "WriteBatch",
+		Schema: req.SchemaName,
+		Table:  req.Table.Name,
+	})
+
 	if s.Debugging() {
 		s.LogDebug("WriteBatch called", "schema", req.SchemaName, "table", req.Table.Name, "config", req.Configuration)
 		s.LogDebug("Replace files", "count", len(req.ReplaceFiles))
@@ -37,44 +42,21 @@ func (s *Server) writeBatch(ctx context.Context, req *pb.WriteBatchRequest) (*pb
 		}, err
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, req.SchemaName)
+	req = withTargetTable(cfg, req)
+
+	db, release, err := s.acquireDB(ctx, cfg, req.SchemaName)
 	if err != nil {
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
-			return &pb.WriteBatchResponse{
-				Response: &pb.WriteBatchResponse_Task{
-					Task: NewTokenExpiredTask(),
-				},
-			}, err
-		}
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.writeBatchFailure(err, nil)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
 	if s.Debugging() {
 		s.LogDebug("WriteBatch using", "namespace", cfg.ns, "database", req.SchemaName)
 	}
 
-	tb, err := s.infoForTable(ctx, req.SchemaName, req.Table.Name, req.Configuration)
+	tb, err := s.writeTableInfo(ctx, db, req.Table.Name)
 	if err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.writeBatchFailure(err, nil)
 	}
 
 	fields := make(map[string]tablemapper.ColumnInfo)
@@ -82,34 +64,72 @@ func (s *Server) writeBatch(ctx context.Context, req *pb.WriteBatchRequest) (*pb
 		fields[column.Name] = column
 	}
 
-	if err := s.handleReplaceFiles(ctx, db, fields, req.ReplaceFiles, req.FileParams, req.Keys, req.Table); err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	// Additional connections for concurrent writers, if enabled.
+	// They are shared by the replace, update, and delete phases, and released once the batch is written.
+	//
+	// We never wait on the pool for them, because this RPC already holds a connection,
+	// and concurrent RPCs waiting for more connections while holding one could exhaust the pool.
+	// When the pool is full, the worker shares this RPC's connection instead,
+	// which is safe as the connection multiplexes concurrent requests.
+	connect := func(ctx context.Context) (*surrealdb.DB, releaseFunc, error) {
+		wdb, wrelease, err := s.tryAcquireDB(ctx, cfg, req.SchemaName)
+		if errors.Is(err, errConnPoolFull) {
+			if s.Debugging() {
+				s.LogDebug("Connection pool is full, sharing the batch connection with a write worker")
+			}
+			return db, func(error) {}, nil
+		}
+		return wdb, wrelease, err
+	}
+	conns := newWriteConns(db, connect)
+	defer conns.release()
+
+	// In transactional write mode, rows are committed in transactions of transactionChunkSize rows,
+	// so that a failure never leaves a chunk partially written.
+	var tx *txWriter
+	if s.writeTransactions {
+		tx = s.newTxWriter(db)
 	}
 
-	if err := s.batchUpdate(ctx, db, fields, req); err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	// In quarantine mode, the rows that can't be written are quarantined instead of failing the batch.
+	var q *quarantine
+	if cfg.quarantine {
+		q = s.newQuarantine(db, req.Table.Name)
+		ctx = withQuarantine(ctx, q)
+	}
+
+	// Note that each phase completes before the next one starts,
+	// so that replaces, updates, and deletes are applied in this order.
+	if err = s.handleReplaceFiles(ctx, conns, tx, fields, req.ReplaceFiles, req.FileParams, req.Keys, req.Table); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
+	}
+
+	if err = s.batchUpdate(ctx, conns, tx, fields, req); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
+	}
+
+	if err = s.batchDelete(ctx, conns, tx, fields, req); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
 	}
 
-	if err := s.batchDelete(ctx, db, fields, req); err != nil {
+	if tx != nil {
+		if err = tx.Commit(ctx); err != nil {
+			err = txErr(tx, err)
+			return s.writeBatchFailure(err, q)
+		}
+	}
+
+	if q != nil && q.Rejected() > 0 {
 		return &pb.WriteBatchResponse{
 			Response: &pb.WriteBatchResponse_Warning{
 				Warning: &pb.Warning{
-					Message: err.Error(),
+					Message: rejectedWarning(req.Table.Name, q.Rejected()),
 				},
 			},
-		}, err
+		}, nil
 	}
 
 	return &pb.WriteBatchResponse{
@@ -119,16 +139,24 @@ func (s *Server) writeBatch(ctx context.Context, req *pb.WriteBatchRequest) (*pb
 	}, nil
 }
 
-// Reads CSV files and updates existing records accordingly.
-func (s *Server) batchUpdate(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, req *pb.WriteBatchRequest) error {
+// Reads batch files and updates existing records accordingly.
+func (s *Server) batchUpdate(ctx context.Context, conns *writeConns, tx *txWriter, fields map[string]tablemapper.ColumnInfo, req *pb.WriteBatchRequest) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationUpdate)
+
 	unmodifiedString := req.FileParams.UnmodifiedString
 
-	return s.processCSVRecords(req.UpdateFiles, req.FileParams, req.Keys, func(columns []string, record []string) error {
+	w := newRowWriter(ctx, s, conns, tx, bulkRowID, bulkRowSource, bulkWriteQuery(tableFields(fields)))
+
+	scalarIDs := scalarRecordIDs(fields)
+	edges := relationColumns(fields)
+	q := quarantineFrom(ctx)
+
+	err := s.processBatchFiles(ctx, req.UpdateFiles, req.FileParams, req.Keys, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Updating record", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -148,9 +176,10 @@ func (s *Server) batchUpdate(ctx context.Context, db *surrealdb.DB, fields map[s
 			return fmt.Errorf("unable to get primary key columns and values for record %v: %w", values, err)
 		}
 
-		// Always use array-based IDs (even for single-column primary keys)
-		// because our connector defines all table IDs as TYPE array<any>
-		thing := models.NewRecordID(req.Table.Name, vals)
+		thing, err := newRecordID(req.Table.Name, vals, scalarIDs)
+		if err != nil {
+			return err
+		}
 
 		var hasUnmodifiedColumns bool
 
@@ -180,82 +209,55 @@ func (s *Server) batchUpdate(ctx context.Context, db *surrealdb.DB, fields map[s
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return fmt.Errorf("unable to convert value %s to surreal type %+v: %w", v, f, err)
+				return invalidRow(fmt.Errorf("unable to convert value %v to surreal type %+v: %w", v, f, err))
 			}
 
 			vars[k] = typedV
 		}
 
-		var res *any
-		if hasUnmodifiedColumns {
-			if s.Debugging() {
-				s.LogDebug("Doing upsert-merge to deal with unmodified columns in update with soft-delete sync mode", "thing", thing, "vars", vars)
-			}
-			var r any
-			r, err = s.upsertMerge(ctx, db, thing, vars)
-			if err != nil {
-				if s.metrics != nil {
-					s.metrics.DBWriteError()
-				}
-				return err
-			}
-			res = &r
-		} else {
-			res, err = surrealdb.Update[any](ctx, db, thing, vars)
-			if err != nil {
-				if s.metrics != nil {
-					s.metrics.DBWriteError()
-				}
-				return err
-			}
+		if s.Debugging() && hasUnmodifiedColumns {
+			s.LogDebug("Doing upsert-merge to deal with unmodified columns in update with soft-delete sync mode", "thing", thing, "vars", vars)
 		}
 
-		// Track successful DB write
-		if s.metrics != nil {
-			s.metrics.DBWriteCompleted(1)
+		// Records with unmodified columns are upsert-merged so that the unmodified columns are kept,
+		// while the others are updated with the whole content.
+		kind := bulkUpdate
+		if hasUnmodifiedColumns {
+			kind = bulkMerge
 		}
-
-		if s.Debugging() {
-			s.LogDebug("Updated record", "thing", thing, "vars", vars, "result", *res)
+		row := bulkRow{id: thing, content: vars, kind: kind, source: q.source()}
+		if edges != nil {
+			if row.edge, err = edges.edge(vars); err != nil {
+				return invalidRow(fmt.Errorf("update file: record %s: %w", thing, err))
+			}
 		}
 
-		return nil
+		return w.Add(row)
 	})
-}
-
-func (s *Server) upsertMerge(ctx context.Context, db *surrealdb.DB, thing models.RecordID, vars map[string]interface{}) (*[]surrealdb.QueryResult[any], error) {
-	var content string
-	for k := range vars {
-		content += fmt.Sprintf("%s: $%s, ", k, k)
-	}
-	content = strings.TrimSuffix(content, ", ")
-
-	varsWithTB := map[string]interface{}{
-		"tb": thing.Table,
-		"id": thing,
-	}
-	for k, v := range vars {
-		varsWithTB[k] = v
-	}
-
-	res, err := surrealdb.Query[any](ctx, db, `UPSERT type::thing($tb, $id) MERGE {`+content+`};`, varsWithTB)
 	if err != nil {
-		return nil, fmt.Errorf("unable to upsert merge record %s: %w", thing, err)
+		w.Abort()
+		return err
 	}
 
-	return res, nil
+	return w.Flush()
 }
 
-// Reads CSV files and deletes existing records accordingly.
-func (s *Server) batchDelete(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, req *pb.WriteBatchRequest) error {
-	return s.processCSVRecords(req.DeleteFiles, req.FileParams, req.Keys, func(columns []string, record []string) error {
+// Reads batch files and deletes existing records accordingly.
+func (s *Server) batchDelete(ctx context.Context, conns *writeConns, tx *txWriter, fields map[string]tablemapper.ColumnInfo, req *pb.WriteBatchRequest) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationDelete)
+
+	w := newRowWriter(ctx, s, conns, tx, recordID, nil, bulkDeleteQuery)
+
+	scalarIDs := scalarRecordIDs(fields)
+
+	err := s.processBatchFiles(ctx, req.DeleteFiles, req.FileParams, req.Keys, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Deleting record", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -265,27 +267,21 @@ func (s *Server) batchDelete(ctx context.Context, db *surrealdb.DB, fields map[s
 			return fmt.Errorf("unable to get primary key columns and values for record %v: %w", values, err)
 		}
 
-		// Always use array-based IDs (even for single-column primary keys)
-		// because our connector defines all table IDs as TYPE array<any>
-		thing := models.NewRecordID(req.Table.Name, vals)
-
-		_, err = surrealdb.Delete[any](ctx, db, thing)
+		thing, err := newRecordID(req.Table.Name, vals, scalarIDs)
 		if err != nil {
-			if s.metrics != nil {
-				s.metrics.DBWriteError()
-			}
-			return fmt.Errorf("unable to delete record %s: %w", thing, err)
-		}
-
-		// Track successful DB write (delete)
-		if s.metrics != nil {
-			s.metrics.DBWriteCompleted(1)
+			return err
 		}
 
 		if s.Debugging() {
-			s.LogDebug("Deleted record", "thing", thing)
+			s.LogDebug("Deleting record", "thing", thing)
 		}
 
-		return nil
+		return w.Add(thing)
 	})
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	return w.Flush()
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"os"
 	"testing"
 
@@ -95,6 +96,51 @@ func TestWriteBatch_SuccessReplaceSimple(t *testing.T) {
 		map[string]interface{}{"name": "Bob", "age": uint64(30), "active": false})
 }
 
+func TestWriteBatch_SuccessReplaceUnencrypted(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	// Create table
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	// Create plain CSV file, which needs no key
+	columns, records := createTestRecords()
+	csvFile := testframework.CreateUnencryptedCSV(t, tempDir, "replace_unencrypted.csv", columns, records)
+
+	// Execute WriteBatch
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{csvFile},
+		FileParams:    testframework.GetUnencryptedFileParams(),
+	})
+
+	// Assert success
+	require.NoError(t, err)
+	require.NotNil(t, batchResp)
+	success, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+	require.True(t, success.Success)
+
+	// Verify data in database
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 3)
+	testframework.AssertRecordExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user3"},
+		map[string]interface{}{"name": "Charlie", "age": uint64(35), "active": true})
+}
+
 func TestWriteBatch_SuccessReplaceMultiple(t *testing.T) {
 	tempDir, cleanup := setupWriteBatchTest(t)
 	defer cleanup()
@@ -924,6 +970,286 @@ func TestWriteBatch_SuccessSingleIDPK(t *testing.T) {
 	require.Equal(t, "Product C Updated", dbRecords[1]["name"])
 }
 
+func TestWriteBatch_SuccessScalarRecordIDs(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	schema := "test_writebatch"
+
+	scalarConfig := map[string]string{"record_ids": RecordIDsScalar}
+	for k, v := range config {
+		scalarConfig[k] = v
+	}
+
+	table := testframework.NewTableDefinition("scalar_products", map[string]pb.DataType{
+		"id":   pb.DataType_INT,
+		"name": pb.DataType_STRING,
+	}, []string{"id"})
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: scalarConfig,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	// The table keeps using scalar record IDs once altered without the option
+	table.Columns = append(table.Columns, &pb.Column{Name: "price", Type: pb.DataType_FLOAT})
+	_, err = srv.AlterTable(t.Context(), &pb.AlterTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+
+	replaceFile := testframework.CreateUnencryptedCSV(t, tempDir, "scalar_replace.csv", []string{"id", "name", "price"}, [][]string{
+		{"1", "Product A", "10.5"},
+		{"2", "Product B", "20.5"},
+	})
+	deleteFile := testframework.CreateUnencryptedCSV(t, tempDir, "scalar_delete.csv", []string{"id"}, [][]string{
+		{"2"},
+	})
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		DeleteFiles:   []string{deleteFile},
+		FileParams:    testframework.GetUnencryptedFileParams(),
+	})
+	require.NoError(t, err)
+	_, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+
+	records := testframework.QueryTable(t, config, "test", schema, table.Name)
+	require.Len(t, records, 1)
+	require.Equal(t, models.NewRecordID(table.Name, uint64(1)), records[0]["id"], "Record ID should be scalar_products:1")
+	require.Equal(t, "Product A", records[0]["name"])
+
+	// DescribeTable reports the same columns as the tables with array record IDs
+	describeResp, err := srv.DescribeTable(t.Context(), &pb.DescribeTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		TableName:     table.Name,
+	})
+	require.NoError(t, err)
+	described, ok := describeResp.Response.(*pb.DescribeTableResponse_Table)
+	require.True(t, ok, "Expected DescribeTable table response")
+	require.Len(t, described.Table.Columns, 3)
+	require.Equal(t, "id", described.Table.Columns[0].Name)
+	require.Equal(t, pb.DataType_INT, described.Table.Columns[0].Type)
+	require.True(t, described.Table.Columns[0].PrimaryKey)
+}
+
+func TestWriteBatch_SuccessRecordLinks(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	schema := "test_writebatch"
+
+	linkConfig := map[string]string{
+		"record_ids":   RecordIDsScalar,
+		"record_links": `{"link_orders": {"customer_id": "link_customers"}}`,
+	}
+	for k, v := range config {
+		linkConfig[k] = v
+	}
+
+	customers := testframework.NewTableDefinition("link_customers", map[string]pb.DataType{
+		"id":   pb.DataType_INT,
+		"name": pb.DataType_STRING,
+	}, []string{"id"})
+	orders := testframework.NewTableDefinition("link_orders", map[string]pb.DataType{
+		"order_id":    pb.DataType_STRING,
+		"customer_id": pb.DataType_INT,
+	}, []string{"order_id"})
+
+	for _, table := range []*pb.Table{customers, orders} {
+		_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+			Configuration: linkConfig,
+			SchemaName:    schema,
+			Table:         table,
+		})
+		require.NoError(t, err)
+		defer testframework.DropTable(t, config, "test", schema, table.Name)
+	}
+
+	replaceFile := testframework.CreateUnencryptedCSV(t, tempDir, "link_replace.csv", []string{"order_id", "customer_id"}, [][]string{
+		{"o1", "42"},
+	})
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         orders,
+		ReplaceFiles:  []string{replaceFile},
+		FileParams:    testframework.GetUnencryptedFileParams(),
+	})
+	require.NoError(t, err)
+	_, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+
+	// The link follows the scalar record IDs of the linked table
+	records := testframework.QueryTable(t, config, "test", schema, orders.Name)
+	require.Len(t, records, 1)
+	require.Equal(t, models.NewRecordID(customers.Name, uint64(42)), records[0]["customer_id"])
+
+	// DescribeTable reports the Fivetran type of the link column
+	describeResp, err := srv.DescribeTable(t.Context(), &pb.DescribeTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		TableName:     orders.Name,
+	})
+	require.NoError(t, err)
+	described, ok := describeResp.Response.(*pb.DescribeTableResponse_Table)
+	require.True(t, ok, "Expected DescribeTable table response")
+	for _, c := range described.Table.Columns {
+		if c.Name == "customer_id" {
+			require.Equal(t, pb.DataType_INT, c.Type)
+		}
+	}
+}
+
+func TestWriteBatch_SuccessRelationTable(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	schema := "test_writebatch"
+
+	relationConfig := map[string]string{
+		"relation_tables": `{"rel_order_items": {"in": {"column": "order_id", "table": "rel_orders"}, "out": {"column": "product_id", "table": "rel_products"}}}`,
+	}
+	for k, v := range config {
+		relationConfig[k] = v
+	}
+
+	orders := testframework.NewTableDefinition("rel_orders", map[string]pb.DataType{
+		"order_id": pb.DataType_INT,
+	}, []string{"order_id"})
+	products := testframework.NewTableDefinition("rel_products", map[string]pb.DataType{
+		"product_id": pb.DataType_STRING,
+	}, []string{"product_id"})
+	orderItems := testframework.NewTableDefinition("rel_order_items", map[string]pb.DataType{
+		"order_id":   pb.DataType_INT,
+		"product_id": pb.DataType_STRING,
+		"quantity":   pb.DataType_INT,
+	}, []string{"order_id", "product_id"})
+
+	for _, table := range []*pb.Table{orders, products, orderItems} {
+		_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+			Configuration: relationConfig,
+			SchemaName:    schema,
+			Table:         table,
+		})
+		require.NoError(t, err)
+		defer testframework.DropTable(t, config, "test", schema, table.Name)
+	}
+
+	columns := []string{"order_id", "product_id", "quantity"}
+	for i, rows := range [][][]string{{{"1", "p1", "2"}}, {{"1", "p1", "3"}}} {
+		replaceFile := testframework.CreateUnencryptedCSV(t, tempDir, fmt.Sprintf("relation_replace_%d.csv", i), columns, rows)
+		batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+			Configuration: config,
+			SchemaName:    schema,
+			Table:         orderItems,
+			ReplaceFiles:  []string{replaceFile},
+			FileParams:    testframework.GetUnencryptedFileParams(),
+		})
+		require.NoError(t, err)
+		_, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+		require.True(t, ok, "Expected WriteBatch success response")
+	}
+
+	// The edge relates the records identified by the relation columns, and is replaced in place
+	records := testframework.QueryTable(t, config, "test", schema, orderItems.Name)
+	require.Len(t, records, 1)
+	require.Equal(t, models.NewRecordID(orders.Name, []any{uint64(1)}), records[0]["in"])
+	require.Equal(t, models.NewRecordID(products.Name, []any{"p1"}), records[0]["out"])
+	require.Equal(t, uint64(3), records[0]["quantity"])
+
+	// DescribeTable only reports the Fivetran columns
+	describeResp, err := srv.DescribeTable(t.Context(), &pb.DescribeTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		TableName:     orderItems.Name,
+	})
+	require.NoError(t, err)
+	described, ok := describeResp.Response.(*pb.DescribeTableResponse_Table)
+	require.True(t, ok, "Expected DescribeTable table response")
+	require.Len(t, described.Table.Columns, len(orderItems.Columns))
+}
+
+func TestWriteBatch_SuccessQuarantine(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+
+	quarantineConfig := map[string]string{"quarantine_rejected_rows": "true"}
+	for k, v := range config {
+		quarantineConfig[k] = v
+	}
+
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+	defer testframework.DropTable(t, config, "test", schema, rejectedTable)
+
+	columns, records := createTestRecords()
+	records[1][2] = "thirty"
+	replaceFile := testframework.CreateUnencryptedCSV(t, tempDir, "quarantine_replace.csv", columns, records)
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: quarantineConfig,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		FileParams:    testframework.GetUnencryptedFileParams(),
+	})
+	require.NoError(t, err)
+	warning, ok := batchResp.Response.(*pb.WriteBatchResponse_Warning)
+	require.True(t, ok, "Expected WriteBatch warning response")
+	require.Contains(t, warning.Warning.Message, "1 rows of table users can't be written")
+
+	// The other rows are written, and the malformed row is quarantined with its raw values
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 2)
+	rejected := testframework.QueryTable(t, config, "test", schema, rejectedTable)
+	require.Len(t, rejected, 1)
+	require.Equal(t, table.Name, rejected[0]["table"])
+	require.Equal(t, replaceFile, rejected[0]["file"])
+	require.Equal(t, "thirty", rejected[0]["values"].(map[string]any)["age"])
+	require.NotEmpty(t, rejected[0]["error"])
+
+	// Retrying the batch, whose file is in another temporary directory, never quarantines the row twice
+	retryFile := testframework.CreateUnencryptedCSV(t, t.TempDir(), "quarantine_replace.csv", columns, records)
+	_, err = srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: quarantineConfig,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{retryFile},
+		FileParams:    testframework.GetUnencryptedFileParams(),
+	})
+	require.NoError(t, err)
+	rejected = testframework.QueryTable(t, config, "test", schema, rejectedTable)
+	require.Len(t, rejected, 1)
+	require.Equal(t, retryFile, rejected[0]["file"])
+}
+
 func TestWriteBatch_FailureEmptyCSV(t *testing.T) {
 	tempDir, cleanup := setupWriteBatchTest(t)
 	defer cleanup()
@@ -969,3 +1295,97 @@ func TestWriteBatch_FailureEmptyCSV(t *testing.T) {
 	// Verify no records were inserted
 	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 0)
 }
+
+// userParquetRow is a row of buildUserTable in Parquet batch files.
+// Name is a string so that it can hold the unmodified string in update files.
+type userParquetRow struct {
+	FivetranID string  `parquet:"_fivetran_id"`
+	Name       *string `parquet:"name,optional"`
+	Age        *int64  `parquet:"age,optional"`
+	Active     *bool   `parquet:"active,optional"`
+}
+
+type userParquetDeleteRow struct {
+	FivetranID string `parquet:"_fivetran_id"`
+}
+
+func TestWriteBatch_SuccessParquet(t *testing.T) {
+	tempDir, cleanup := setupWriteBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.batchFileFormat = pb.BatchFileFormat_PARQUET
+	config := testframework.GetSurrealDBConfig()
+	table := buildUserTable()
+	schema := "test_writebatch"
+	fileParams := testframework.GetTestFileParams()
+
+	// Create table
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	str := func(s string) *string { return &s }
+	i64 := func(i int64) *int64 { return &i }
+	boolean := func(b bool) *bool { return &b }
+
+	replaceRows := []userParquetRow{
+		{FivetranID: "user1", Name: str("Alice"), Age: i64(25), Active: boolean(true)},
+		{FivetranID: "user2", Name: str("Bob"), Age: i64(30), Active: boolean(false)},
+		{FivetranID: "user3", Name: str("Charlie"), Age: i64(35), Active: boolean(true)},
+	}
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedParquet(t, tempDir, "replace.parquet", replaceRows, replaceKey)
+
+	updateRows := []userParquetRow{
+		// The unmodified name is kept, and the null active is removed
+		{FivetranID: "user1", Name: str(fileParams.UnmodifiedString), Age: i64(26)},
+		// Every column is updated, including the null age
+		{FivetranID: "user2", Name: str("Bobby"), Active: boolean(true)},
+	}
+	updateKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	updateFile := testframework.CreateEncryptedParquet(t, tempDir, "update.parquet", updateRows, updateKey)
+
+	deleteRows := []userParquetDeleteRow{{FivetranID: "user3"}}
+	deleteKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	deleteFile := testframework.CreateEncryptedParquet(t, tempDir, "delete.parquet", deleteRows, deleteKey)
+
+	batchResp, err := srv.WriteBatch(t.Context(), &pb.WriteBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		UpdateFiles:   []string{updateFile},
+		DeleteFiles:   []string{deleteFile},
+		Keys:          map[string][]byte{replaceFile: replaceKey, updateFile: updateKey, deleteFile: deleteKey},
+		FileParams:    fileParams,
+	})
+	require.NoError(t, err)
+	success, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteBatch success response")
+	require.True(t, success.Success)
+
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 2)
+	testframework.AssertRecordNotExists(t, config, "test", schema, table.Name,
+		map[string]interface{}{"_fivetran_id": "user3"})
+
+	records := map[string]map[string]interface{}{}
+	for _, record := range testframework.QueryTable(t, config, "test", schema, table.Name) {
+		records[record["_fivetran_id"].(string)] = record
+	}
+
+	require.Equal(t, "Alice", records["user1"]["name"])
+	require.Equal(t, uint64(26), records["user1"]["age"])
+	require.Nil(t, records["user1"]["active"], "active should be NULL")
+
+	require.Equal(t, "Bobby", records["user2"]["name"])
+	require.Nil(t, records["user2"]["age"], "age should be NULL")
+	require.Equal(t, true, records["user2"]["active"])
+}
@@ -2,11 +2,12 @@ package server
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
 	"github.com/surrealdb/fivetran-destination/internal/connector/tablemapper"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	"github.com/surrealdb/surrealdb.go"
@@ -14,7 +15,17 @@ import (
 )
 
 // writeHistoryBatch handles the WriteHistoryBatch request from Fivetran.
-func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatchRequest) (*pb.WriteBatchResponse, error) {
+//
+// Unlike writeBatch, rows are always written one by one on the batch's connection,
+// ignoring the write concurrency, because each row reads and deactivates
+// the previous version of its record before writing the new version.
+func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatchRequest) (_ *pb.WriteBatchResponse, err error) {
+	ctx = metrics.WithLabels(ctx, metrics.Labels{
+		RPC:    "WriteHistoryBatch",
+		Schema: req.SchemaName,
+		Table:  req.Table.Name,
+	})
+
 	if s.Debugging() {
 		s.LogDebug("WriteHistoryBatch called", "schema", req.SchemaName, "table", req.Table.Name)
 		s.LogDebug("Earliest start files", "count", len(req.EarliestStartFiles))
@@ -39,44 +50,21 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 		}, err
 	}
 
-	db, err := s.connectAndUse(ctx, cfg, req.SchemaName)
+	req = withTargetTable(cfg, req)
+
+	db, release, err := s.acquireDB(ctx, cfg, req.SchemaName)
 	if err != nil {
-		// Check for token expiration - return Task instead of Warning
-		if errors.Is(err, ErrTokenExpired) {
-			s.LogSevere("Authentication token expired", err, "schema", req.SchemaName)
-			return &pb.WriteBatchResponse{
-				Response: &pb.WriteBatchResponse_Task{
-					Task: NewTokenExpiredTask(),
-				},
-			}, err
-		}
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.writeBatchFailure(err, nil)
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			s.LogWarning("failed to close db", err)
-		}
-	}()
+	defer func() { release(err) }()
 
 	if s.Debugging() {
 		s.LogDebug("WriteHistoryBatch using", "namespace", cfg.ns, "database", req.SchemaName)
 	}
 
-	tb, err := s.infoForTable(ctx, req.SchemaName, req.Table.Name, req.Configuration)
+	tb, err := s.writeTableInfo(ctx, db, req.Table.Name)
 	if err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+		return s.writeBatchFailure(err, nil)
 	}
 
 	fields := make(map[string]tablemapper.ColumnInfo)
@@ -84,6 +72,23 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 		fields[column.Name] = column
 	}
 
+	// In transactional write mode, rows are committed in transactions of transactionChunkSize rows.
+	// Each row reads the previous version of its record in the statements buffered to the transaction,
+	// so that a failure never leaves a row partially written.
+	var tx *txWriter
+	if s.writeTransactions {
+		tx = s.newTxWriter(db)
+	}
+
+	// In quarantine mode, the rows that can't be converted are quarantined instead of failing the batch.
+	// Unlike writeBatch, a row SurrealDB rejects still fails the batch,
+	// because a history mode row may have been written partially by then.
+	var q *quarantine
+	if cfg.quarantine {
+		q = s.newQuarantine(db, req.Table.Name)
+		ctx = withQuarantine(ctx, q)
+	}
+
 	if s.Debugging() {
 		s.LogDebug("Batch processing earliest start files")
 	}
@@ -91,14 +96,9 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 	// Implements https://github.com/fivetran/fivetran_partner_sdk/blob/main/how-to-handle-history-mode-batch-files.md#earliest_start_files
 	//
 	// See "EARLIEST START FILE" in https://github.com/fivetran/fivetran_partner_sdk/blob/main/history_mode.png
-	if err := s.handleHistoryModeEarliestStartFiles(ctx, db, fields, req); err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	if err = s.handleHistoryModeEarliestStartFiles(ctx, db, tx, fields, req); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
 	}
 
 	if s.Debugging() {
@@ -108,14 +108,9 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 	// Implements https://github.com/fivetran/fivetran_partner_sdk/blob/main/how-to-handle-history-mode-batch-files.md#replace_files
 	//
 	// We assume this corresponds to "UPSERT BATCH FILE" in https://github.com/fivetran/fivetran_partner_sdk/blob/main/history_mode.png
-	if err := s.handleHistoryModeReplaceFiles(ctx, db, fields, req.ReplaceFiles, req.FileParams, req.Keys, req.Table); err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	if err = s.handleHistoryModeReplaceFiles(ctx, db, tx, fields, req.ReplaceFiles, req.FileParams, req.Keys, req.Table); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
 	}
 
 	if s.Debugging() {
@@ -125,14 +120,9 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 	// Implements https://github.com/fivetran/fivetran_partner_sdk/blob/main/how-to-handle-history-mode-batch-files.md#update_files
 	//
 	// We assume this corresponds to "UPDATE BATCH FILE" in https://github.com/fivetran/fivetran_partner_sdk/blob/main/history_mode.png
-	if err := s.handleHistoryModeUpdateFiles(ctx, db, fields, req); err != nil {
-		return &pb.WriteBatchResponse{
-			Response: &pb.WriteBatchResponse_Warning{
-				Warning: &pb.Warning{
-					Message: err.Error(),
-				},
-			},
-		}, err
+	if err = s.handleHistoryModeUpdateFiles(ctx, db, tx, fields, req); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
 	}
 
 	if s.Debugging() {
@@ -144,14 +134,26 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 	// TODO We probably need to have handleDeleteFiles specifically for DeleteFiles
 	// Once that's done this will correspond to "DELETE BATCH FILE" in
 	// https://github.com/fivetran/fivetran_partner_sdk/blob/main/history_mode.png
-	if err := s.handleHistoryModeDeleteFiles(ctx, db, fields, req); err != nil {
+	if err = s.handleHistoryModeDeleteFiles(ctx, db, tx, fields, req); err != nil {
+		err = txErr(tx, err)
+		return s.writeBatchFailure(err, q)
+	}
+
+	if tx != nil {
+		if err = tx.Commit(ctx); err != nil {
+			err = txErr(tx, err)
+			return s.writeBatchFailure(err, q)
+		}
+	}
+
+	if q != nil && q.Rejected() > 0 {
 		return &pb.WriteBatchResponse{
 			Response: &pb.WriteBatchResponse_Warning{
 				Warning: &pb.Warning{
-					Message: err.Error(),
+					Message: rejectedWarning(req.Table.Name, q.Rejected()),
 				},
 			},
-		}, err
+		}, nil
 	}
 
 	return &pb.WriteBatchResponse{
@@ -161,13 +163,15 @@ func (s *Server) writeHistoryBatch(ctx context.Context, req *pb.WriteHistoryBatc
 	}, nil
 }
 
-func (s *Server) handleHistoryModeEarliestStartFiles(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
-	return s.processCSVRecords(req.EarliestStartFiles, req.FileParams, req.Keys, func(columns []string, record []string) error {
+func (s *Server) handleHistoryModeEarliestStartFiles(ctx context.Context, db *surrealdb.DB, tx *txWriter, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationEarliestStart)
+
+	return s.processBatchFiles(ctx, req.EarliestStartFiles, req.FileParams, req.Keys, txRows(ctx, tx, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Processing earliest start file", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -193,15 +197,17 @@ func (s *Server) handleHistoryModeEarliestStartFiles(ctx context.Context, db *su
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return fmt.Errorf("earliest start file: %w", err)
+				return invalidRow(fmt.Errorf("earliest start file: %w", err))
 			}
 
 			vars[k] = typedV
 		}
 
-		vars["tb"] = req.Table.Name
+		// Now find the latest remaining record to deactivate, in the same query as the DELETE,
+		// so that it sees the records removed by the DELETE.
+		latest, queryVars, byID := s.latestHistoryRecordQuery("id", cols, pkVals, req.Table.Name)
 
 		// Use range query approach for all PK columns
 		// This works for any PK column name (id, _fivetran_id, user_id, etc.)
@@ -214,8 +220,11 @@ func (s *Server) handleHistoryModeEarliestStartFiles(ctx context.Context, db *su
 		// Upper bound: [pk_values..., max_timestamp] (already set by buildRecordIDRangeQueryBounds)
 		earliestStart := vars["_fivetran_start"].(models.CustomDateTime)
 		lowerWithStart := append(rangeConfig.lowerBound, earliestStart)
-		vars["lower"] = lowerWithStart
-		vars["upper"] = rangeConfig.upperBound
+		queryVars["earliest"] = lowerWithStart
+		queryVars["upper"] = rangeConfig.upperBound
+		queryVars["_fivetran_end"] = models.CustomDateTime{
+			Time: earliestStart.Add(-time.Millisecond),
+		}
 
 		if s.Debugging() {
 			var lowerTypes, upperTypes []string
@@ -237,122 +246,24 @@ func (s *Server) handleHistoryModeEarliestStartFiles(ctx context.Context, db *su
 		// We skip creating pkcol index for tables with "id" as PK to avoid a potential SurrealDB bug
 		// where direct DELETE with range comparisons fails when indexes exist on the table.
 		// The range [pk, earliest_start] to [pk, max_timestamp] captures exactly the records to delete
-		deleteQuery := `DELETE FROM type::table($tb) WHERE id >= type::thing($tb, $lower) AND id < type::thing($tb, $upper);`
+		//
+		// If no records remain after the removal, there is nothing to deactivate.
+		query := "DELETE FROM type::table($tb) WHERE id >= type::thing($tb, $earliest) AND id < type::thing($tb, $upper);\n" +
+			withLatestHistoryRecord(latest, "UPDATE $_latest.id SET _fivetran_active = false, _fivetran_end = $_fivetran_end RETURN NONE;")
 
-		res, err := surrealdb.Query[any](ctx, db, deleteQuery, vars)
-		if err != nil {
-			return fmt.Errorf("unable to delete from table %s: %w", req.Table.Name, err)
+		if err := s.execWrite(ctx, db, tx, query, queryVars); err != nil {
+			return fmt.Errorf("unable to remove records from table %s where %s and _fivetran_start >= %v, and deactivate the latest remaining one: %w", req.Table.Name, byID, earliestStart, err)
 		}
 
 		if s.Debugging() {
-			s.LogDebug("Removed records", "byID", rangeConfig.byID, "_fivetran_start_gte", vars["_fivetran_start"], "result", *res)
-		}
-
-		// Now find the latest remaining record to deactivate
-		// Use selectLatestHistoryRecord which handles both "id" column and standard cases
-		result, byID, err := s.selectLatestHistoryRecord(
-			ctx, db,
-			"_fivetran_start, id",
-			nil,
-			cols, pkVals,
-			req.Table.Name,
-		)
-		if err != nil {
-			// Check if the error is "got empty query response" which means no records remain
-			if strings.Contains(err.Error(), "got empty query response") {
-				if s.Debugging() {
-					s.LogDebug("No existing records remain after earliest_start removal, skipping update to set _fivetran_active and _fivetran_end", "byID", byID)
-				}
-				return nil
-			}
-			return fmt.Errorf("unable to select latest record from table %s: %w", req.Table.Name, err)
-		}
-
-		if s.Debugging() {
-			s.LogDebug("Selected latest record", "byID", byID, "result", result)
-		}
-
-		if len(result) == 0 {
-			// No existing records remain, nothing to do.
-			if s.Debugging() {
-				s.LogDebug("No existing records remain after earliest_start removal, skipping update to set _fivetran_active and _fivetran_end", "byID", byID)
-			}
-			return nil
-		}
-
-		// Extract the actual RecordID from the query result
-		recordIDVal, ok := result[0]["id"]
-		if !ok {
-			return fmt.Errorf("id not found in the selected record: %v", result[0])
-		}
-
-		updatedRecordID, ok := recordIDVal.(models.RecordID)
-		if !ok {
-			return fmt.Errorf("unexpected type for id: %T, expected models.RecordID", recordIDVal)
-		}
-
-		endTime := models.CustomDateTime{
-			Time: earliestStart.Add(-time.Millisecond),
-		}
-
-		res, err = surrealdb.Query[any](
-			ctx,
-			db,
-			"UPDATE type::thing($record_id) SET _fivetran_active = false, _fivetran_end = $_fivetran_end",
-			map[string]any{
-				"record_id":     updatedRecordID,
-				"_fivetran_end": endTime,
-			},
-		)
-		if err != nil {
-			return fmt.Errorf("unable to update record %v: %w", updatedRecordID, err)
-		}
-
-		if s.Debugging() {
-			s.LogDebug("Updated records to set _fivetran_active=false and _fivetran_end=_fivetran_start-1ms", "_fivetran_start", vars["_fivetran_start"], "result", *res)
+			s.LogDebug("Removed records, and updated the latest remaining one to set _fivetran_active=false and _fivetran_end=_fivetran_start-1ms", "byID", byID, "_fivetran_start_gte", earliestStart)
 		}
 
 		return nil
-	})
-}
-
-func (s *Server) getPKColumnsAndValuesTyped(values map[string]any, table *pb.Table) ([]string, []any, error) {
-	var pkColumns []string
-	for _, c := range table.Columns {
-		if c.PrimaryKey {
-			pkColumns = append(pkColumns, c.Name)
-		}
-	}
-
-	if s.Debugging() {
-		s.LogDebug("getPKColumnsAndValuesTyped: primary key columns", "table", table.Name, "pkColumns", pkColumns)
-	}
-
-	// Note that we intentionally do not sort the primary key columns.
-	// We assume Fivetran in history mode sends us primary key columns containing the primary key in the source
-	// along with _fivetran_start.
-	// In that case, I want to use [id_from_src, _fivetran_start] as the primary key assumng
-	// Fivetran gives us columns definitions in this specific order.
-	// If we sorted it like the below, we might end up with [_fivetran_start, id_from_src] as the primary key.
-	// That's not wrong but I think it's not intuitive from users perspective.
-	//
-	// sort.Slice(pkColumns, func(i, j int) bool {
-	// 	return pkColumns[i] < pkColumns[j]
-	// })
-
-	var pkValues []any
-	for _, pkColumn := range pkColumns {
-		v, ok := values[pkColumn]
-		if !ok {
-			return nil, nil, fmt.Errorf("primary key column %s not found in record values: %v", pkColumn, values)
-		}
-		pkValues = append(pkValues, v)
-	}
-
-	return pkColumns, pkValues, nil
+	}))
 }
 
-func (s *Server) generateIdArray(values map[string]string, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]any, error) {
+func (s *Server) generateIdArray(values map[string]any, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]any, error) {
 	_, vals, err := s.getPKColumnsAndValues(values, table, fields)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get primary key columns and values for record %v: %w", values, err)
@@ -361,38 +272,18 @@ func (s *Server) generateIdArray(values map[string]string, table *pb.Table, fiel
 	return vals, nil
 }
 
-func (s *Server) generateIdArrayForDelete(values map[string]string, table *pb.Table, fields map[string]tablemapper.ColumnInfo, fivetranStartDefault *models.CustomDateTime) ([]any, error) {
-	_, vals, err := s.parsePrimaryKeyValuesExceptFivetranStart(values, table, fields)
-	if err != nil {
-		return nil, fmt.Errorf("generateIdArrayForDelete: unable to get primary key columns and values for record %v: %w", values, err)
-	}
-
-	// Override _fivetran_start value to make the composite key
-	vals = append(vals, fivetranStartDefault)
-
-	return vals, nil
-}
+// Reads batch files and replaces existing records accordingly.
+func (s *Server) handleHistoryModeReplaceFiles(ctx context.Context, db *surrealdb.DB, tx *txWriter, fields map[string]tablemapper.ColumnInfo, replaceFiles []string, fileParams *pb.FileParams, keys map[string][]byte, table *pb.Table) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationReplace)
 
-func (s *Server) generateIdArrayTyped(values map[string]any, table *pb.Table) (*models.RecordID, error) {
-	_, vals, err := s.getPKColumnsAndValuesTyped(values, table)
-	if err != nil {
-		return nil, fmt.Errorf("generateIdArrayTyped: unable to get primary key columns and values for record %v: %w", values, err)
-	}
-
-	rid := models.NewRecordID(table.Name, vals)
-
-	return &rid, nil
-}
-
-// Reads CSV files and replaces existing records accordingly.
-func (s *Server) handleHistoryModeReplaceFiles(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, replaceFiles []string, fileParams *pb.FileParams, keys map[string][]byte, table *pb.Table) error {
 	unmodifiedString := fileParams.UnmodifiedString
-	return s.processCSVRecords(replaceFiles, fileParams, keys, func(columns []string, record []string) error {
+	edges := relationColumns(fields)
+	return s.processBatchFiles(ctx, replaceFiles, fileParams, keys, txRows(ctx, tx, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Replacing record", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -425,78 +316,49 @@ func (s *Server) handleHistoryModeReplaceFiles(ctx context.Context, db *surreald
 				return fmt.Errorf("replace file: column %s not found in the table info: %v", k, fields)
 			}
 
-			if v == fileParams.NullString {
+			if isNullValue(v, fileParams) {
 				vars[k] = models.None
 				continue
 			}
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return fmt.Errorf("replace file: %w", err)
+				return invalidRow(fmt.Errorf("replace file: %w", err))
 			}
 
 			vars[k] = typedV
 		}
 
-		res, err := surrealdb.Upsert[any](ctx, db, thing, vars)
+		query, queryVars, err := upsertContentQuery(edges, thing, vars)
+		if err != nil {
+			return invalidRow(fmt.Errorf("replace file: %w", err))
+		}
+
+		err = s.execWrite(ctx, db, tx, query, queryVars)
 		if err != nil {
 			if s.metrics != nil {
-				s.metrics.DBWriteError()
+				s.metrics.DBWriteError(metrics.LabelsFromContext(ctx))
 			}
 			s.LogDebug("Failed to upsert record for replace", "thing", thing, "vars", fmt.Sprintf("%+v", vars), "error", err)
 			return fmt.Errorf("unable to upsert record %s: %w", thing, err)
 		}
 
-		// Track successful DB write
-		if s.metrics != nil {
-			s.metrics.DBWriteCompleted(1)
+		// Track successful DB write. In transactional write mode, this is tracked on commit instead.
+		if s.metrics != nil && tx == nil {
+			s.metrics.DBWriteCompleted(metrics.LabelsFromContext(ctx), 1)
 		}
 
 		if s.Debugging() {
-			s.LogDebug("Replaced record", "commaSeparatedStringValues", values, "thing", thing, "vars", fmt.Sprintf("%+v", vars), "result", fmt.Sprintf("%+v", *res))
+			s.LogDebug("Replaced record", "commaSeparatedStringValues", values, "thing", thing, "vars", fmt.Sprintf("%+v", vars))
 		}
 
 		return nil
-	})
+	}))
 }
 
-func (s *Server) selectLatestFivetranStart(ctx context.Context, db *surrealdb.DB, table *pb.Table, values map[string]string, fields map[string]tablemapper.ColumnInfo) (*models.CustomDateTime, error) {
-	pkCols, pkVals, err := s.parsePrimaryKeyValuesExceptFivetranStart(values, table, fields)
-	if err != nil {
-		return nil, fmt.Errorf("latest fivetran_start: %w", err)
-	}
-
-	result, byID, err := s.selectLatestHistoryRecord(
-		ctx, db,
-		"_fivetran_start, id",
-		nil, // no extra vars needed
-		pkCols, pkVals,
-		table.Name,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get latest _fivetran_start for %s where %s: %w", table.Name, byID, err)
-	}
-
-	if len(result) == 0 {
-		// This means there is no previous record to be deleted with the given primary key values.
-		// So we return nil to indicate that.
-		// The caller should handle this case appropriately, like skipping with some logging.
-		return nil, nil
-	}
-
-	ftStart := result[0]["_fivetran_start"]
-
-	latestFivetranStart, ok := ftStart.(models.CustomDateTime)
-	if !ok {
-		return nil, fmt.Errorf("unable to assert latest _fivetran_start to SurrealDB datetime for %s where %s: %+v", table.Name, byID, ftStart)
-	}
-
-	return &latestFivetranStart, nil
-}
-
-func (s *Server) parsePrimaryKeyValuesExceptFivetranStart(strValues map[string]string, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]string, []any, error) {
+func (s *Server) parsePrimaryKeyValuesExceptFivetranStart(strValues map[string]any, table *pb.Table, fields map[string]tablemapper.ColumnInfo) ([]string, []any, error) {
 	var pkColumns []string
 	for _, c := range table.Columns {
 		if c.PrimaryKey && c.Name != "_fivetran_start" {
@@ -528,9 +390,9 @@ func (s *Server) parsePrimaryKeyValuesExceptFivetranStart(strValues map[string]s
 			return nil, nil, fmt.Errorf("getPKColumnsAndValues: column %s not found in the table info: %v", pkColumn, fields)
 		}
 
-		typedV, err := f.StrToSurrealType(v)
+		typedV, err := f.ValueToSurrealType(v)
 		if err != nil {
-			return nil, nil, fmt.Errorf("getPKColumnsAndValues: %w", err)
+			return nil, nil, invalidRow(fmt.Errorf("getPKColumnsAndValues: %w", err))
 		}
 
 		pkValues = append(pkValues, typedV)
@@ -539,13 +401,16 @@ func (s *Server) parsePrimaryKeyValuesExceptFivetranStart(strValues map[string]s
 	return pkColumns, pkValues, nil
 }
 
-func (s *Server) handleHistoryModeUpdateFiles(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
-	return s.processCSVRecords(req.UpdateFiles, req.FileParams, req.Keys, func(columns []string, record []string) error {
+func (s *Server) handleHistoryModeUpdateFiles(ctx context.Context, db *surrealdb.DB, tx *txWriter, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationUpdate)
+
+	edges := relationColumns(fields)
+	return s.processBatchFiles(ctx, req.UpdateFiles, req.FileParams, req.Keys, txRows(ctx, tx, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Processing update file", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
@@ -585,16 +450,16 @@ func (s *Server) handleHistoryModeUpdateFiles(ctx context.Context, db *surrealdb
 			// Null strings like "null-m8yilkvPsNulehxl2G6pmSQ3G3WWdLP"
 			// should result in SurrealDB none for the option<theType> SurrealDB
 			// field.
-			if v == req.FileParams.NullString {
+			if isNullValue(v, req.FileParams) {
 				vars[k] = models.None
 				continue
 			}
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return fmt.Errorf("history mode update file: %w", err)
+				return invalidRow(fmt.Errorf("history mode update file: %w", err))
 			}
 
 			vars[k] = typedV
@@ -609,266 +474,89 @@ func (s *Server) handleHistoryModeUpdateFiles(ctx context.Context, db *surrealdb
 			// We assume it is invalid to have no unmodified fields in an update file.
 			return fmt.Errorf("history mode update file: no unmodified fields found in the record %s", thing)
 		}
-
-		// Get the preivous values to populate the fields with values set to the unmodeified string
-
-		// There could be one or more unmodified fields even though
-		// it is the first time for Fivetran and the connector to upsert this record.
-		// We try to obtain the previous values from SurrealDB anyway.
-		// In case the record is not found, we are sure that the fields noted as unmodified are actually empty.
-		previousPKValues, previousFieldsAndValues, err := s.getPreviousValues(ctx, db, unmodifiedFields, req.Table, cols, vals)
-		if err != nil {
-			return fmt.Errorf("unable to get previous values for record %s: %w", thing, err)
-		}
-
-		if len(previousPKValues) == 0 {
-			// No previous record found, nothing to do.
-			// See https://github.com/fivetran/fivetran_partner_sdk/pull/149
-
-			s.LogDebug("No previous record found for update, skipping", "record", thing)
-			return nil
-		}
-
-		for k, v := range previousFieldsAndValues {
-			vars[k] = v
-		}
-
-		prevRecordID, err := s.generateIdArrayTyped(previousPKValues, req.Table)
-		if err != nil {
-			return fmt.Errorf("unable to generate previous record ID for record %s: %w", thing, err)
-		}
-
-		if s.Debugging() {
-			s.LogDebug("handleHistoryModeUpdateFiles: generated prevRecordID",
-				"prevRecordID", *prevRecordID,
-				"prevRecordID.ID", prevRecordID.ID,
-				"prevRecordID.ID_type", fmt.Sprintf("%T", prevRecordID.ID),
-				"previousPKValues", previousPKValues)
-		}
+		sort.Strings(unmodifiedFields)
 
 		newStartTime, ok := vars["_fivetran_start"].(models.CustomDateTime)
 		if !ok {
 			return fmt.Errorf("unable to assert _fivetran_start to models.CustomDateTime for record %s: %+v", thing, vars["_fivetran_start"])
 		}
 
-		prevEndTime := models.CustomDateTime{
-			Time: newStartTime.Add(-1 * time.Millisecond),
-		}
-
-		// Update the previous record to set its _fivetran_active to false,
-		// and _fivetran_end to newStartTime-1ms
-		err = s.upsertSetHistoryMode(ctx, db, *prevRecordID, map[string]interface{}{
-			"_fivetran_active": false,
-			"_fivetran_end":    prevEndTime,
-		})
+		contentQuery, contentVars, err := upsertContentQuery(edges, thing, vars)
 		if err != nil {
-			return fmt.Errorf("batchHistoryUpdate failed to update previous record's _fivetran_end: %w", err)
-		}
-
-		err = s.upsertContentHistoryMode(ctx, db, thing, vars)
-		if err != nil {
-			return fmt.Errorf("batchHistoryUpdate failed: %w", err)
-		}
-
-		return nil
-	})
-}
-
-func (s *Server) getPreviousValues(ctx context.Context, db *surrealdb.DB, fields []string, table *pb.Table, pkColumns []string, pkValues []any) (map[string]any, map[string]any, error) {
-	// Get the previous values for the thing (where the SurrealDB table field that corresponds to the source table's primary key column matches)
-
-	// Build the fields list: PK columns + content fields
-	idFieldsAndContentFields := make([]string, len(pkColumns)+len(fields))
-	copy(idFieldsAndContentFields, pkColumns)
-	copy(idFieldsAndContentFields[len(pkColumns):], fields)
-
-	// Use type::fields($fields), id for the query - this allows us to:
-	// 1. Get only the specific fields we need
-	// 2. Get the full RecordID for PK extraction
-	result, byID, err := s.selectLatestHistoryRecord(
-		ctx, db,
-		"type::fields($fields), id",
-		map[string]any{"fields": idFieldsAndContentFields},
-		pkColumns, pkValues,
-		table.Name,
-	)
-	if err != nil {
-		return nil, nil, fmt.Errorf("unable to get previous values for %s where %s: %w", table.Name, byID, err)
-	}
-
-	if len(result) == 0 {
-		// We assume the record has not been created yet.
-		// The caller should omit the unmodified fields from the vars,
-		// so that SurrealDB will create the record without those fields noted unmodified.
-		return nil, nil, nil
-	}
-
-	// Both query paths use LIMIT 1, so we should get at most one result.
-	if len(result) > 1 {
-		return nil, nil, fmt.Errorf("got multiple results while getting previous values found for %s where %s: %+v", table.Name, byID, result)
-	}
-
-	fetchedPKValues := make(map[string]any)
-	fetchedContentValues := make(map[string]any)
-	for k, v := range result[0] {
-		// Skip temporary fields from subquery workaround
-		if k == "_gte" || k == "_lt" {
-			continue
+			return invalidRow(fmt.Errorf("history mode update file: %w", err))
 		}
 
-		// Skip SurrealDB's RecordID field unless "id" is explicitly a PK column.
-		// The query uses "type::fields($fields), id" which returns the RecordID.
-		// We only want to process "id" if it's actually a user-defined PK column.
-		if k == "id" && !hasIdPKColumn(pkColumns) {
-			continue
+		// Get the previous version to populate the fields with values set to the unmodified string.
+		//
+		// There could be one or more unmodified fields even though
+		// it is the first time for Fivetran and the connector to upsert this record.
+		// In case the record is not found, nothing is written.
+		// See https://github.com/fivetran/fivetran_partner_sdk/pull/149
+		latest, queryVars, byID := s.latestHistoryRecordQuery("*", cols, vals, req.Table.Name)
+		for k, v := range contentVars {
+			queryVars[k] = v
+		}
+		queryVars["thing"] = thing
+		// The previous version ends 1ms before the new one starts
+		queryVars["previous_end"] = models.CustomDateTime{
+			Time: newStartTime.Add(-1 * time.Millisecond),
 		}
 
-		isPK := false
-		for _, pkCol := range pkColumns {
-			if k == pkCol {
-				isPK = true
-				break
-			}
+		sets := make([]string, 0, len(unmodifiedFields))
+		for _, k := range unmodifiedFields {
+			sets = append(sets, fmt.Sprintf("%s = $_latest.%s", quoteIdent(k), quoteIdent(k)))
 		}
-		if isPK {
-			// Special handling for "id" column: SurrealDB returns the full RecordID,
-			// but we need to extract the actual column value from it.
-			if k == "id" {
-				if rid, ok := v.(models.RecordID); ok {
-					// The RecordID.ID is an array like ["id1", "2024-01-01T12:00:00Z"]
-					// The first element(s) before _fivetran_start are the actual PK values
-					// For a single "id" column, we just need the first element
-					if idArr, ok := rid.ID.([]any); ok && len(idArr) > 0 {
-						fetchedPKValues[k] = idArr[0]
-						if s.Debugging() {
-							s.LogDebug("Extracted id value from RecordID", "rid", rid, "extracted_id", idArr[0])
-						}
-					} else {
-						// Fallback: use as-is (this shouldn't happen for composite keys)
-						fetchedPKValues[k] = v
-					}
-				} else if idMap, ok := v.(map[string]any); ok {
-					// Alternative: id.id returns map[id:[...]] structure from some queries
-					if idArr, ok := idMap["id"].([]any); ok && len(idArr) > 0 {
-						fetchedPKValues["id"] = idArr[0]
-						if s.Debugging() {
-							s.LogDebug("Extracted id value from id.id map", "idMap", idMap, "extracted_id", idArr[0])
-						}
-					}
-				} else {
-					fetchedPKValues[k] = v
-				}
-			} else {
-				fetchedPKValues[k] = v
-				if s.Debugging() {
-					s.LogDebug("Stored PK value", "k", k, "v", v, "v_type", fmt.Sprintf("%T", v))
-				}
-			}
-		} else {
-			fetchedContentValues[k] = v
-		}
-	}
 
-	return fetchedPKValues, fetchedContentValues, nil
-}
+		// Update the previous version to set its _fivetran_active to false,
+		// and _fivetran_end to newStartTime-1ms, then add the new version
+		// with the unmodified fields taken from the previous version.
+		query := withLatestHistoryRecord(latest,
+			"UPDATE $_latest.id SET _fivetran_active = false, _fivetran_end = $previous_end RETURN NONE;",
+			strings.TrimSuffix(strings.TrimSpace(contentQuery), ";")+";",
+			"UPDATE $thing SET "+strings.Join(sets, ", ")+" RETURN NONE;",
+		)
 
-func (s *Server) upsertSetHistoryMode(ctx context.Context, db *surrealdb.DB, thing models.RecordID, vars map[string]interface{}) error {
-	if s.Debugging() {
-		// Log detailed info about the RecordID being used for UPSERT
-		var idElementTypes []string
-		if idSlice, ok := thing.ID.([]any); ok {
-			for i, elem := range idSlice {
-				idElementTypes = append(idElementTypes, fmt.Sprintf("[%d]=%T", i, elem))
-			}
+		if err := s.execWrite(ctx, db, tx, query, queryVars); err != nil {
+			s.LogDebug("Failed to add history record for update", "thing", thing, "vars", fmt.Sprintf("%+v", vars), "error", err)
+			return fmt.Errorf("batchHistoryUpdate failed to deactivate the latest record where %s and add %s: %w", byID, thing, err)
 		}
-		s.LogDebug("upsertSetHistoryMode: targeting record",
-			"thing.Table", thing.Table,
-			"thing.ID", thing.ID,
-			"thing.ID_type", fmt.Sprintf("%T", thing.ID),
-			"thing.ID_element_types", idElementTypes)
-	}
 
-	var conds []string
-	for k := range vars {
-		if k == "thing" {
-			continue
+		if s.Debugging() {
+			s.LogDebug("Added history record", "thing", thing, "vars", vars, "unmodified", unmodifiedFields)
 		}
-		conds = append(conds, fmt.Sprintf("%s = $%s", k, k))
-	}
-
-	vars["thing"] = thing
-
-	_, err := surrealdb.Query[[]map[string]any](
-		ctx,
-		db,
-		"UPSERT $thing SET "+strings.Join(conds, ", "),
-		vars,
-	)
-	if err != nil {
-		return fmt.Errorf("upsert set failed: %w", err)
-	}
 
-	return nil
+		return nil
+	}))
 }
 
-func (s *Server) upsertContentHistoryMode(ctx context.Context, db *surrealdb.DB, thing models.RecordID, vars map[string]interface{}) error {
-	if _, found := vars["id"]; found {
-		return fmt.Errorf("id is not allowed to be set in the vars")
-	}
-
-	res, err := surrealdb.Upsert[any](ctx, db, thing, vars)
-	if err != nil {
-		s.LogDebug("Failed to upsert record for update", "thing", thing, "vars", fmt.Sprintf("%+v", vars), "error", err)
-		return fmt.Errorf("unable to upsert record %s: %w", thing, err)
-	}
-
-	if s.Debugging() {
-		s.LogDebug("Added history record", "thing", thing, "vars", vars, "result", *res)
-	}
-
-	return nil
-}
+func (s *Server) handleHistoryModeDeleteFiles(ctx context.Context, db *surrealdb.DB, tx *txWriter, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
+	ctx = metrics.WithOperation(ctx, metrics.OperationDelete)
 
-func (s *Server) handleHistoryModeDeleteFiles(ctx context.Context, db *surrealdb.DB, fields map[string]tablemapper.ColumnInfo, req *pb.WriteHistoryBatchRequest) error {
-	return s.processCSVRecords(req.DeleteFiles, req.FileParams, req.Keys, func(columns []string, record []string) error {
+	return s.processBatchFiles(ctx, req.DeleteFiles, req.FileParams, req.Keys, txRows(ctx, tx, func(columns []string, record []any) error {
 		if s.Debugging() {
 			s.LogDebug("Processing delete file", "columns", columns, "record", record)
 		}
 
-		values := make(map[string]string)
+		values := make(map[string]any)
 		for i, column := range columns {
 			values[column] = record[i]
 		}
 
-		// In case it is DELETE file, Fivetran does not provide _fivetran_start column/value.
-		// In that case, we need to be creative to get the lastest _fivetran_start for the record
-		// identified by the primary key columns.
-		// That way, we can update the (1) fivetran_end to the time specified in the file,
-		// and (2) fivetran_active to false for the latest record.
-		latestFivetranStart, err := s.selectLatestFivetranStart(ctx, db, req.Table, values, fields)
-		if err != nil {
-			return fmt.Errorf("unable to get latest _fivetran_start for record %v: %w", values, err)
-		}
-
-		if latestFivetranStart == nil {
-			// No previous record found, nothing to do.
-			// See https://github.com/fivetran/fivetran_partner_sdk/pull/148
-			s.LogDebug("No existing records found for delete, skipping", "values", values)
-			return nil
-		}
-
 		if s.Debugging() {
 			s.LogDebug("History mode delete record", "commaSeparatedStringValues", values)
 		}
 
-		id, err := s.generateIdArrayForDelete(values, req.Table, fields, latestFivetranStart)
+		// In case it is DELETE file, Fivetran does not provide _fivetran_start column/value.
+		// In that case, we need to be creative to get the lastest record
+		// identified by the primary key columns except _fivetran_start.
+		// That way, we can update the (1) fivetran_end to the time specified in the file,
+		// and (2) fivetran_active to false for the latest record.
+		pkCols, pkVals, err := s.parsePrimaryKeyValuesExceptFivetranStart(values, req.Table, fields)
 		if err != nil {
-			return fmt.Errorf("history mode delete file: %w", err)
+			return fmt.Errorf("history mode delete file: unable to get primary key columns and values for record %v: %w", values, err)
 		}
 
-		vars := map[string]any{
-			"thing": models.NewRecordID(req.Table.Name, id),
-		}
+		vars := map[string]any{}
 		for k, v := range values {
 			if k == "id" {
 				if s.Debugging() {
@@ -889,15 +577,15 @@ func (s *Server) handleHistoryModeDeleteFiles(ctx context.Context, db *surrealdb
 			// Null strings like "null-m8yilkvPsNulehxl2G6pmSQ3G3WWdLP"
 			// should be handled as "missing" and "not neeeded to be updated"
 			// in DELETE files.
-			if v == req.FileParams.NullString {
+			if isNullValue(v, req.FileParams) {
 				continue
 			}
 
 			var typedV interface{}
 
-			typedV, err := f.StrToSurrealType(v)
+			typedV, err := f.ValueToSurrealType(v)
 			if err != nil {
-				return fmt.Errorf("history mode delete file: %w", err)
+				return invalidRow(fmt.Errorf("history mode delete file: %w", err))
 			}
 
 			vars[k] = typedV
@@ -909,24 +597,30 @@ func (s *Server) handleHistoryModeDeleteFiles(ctx context.Context, db *surrealdb
 		// in delete file rows, unlike sdktester which does.
 		vars["_fivetran_active"] = false
 
-		var conds []string
+		latest, queryVars, byID := s.latestHistoryRecordQuery("id", pkCols, pkVals, req.Table.Name)
+
+		keys := make([]string, 0, len(vars))
 		for k := range vars {
-			if k == "thing" {
-				continue
-			}
-			conds = append(conds, fmt.Sprintf("%s = $%s", k, k))
+			keys = append(keys, k)
 		}
+		sort.Strings(keys)
 
-		_, err = surrealdb.Query[[]map[string]any](
-			ctx,
-			db,
-			"UPSERT $thing SET "+strings.Join(conds, ", "),
-			vars,
-		)
-		if err != nil {
-			return fmt.Errorf("history mode delete file failed: %w", err)
+		// The values are passed as $set0, $set1, ..., so that they never collide with the parameters of the latest record query
+		var conds []string
+		for i, k := range keys {
+			param := fmt.Sprintf("set%d", i)
+			queryVars[param] = vars[k]
+			conds = append(conds, fmt.Sprintf("%s = $%s", quoteIdent(k), param))
+		}
+
+		// If no previous record is found, nothing is written.
+		// See https://github.com/fivetran/fivetran_partner_sdk/pull/148
+		query := withLatestHistoryRecord(latest, "UPDATE $_latest.id SET "+strings.Join(conds, ", ")+" RETURN NONE;")
+
+		if err := s.execWrite(ctx, db, tx, query, queryVars); err != nil {
+			return fmt.Errorf("history mode delete file failed to deactivate the latest record where %s: %w", byID, err)
 		}
 
 		return nil
-	})
+	}))
 }
@@ -1621,3 +1621,133 @@ func TestWriteHistoryBatch_SuccessReplaceUpdateDeleteSameRecord_WithoutFivetranA
 	t2End := t2Record["_fivetran_end"].(models.CustomDateTime)
 	assert.Equal(t, deleteTime, t2End.Time, "T2: _fivetran_end should be delete time T3")
 }
+
+// historyParquetRow is a row of buildHistoryTable in Parquet batch files.
+// Name is a string so that it can hold the unmodified string in update files.
+type historyParquetRow struct {
+	FivetranID     string     `parquet:"_fivetran_id"`
+	FivetranStart  *time.Time `parquet:"_fivetran_start,optional,timestamp(millisecond)"`
+	FivetranEnd    *time.Time `parquet:"_fivetran_end,optional,timestamp(millisecond)"`
+	FivetranActive *bool      `parquet:"_fivetran_active,optional"`
+	FivetranSynced *time.Time `parquet:"_fivetran_synced,optional,timestamp(millisecond)"`
+	Name           *string    `parquet:"name,optional"`
+	Age            *int64     `parquet:"age,optional"`
+	Active         *bool      `parquet:"active,optional"`
+}
+
+type historyParquetEarliestStartRow struct {
+	FivetranID    string    `parquet:"_fivetran_id"`
+	FivetranStart time.Time `parquet:"_fivetran_start,timestamp(millisecond)"`
+}
+
+func TestWriteHistoryBatch_SuccessParquet(t *testing.T) {
+	tempDir, cleanup := setupWriteHistoryBatchTest(t)
+	defer cleanup()
+
+	srv := New(zerolog.New(os.Stdout).Level(zerolog.DebugLevel))
+	srv.batchFileFormat = pb.BatchFileFormat_PARQUET
+	config := testframework.GetSurrealDBConfig()
+	table := buildHistoryTable()
+	schema := "test_writehistorybatch"
+	fileParams := testframework.GetTestFileParams()
+
+	// Create table
+	_, err := srv.CreateTable(t.Context(), &pb.CreateTableRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+	})
+	require.NoError(t, err)
+	defer testframework.DropTable(t, config, "test", schema, table.Name)
+
+	str := func(s string) *string { return &s }
+	i64 := func(i int64) *int64 { return &i }
+	boolean := func(b bool) *bool { return &b }
+	ts := func(t time.Time) *time.Time { return &t }
+
+	startTime1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	startTime2 := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	startTime3 := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	endTime := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	syncTime := time.Now().UTC().Truncate(time.Millisecond)
+
+	replaceRows := []historyParquetRow{
+		{FivetranID: "user1", FivetranStart: ts(startTime1), FivetranEnd: ts(endTime), FivetranActive: boolean(true), FivetranSynced: ts(syncTime), Name: str("Alice"), Age: i64(25), Active: boolean(true)},
+		{FivetranID: "user2", FivetranStart: ts(startTime1), FivetranEnd: ts(endTime), FivetranActive: boolean(true), FivetranSynced: ts(syncTime), Name: str("Bob"), Age: i64(30), Active: boolean(false)},
+		{FivetranID: "user3", FivetranStart: ts(startTime1), FivetranEnd: ts(endTime), FivetranActive: boolean(true), FivetranSynced: ts(syncTime), Name: str("Charlie"), Age: i64(35), Active: boolean(true)},
+		{FivetranID: "user3", FivetranStart: ts(startTime3), FivetranEnd: ts(endTime), FivetranActive: boolean(true), FivetranSynced: ts(syncTime), Name: str("Charlie v2"), Age: i64(36), Active: boolean(true)},
+	}
+	replaceKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	replaceFile := testframework.CreateEncryptedParquet(t, tempDir, "replace.parquet", replaceRows, replaceKey)
+
+	_, err = srv.WriteHistoryBatch(t.Context(), &pb.WriteHistoryBatchRequest{
+		Configuration: config,
+		SchemaName:    schema,
+		Table:         table,
+		ReplaceFiles:  []string{replaceFile},
+		Keys:          map[string][]byte{replaceFile: replaceKey},
+		FileParams:    fileParams,
+	})
+	require.NoError(t, err)
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 4)
+
+	// The unmodified name is taken from the previous version, and the null age is removed
+	updateRows := []historyParquetRow{
+		{FivetranID: "user1", FivetranStart: ts(startTime2), FivetranEnd: ts(endTime), FivetranActive: boolean(true), FivetranSynced: ts(syncTime), Name: str(fileParams.UnmodifiedString), Active: boolean(false)},
+	}
+	updateKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	updateFile := testframework.CreateEncryptedParquet(t, tempDir, "update.parquet", updateRows, updateKey)
+
+	// user2 is deleted, with nulls in every column but the primary key and the history mode columns
+	deleteRows := []historyParquetRow{
+		{FivetranID: "user2", FivetranEnd: ts(startTime2), FivetranActive: boolean(false), FivetranSynced: ts(syncTime)},
+	}
+	deleteKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	deleteFile := testframework.CreateEncryptedParquet(t, tempDir, "delete.parquet", deleteRows, deleteKey)
+
+	// The second version of user3 is removed, and the first one is deactivated
+	earliestRows := []historyParquetEarliestStartRow{
+		{FivetranID: "user3", FivetranStart: startTime2},
+	}
+	earliestKey, err := testframework.GenerateAESKey()
+	require.NoError(t, err)
+	earliestFile := testframework.CreateEncryptedParquet(t, tempDir, "earliest.parquet", earliestRows, earliestKey)
+
+	batchResp, err := srv.WriteHistoryBatch(t.Context(), &pb.WriteHistoryBatchRequest{
+		Configuration:      config,
+		SchemaName:         schema,
+		Table:              table,
+		EarliestStartFiles: []string{earliestFile},
+		UpdateFiles:        []string{updateFile},
+		DeleteFiles:        []string{deleteFile},
+		Keys:               map[string][]byte{earliestFile: earliestKey, updateFile: updateKey, deleteFile: deleteKey},
+		FileParams:         fileParams,
+	})
+	require.NoError(t, err)
+	success, ok := batchResp.Response.(*pb.WriteBatchResponse_Success)
+	require.True(t, ok, "Expected WriteHistoryBatch success response")
+	require.True(t, success.Success)
+
+	// user1 has 2 versions, user2 has 1 deactivated version, and user3 has 1 deactivated version
+	testframework.AssertRecordCount(t, config, "test", schema, table.Name, 4)
+
+	assertHistoryRecordValues(t,
+		assertInactiveRecord(t, config, "test", schema, table.Name, "user1", startTime1.Format(time.RFC3339)),
+		map[string]any{"name": "Alice", "age": uint64(25), "active": true})
+
+	user1 := assertActiveRecord(t, config, "test", schema, table.Name, "user1", models.CustomDateTime{Time: startTime2})
+	assertHistoryRecordValues(t, user1, map[string]any{"name": "Alice", "active": false})
+	require.Nil(t, user1["age"], "age should be NULL")
+
+	assertInactiveRecord(t, config, "test", schema, table.Name, "user2", startTime1.Format(time.RFC3339))
+
+	assertHistoryRecordValues(t,
+		assertInactiveRecord(t, config, "test", schema, table.Name, "user3", startTime1.Format(time.RFC3339)),
+		map[string]any{
+			"name":          "Charlie",
+			"_fivetran_end": models.CustomDateTime{Time: startTime2.Add(-time.Millisecond)},
+		})
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestNewRecordID(t *testing.T) {
+	id, err := newRecordID("users", []any{int64(42)}, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.NewRecordID("users", []any{int64(42)}), id)
+
+	id, err = newRecordID("users", []any{int64(42)}, true)
+	require.NoError(t, err)
+	assert.Equal(t, models.NewRecordID("users", int64(42)), id)
+
+	_, err = newRecordID("users", []any{int64(42), "a"}, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table users uses scalar record IDs")
+}
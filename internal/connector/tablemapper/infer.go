@@ -0,0 +1,65 @@
+package tablemapper
+
+import (
+	"strings"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+// The decimal params reported for decimal fields of tables not created by the connector.
+// SurrealDB decimals have no declared precision or scale, so we report the largest precision
+// SurrealDB decimals can represent without falling back to floats. See ColumnMeta.DecimalPrecision.
+const (
+	inferredDecimalPrecision = 28
+	inferredDecimalScale     = 10
+)
+
+// inferredTypes maps the SurrealDB types of fields without ColumnMeta to Fivetran data types.
+var inferredTypes = map[string]pb.DataType{
+	"string":   pb.DataType_STRING,
+	"int":      pb.DataType_LONG,
+	"float":    pb.DataType_DOUBLE,
+	"decimal":  pb.DataType_DECIMAL,
+	"bool":     pb.DataType_BOOLEAN,
+	"datetime": pb.DataType_UTC_DATETIME,
+	"duration": pb.DataType_NAIVE_TIME,
+	"object":   pb.DataType_JSON,
+	"bytes":    pb.DataType_BINARY,
+	"array":    pb.DataType_JSON,
+}
+
+// InferColumnMeta infers the ColumnMeta of a field defined without one,
+// like the fields of tables defined by hand or by other tools, from its SurrealDB type.
+// sdbType is the type of the field without option<...>, like array<string>.
+//
+// The id field, which constrains the record IDs, is inferred to be the only primary key column
+// whose values are used as scalar record IDs.
+// An array id field is never inferred, as we cannot tell which columns its elements are.
+//
+// It returns false if the type has no corresponding Fivetran data type.
+// The returned FtIndex is always 0, and is up to the caller.
+func InferColumnMeta(name, sdbType string) (ColumnMeta, bool) {
+	base, _, _ := strings.Cut(sdbType, "<")
+
+	ftType, ok := inferredTypes[base]
+	if !ok {
+		return ColumnMeta{}, false
+	}
+
+	meta := ColumnMeta{FtType: ftType}
+
+	if ftType == pb.DataType_DECIMAL {
+		meta.DecimalPrecision = inferredDecimalPrecision
+		meta.DecimalScale = inferredDecimalScale
+	}
+
+	if name == "id" {
+		if base != "int" && base != "string" {
+			return ColumnMeta{}, false
+		}
+		meta.FtPrimaryKey = true
+		meta.ScalarRecordID = true
+	}
+
+	return meta, true
+}
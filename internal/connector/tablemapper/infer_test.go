@@ -0,0 +1,55 @@
+package tablemapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestInferColumnMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		sdbType string
+		want    ColumnMeta
+		wantOK  bool
+	}{
+		{name: "name", sdbType: "string", want: ColumnMeta{FtType: pb.DataType_STRING}, wantOK: true},
+		{name: "count", sdbType: "int", want: ColumnMeta{FtType: pb.DataType_LONG}, wantOK: true},
+		{name: "ratio", sdbType: "float", want: ColumnMeta{FtType: pb.DataType_DOUBLE}, wantOK: true},
+		{name: "price", sdbType: "decimal", want: ColumnMeta{FtType: pb.DataType_DECIMAL, DecimalPrecision: 28, DecimalScale: 10}, wantOK: true},
+		{name: "active", sdbType: "bool", want: ColumnMeta{FtType: pb.DataType_BOOLEAN}, wantOK: true},
+		{name: "created_at", sdbType: "datetime", want: ColumnMeta{FtType: pb.DataType_UTC_DATETIME}, wantOK: true},
+		{name: "opens_at", sdbType: "duration", want: ColumnMeta{FtType: pb.DataType_NAIVE_TIME}, wantOK: true},
+		{name: "attrs", sdbType: "object", want: ColumnMeta{FtType: pb.DataType_JSON}, wantOK: true},
+		{name: "blob", sdbType: "bytes", want: ColumnMeta{FtType: pb.DataType_BINARY}, wantOK: true},
+		{name: "tags", sdbType: "array<string>", want: ColumnMeta{FtType: pb.DataType_JSON}, wantOK: true},
+		{name: "id", sdbType: "int", want: ColumnMeta{FtType: pb.DataType_LONG, FtPrimaryKey: true, ScalarRecordID: true}, wantOK: true},
+		{name: "id", sdbType: "string", want: ColumnMeta{FtType: pb.DataType_STRING, FtPrimaryKey: true, ScalarRecordID: true}, wantOK: true},
+		{name: "id", sdbType: "array<any>"},
+		{name: "location", sdbType: "geometry<point>"},
+		{name: "owner", sdbType: "record<user>"},
+		{name: "anything", sdbType: "any"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.sdbType, func(t *testing.T) {
+			meta, ok := InferColumnMeta(tt.name, tt.sdbType)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, meta)
+		})
+	}
+}
+
+func TestFindTypeMappingByColumnInfo_PrefersFieldType(t *testing.T) {
+	array := FindTypeMappingByColumnInfo(&ColumnInfo{SDBType: "array<string>", ColumnMeta: ColumnMeta{FtType: pb.DataType_JSON}})
+	assert.Equal(t, "array", array.SDB)
+
+	v, err := array.SurrealType(`["a", "b"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, v)
+
+	object := FindTypeMappingByColumnInfo(&ColumnInfo{SDBType: "object", ColumnMeta: ColumnMeta{FtType: pb.DataType_JSON}})
+	assert.Equal(t, "object", object.SDB)
+}
@@ -0,0 +1,80 @@
+package tablemapper
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// validateLink returns an error if the column can't link to the table.
+//
+// The values of a link column become the record IDs of the linked records,
+// so the column needs to be of a type SurrealDB accepts as record IDs.
+// Primary key columns can't be links, because their values make the record IDs of the table itself.
+func validateLink(c *pb.Column, table string) error {
+	if err := ValidateTableName(table); err != nil {
+		return fmt.Errorf("invalid link of column %s: %w", c.Name, err)
+	}
+
+	if c.PrimaryKey {
+		return fmt.Errorf("primary key column %s can't link to table %s", c.Name, table)
+	}
+
+	tpe := FindTypeMappingByPbColumn(c)
+	if tpe == nil || (tpe.SDB != "int" && tpe.SDB != "string") {
+		return fmt.Errorf("column %s of type %s can't link to table %s: only integer and string columns can", c.Name, c.Type, table)
+	}
+
+	return nil
+}
+
+// ResolveLinks looks up the record ID layout of the tables the link and relation columns of the table refer to,
+// so that the values of the columns are converted to record IDs of the same layout.
+//
+// A table not defined yet is assumed to use array record IDs, the default layout.
+func (tm *TableMapper) ResolveLinks(ctx context.Context, tableName string, info *TableInfo) error {
+	scalar := map[string]bool{tableName: info.ScalarRecordIDs()}
+
+	for i := range info.Columns {
+		c := &info.Columns[i]
+		table := c.LinkTable
+		if table == "" {
+			table = c.RelationTable
+		}
+		if table == "" {
+			continue
+		}
+
+		s, ok := scalar[table]
+		if !ok {
+			linked, err := tm.InfoForTable(ctx, table)
+			if err != nil {
+				return fmt.Errorf("failed to get info of table %s referred to by column %s: %w", table, c.Name, err)
+			}
+			s = linked.ScalarRecordIDs()
+			scalar[table] = s
+		}
+		c.LinkScalarRecordIDs = s
+	}
+
+	return nil
+}
+
+// link returns the record ID of the linked record if the column is a link, or the value as is otherwise.
+func (c *ColumnInfo) link(v any) any {
+	if c.LinkTable == "" || v == nil || v == models.None {
+		return v
+	}
+	return c.recordIDOf(c.LinkTable, v)
+}
+
+// recordIDOf returns the ID of the record of the table identified by the value,
+// following the record ID layout of the table resolved by ResolveLinks.
+func (c *ColumnInfo) recordIDOf(table string, v any) models.RecordID {
+	if c.LinkScalarRecordIDs {
+		return models.NewRecordID(table, v)
+	}
+	return models.NewRecordID(table, []any{v})
+}
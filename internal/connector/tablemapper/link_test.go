@@ -0,0 +1,47 @@
+package tablemapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestColumnInfo_Link(t *testing.T) {
+	c := ColumnInfo{
+		Name:       "customer_id",
+		SDBType:    "record<customers>",
+		ColumnMeta: ColumnMeta{FtType: pb.DataType_LONG, LinkTable: "customers"},
+	}
+
+	v, err := c.ValueToSurrealType("42")
+	require.NoError(t, err)
+	assert.Equal(t, models.NewRecordID("customers", []any{42}), v)
+
+	c.LinkScalarRecordIDs = true
+	v, err = c.ValueToSurrealType(int64(42))
+	require.NoError(t, err)
+	assert.Equal(t, models.NewRecordID("customers", int64(42)), v)
+
+	v, err = c.ValueToSurrealType(nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, v, "nulls are never links")
+}
+
+func TestDefineFieldQuery_Link(t *testing.T) {
+	c := &pb.Column{Name: "customer_id", Type: pb.DataType_LONG}
+	require.NoError(t, validateLink(c, "customers"))
+
+	q, err := defineFieldQuery("orders", c, ColumnMeta{FtType: pb.DataType_LONG, LinkTable: "customers"})
+	require.NoError(t, err)
+	assert.Contains(t, q, "TYPE option<record<customers>>")
+
+	err = validateLink(&pb.Column{Name: "id", Type: pb.DataType_LONG, PrimaryKey: true}, "customers")
+	assert.ErrorContains(t, err, "primary key column id can't link to table customers")
+
+	err = validateLink(&pb.Column{Name: "customer", Type: pb.DataType_JSON}, "customers")
+	assert.ErrorContains(t, err, "only integer and string columns can")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
@@ -18,6 +19,9 @@ type TypeMapping struct {
 	// max decimal precision supported for this mapping
 	MaxDecimalPrecision uint32
 	SurrealType         func(string) (interface{}, error)
+	// SurrealTypeFromValue converts an already typed value, like the ones decoded from
+	// Parquet batch files, to the SurrealDB type without going through strings.
+	SurrealTypeFromValue func(any) (interface{}, error)
 }
 
 // TypeMappings contains all available type mappings.
@@ -28,6 +32,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return v, nil
 		},
+		SurrealTypeFromValue: stringFromValue,
 	},
 	{
 		SDB: "int",
@@ -35,6 +40,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.Atoi(v)
 		},
+		SurrealTypeFromValue: intFromValue,
 	},
 	{
 		SDB: "int",
@@ -42,6 +48,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.Atoi(v)
 		},
+		SurrealTypeFromValue: intFromValue,
 	},
 	{
 		SDB: "int",
@@ -49,6 +56,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.Atoi(v)
 		},
+		SurrealTypeFromValue: intFromValue,
 	},
 	{
 		SDB: "bytes",
@@ -56,6 +64,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return []byte(v), nil
 		},
+		SurrealTypeFromValue: bytesFromValue,
 	},
 	{
 		SDB: "float",
@@ -63,6 +72,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.ParseFloat(v, 64)
 		},
+		SurrealTypeFromValue: floatFromValue,
 	},
 	{
 		SDB: "float",
@@ -70,6 +80,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.ParseFloat(v, 64)
 		},
+		SurrealTypeFromValue: floatFromValue,
 	},
 	{
 		SDB: "bool",
@@ -77,6 +88,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.ParseBool(v)
 		},
+		SurrealTypeFromValue: boolFromValue,
 	},
 	{
 		SDB:                 "decimal",
@@ -85,6 +97,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return models.DecimalString(v), nil
 		},
+		SurrealTypeFromValue: decimalFromValue,
 	},
 	{
 		SDB:                 "float",
@@ -93,6 +106,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return strconv.ParseFloat(v, 64)
 		},
+		SurrealTypeFromValue: floatFromValue,
 	},
 	{
 		SDB: "datetime",
@@ -104,6 +118,7 @@ var TypeMappings = []TypeMapping{
 			}
 			return models.CustomDateTime{Time: dt}, nil
 		},
+		SurrealTypeFromValue: datetimeFromValue,
 	},
 	{
 		SDB: "datetime",
@@ -115,6 +130,7 @@ var TypeMappings = []TypeMapping{
 			}
 			return models.CustomDateTime{Time: dt}, nil
 		},
+		SurrealTypeFromValue: datetimeFromValue,
 	},
 	{
 		SDB: "datetime",
@@ -126,6 +142,7 @@ var TypeMappings = []TypeMapping{
 			}
 			return models.CustomDateTime{Time: dt}, nil
 		},
+		SurrealTypeFromValue: datetimeFromValue,
 	},
 	{
 		SDB: "object",
@@ -137,6 +154,21 @@ var TypeMappings = []TypeMapping{
 			}
 			return m, nil
 		},
+		SurrealTypeFromValue: objectFromValue,
+	},
+	{
+		// Only used for writing to array fields of tables not created by the connector,
+		// as JSON columns are defined as object fields.
+		SDB: "array",
+		FT:  pb.DataType_JSON,
+		SurrealType: func(v string) (interface{}, error) {
+			var a []interface{}
+			if err := json.Unmarshal([]byte(v), &a); err != nil {
+				return nil, fmt.Errorf("surrealType(array): %w", err)
+			}
+			return a, nil
+		},
+		SurrealTypeFromValue: arrayFromValue,
 	},
 	{
 		SDB: "string",
@@ -144,6 +176,7 @@ var TypeMappings = []TypeMapping{
 		SurrealType: func(v string) (interface{}, error) {
 			return v, nil
 		},
+		SurrealTypeFromValue: stringFromValue,
 	},
 	{
 		SDB: "duration",
@@ -159,6 +192,7 @@ var TypeMappings = []TypeMapping{
 			}
 			return models.CustomDuration{Duration: parsed.Sub(ref)}, nil
 		},
+		SurrealTypeFromValue: durationFromValue,
 	},
 }
 
@@ -176,7 +210,19 @@ func FindTypeMappingByPbColumn(col *pb.Column) *TypeMapping {
 }
 
 // FindTypeMappingByColumnInfo finds the type mapping for a column info.
+//
+// The mapping to the SurrealDB type of the field is preferred, if any,
+// so that a JSON column is written to an array field as an array.
 func FindTypeMappingByColumnInfo(col *ColumnInfo) *TypeMapping {
+	sdb, _, _ := strings.Cut(col.SDBType, "<")
+	for _, m := range TypeMappings {
+		if m.FT == col.FtType && m.SDB == sdb {
+			if m.MaxDecimalPrecision < col.DecimalPrecision {
+				continue
+			}
+			return &m
+		}
+	}
 	for _, m := range TypeMappings {
 		if m.FT == col.FtType {
 			if m.MaxDecimalPrecision < col.DecimalPrecision {
@@ -203,8 +249,30 @@ func DefineFieldQueryForHistoryModeIDFromFt(tb string, c *pb.Column, columnIndex
 	return defineField, nil
 }
 
+// defineFieldQueryForScalarID generates a DEFINE FIELD query for the id column
+// whose values are used as scalar record IDs.
+func defineFieldQueryForScalarID(tb string, c *pb.Column, meta ColumnMeta) (string, error) {
+	t := `DEFINE FIELD OVERWRITE %s on %s TYPE %s COMMENT '%s';`
+
+	tpe := FindTypeMappingByPbColumn(c)
+	if tpe == nil {
+		return "", fmt.Errorf("defining field: unsupported data type: %s (name=%v, type=%v, params=%v)", c.Type, c.Name, c.Type, c.Params)
+	}
+
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal column meta: %w", err)
+	}
+
+	return fmt.Sprintf(t, c.Name, tb, tpe.SDB, string(metaJSON)), nil
+}
+
 // DefineFieldQueryFromFt generates a DEFINE FIELD query from a Fivetran column.
 func DefineFieldQueryFromFt(tb string, c *pb.Column, columnIndex int) (string, error) {
+	return defineFieldQuery(tb, c, NewColumnMeta(c, columnIndex))
+}
+
+func defineFieldQuery(tb string, c *pb.Column, meta ColumnMeta) (string, error) {
 	t := `DEFINE FIELD OVERWRITE %s on %s TYPE option<%s> COMMENT '%s';`
 
 	tpe := FindTypeMappingByPbColumn(c)
@@ -213,8 +281,10 @@ func DefineFieldQueryFromFt(tb string, c *pb.Column, columnIndex int) (string, e
 	}
 
 	sdb := tpe.SDB
+	if meta.LinkTable != "" {
+		sdb = fmt.Sprintf("record<%s>", meta.LinkTable)
+	}
 
-	meta := NewColumnMeta(c, columnIndex)
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal column meta: %w", err)
@@ -263,3 +333,143 @@ func NewColumnMeta(c *pb.Column, columnIndex int) ColumnMeta {
 		DecimalScale:     PbColumnDecimalScale(c),
 	}
 }
+
+// The below convert typed values, as opposed to strings, to SurrealDB types.
+// String values never reach them, because ColumnInfo.ValueToSurrealType
+// routes strings to SurrealType.
+
+func stringFromValue(v any) (interface{}, error) {
+	// Format typed values the same way they appear in CSV batch files,
+	// so that the stored strings do not depend on the batch file format.
+	switch t := v.(type) {
+	case []byte:
+		return string(t), nil
+	case time.Time:
+		return t.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return formatTimeOfDay(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(t), 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case fmt.Stringer:
+		return t.String(), nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(string): unexpected value %v of type %T", v, v)
+	}
+}
+
+// formatTimeOfDay formats the duration since midnight like time.TimeOnly,
+// followed by the fractional seconds if any.
+func formatTimeOfDay(d time.Duration) string {
+	return time.Time{}.Add(d).Format("15:04:05.999999999")
+}
+
+func intFromValue(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case int64:
+		return t, nil
+	case int32:
+		return int64(t), nil
+	case int:
+		return int64(t), nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(int): unexpected value %v of type %T", v, v)
+	}
+}
+
+func floatFromValue(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case float32:
+		// Widening float32 to float64 directly would give 1.100000023841858 for 1.1,
+		// so we go through the shortest decimal representation like CSV does.
+		return strconv.ParseFloat(strconv.FormatFloat(float64(t), 'g', -1, 32), 64)
+	case int64:
+		return float64(t), nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(float): unexpected value %v of type %T", v, v)
+	}
+}
+
+func boolFromValue(v any) (interface{}, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("surrealTypeFromValue(bool): unexpected value %v of type %T", v, v)
+	}
+	return b, nil
+}
+
+func bytesFromValue(v any) (interface{}, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("surrealTypeFromValue(bytes): unexpected value %v of type %T", v, v)
+	}
+	return b, nil
+}
+
+func decimalFromValue(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case int64:
+		return models.DecimalString(strconv.FormatInt(t, 10)), nil
+	case float64:
+		return models.DecimalString(strconv.FormatFloat(t, 'f', -1, 64)), nil
+	case float32:
+		return models.DecimalString(strconv.FormatFloat(float64(t), 'f', -1, 32)), nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(decimal): unexpected value %v of type %T", v, v)
+	}
+}
+
+func datetimeFromValue(v any) (interface{}, error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("surrealTypeFromValue(datetime): unexpected value %v of type %T", v, v)
+	}
+	return models.CustomDateTime{Time: t}, nil
+}
+
+func objectFromValue(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t, nil
+	case []byte:
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(t, &m); err != nil {
+			return nil, fmt.Errorf("surrealTypeFromValue(object): %w", err)
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(object): unexpected value %v of type %T", v, v)
+	}
+}
+
+func arrayFromValue(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		return t, nil
+	case []byte:
+		var a []interface{}
+		if err := json.Unmarshal(t, &a); err != nil {
+			return nil, fmt.Errorf("surrealTypeFromValue(array): %w", err)
+		}
+		return a, nil
+	default:
+		return nil, fmt.Errorf("surrealTypeFromValue(array): unexpected value %v of type %T", v, v)
+	}
+}
+
+func durationFromValue(v any) (interface{}, error) {
+	d, ok := v.(time.Duration)
+	if !ok {
+		return nil, fmt.Errorf("surrealTypeFromValue(duration): unexpected value %v of type %T", v, v)
+	}
+	return models.CustomDuration{Duration: d}, nil
+}
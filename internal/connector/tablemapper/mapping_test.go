@@ -0,0 +1,40 @@
+package tablemapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringFromValue(t *testing.T) {
+	testCases := []struct {
+		value    any
+		expected string
+	}{
+		{[]byte("abc"), "abc"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 6_000_000, time.UTC), "2024-01-02T03:04:05.006Z"},
+		{13*time.Hour + 4*time.Minute + 5*time.Second, "13:04:05"},
+		{int64(42), "42"},
+		{float32(1.1), "1.1"},
+		{1.5, "1.5"},
+		{true, "true"},
+	}
+
+	for _, tc := range testCases {
+		v, err := stringFromValue(tc.value)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, v, "value %v of type %T", tc.value, tc.value)
+	}
+}
+
+func TestFloatFromValue(t *testing.T) {
+	v, err := floatFromValue(float32(1.1))
+	require.NoError(t, err)
+	require.Equal(t, 1.1, v)
+
+	v, err = decimalFromValue(float32(1.1))
+	require.NoError(t, err)
+	require.EqualValues(t, "1.1", v)
+}
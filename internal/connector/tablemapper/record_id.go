@@ -0,0 +1,48 @@
+package tablemapper
+
+import (
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+// ScalarRecordIDColumn returns the only primary key column of the table,
+// if the table can use its values as scalar record IDs.
+//
+// History mode tables never can, because their record IDs include _fivetran_start
+// in addition to the primary key values.
+// The column also needs to be of a type SurrealDB accepts as record IDs,
+// which are integers and strings among the types Fivetran columns are mapped to.
+func ScalarRecordIDColumn(table *pb.Table) (*pb.Column, bool) {
+	var pk *pb.Column
+	for _, c := range table.Columns {
+		if c.Name == "_fivetran_start" {
+			return nil, false
+		}
+		if !c.PrimaryKey {
+			continue
+		}
+		if pk != nil {
+			return nil, false
+		}
+		pk = c
+	}
+	if pk == nil {
+		return nil, false
+	}
+
+	tpe := FindTypeMappingByPbColumn(pk)
+	if tpe == nil || (tpe.SDB != "int" && tpe.SDB != "string") {
+		return nil, false
+	}
+
+	return pk, true
+}
+
+// ScalarRecordIDs reports whether the table was defined to use scalar record IDs.
+func (t TableInfo) ScalarRecordIDs() bool {
+	for _, c := range t.Columns {
+		if c.ScalarRecordID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package tablemapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestScalarRecordIDColumn(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []*pb.Column
+		wantPK  string
+	}{
+		{
+			name: "single int primary key",
+			columns: []*pb.Column{
+				{Name: "id", Type: pb.DataType_INT, PrimaryKey: true},
+				{Name: "name", Type: pb.DataType_STRING},
+			},
+			wantPK: "id",
+		},
+		{
+			name: "single string primary key",
+			columns: []*pb.Column{
+				{Name: "name", Type: pb.DataType_STRING},
+				{Name: "_fivetran_id", Type: pb.DataType_STRING, PrimaryKey: true},
+			},
+			wantPK: "_fivetran_id",
+		},
+		{
+			name: "composite primary key",
+			columns: []*pb.Column{
+				{Name: "order_id", Type: pb.DataType_INT, PrimaryKey: true},
+				{Name: "line", Type: pb.DataType_INT, PrimaryKey: true},
+			},
+		},
+		{
+			name: "history mode",
+			columns: []*pb.Column{
+				{Name: "id", Type: pb.DataType_INT, PrimaryKey: true},
+				{Name: "_fivetran_start", Type: pb.DataType_UTC_DATETIME},
+			},
+		},
+		{
+			name: "primary key type not usable as record id",
+			columns: []*pb.Column{
+				{Name: "day", Type: pb.DataType_NAIVE_DATE, PrimaryKey: true},
+			},
+		},
+		{
+			name: "no primary key",
+			columns: []*pb.Column{
+				{Name: "name", Type: pb.DataType_STRING},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pk, ok := ScalarRecordIDColumn(&pb.Table{Name: "tb", Columns: tt.columns})
+			if tt.wantPK == "" {
+				assert.False(t, ok)
+				assert.Nil(t, pk)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.wantPK, pk.Name)
+		})
+	}
+}
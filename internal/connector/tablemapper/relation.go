@@ -0,0 +1,107 @@
+package tablemapper
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// Endpoints of the edges of relation tables, which are also the names of the fields holding them.
+const (
+	RelationIn  = "in"
+	RelationOut = "out"
+)
+
+// Relation defines a table as a relation table, whose records are the edges
+// from the records of InTable to the records of OutTable.
+//
+// The records an edge relates are identified by the values of InColumn and OutColumn,
+// which are primary key columns of the table, so that an edge never needs to relate other records once created.
+type Relation struct {
+	InColumn  string
+	InTable   string
+	OutColumn string
+	OutTable  string
+}
+
+// validateRelation returns an error if the table can't be defined as the relation.
+func validateRelation(table *pb.Table, r *Relation) error {
+	if r.InColumn == r.OutColumn {
+		return fmt.Errorf("relation table %s needs different in and out columns, but got %s for both", table.Name, r.InColumn)
+	}
+
+	for _, end := range []struct{ column, table string }{{r.InColumn, r.InTable}, {r.OutColumn, r.OutTable}} {
+		if err := ValidateTableName(end.table); err != nil {
+			return fmt.Errorf("invalid table related by relation table %s: %w", table.Name, err)
+		}
+
+		var column *pb.Column
+		for _, c := range table.Columns {
+			if c.Name == end.column {
+				column = c
+			}
+		}
+		switch {
+		case column == nil:
+			return fmt.Errorf("relation table %s has no column %s", table.Name, end.column)
+		case !column.PrimaryKey:
+			return fmt.Errorf("column %s of relation table %s needs to be a primary key column", end.column, table.Name)
+		}
+
+		tpe := FindTypeMappingByPbColumn(column)
+		if tpe == nil || (tpe.SDB != "int" && tpe.SDB != "string") {
+			return fmt.Errorf("column %s of type %s can't relate records of table %s: only integer and string columns can", column.Name, column.Type, end.table)
+		}
+	}
+
+	for _, c := range table.Columns {
+		if c.Name == RelationIn || c.Name == RelationOut {
+			return fmt.Errorf("relation table %s can't have column %s, which is reserved for the edges", table.Name, c.Name)
+		}
+	}
+
+	return nil
+}
+
+// defineRelationTableQuery returns the query defining the relation table and the fields of its edges' endpoints.
+func defineRelationTableQuery(tb string, r *Relation) string {
+	return fmt.Sprintf(`DEFINE TABLE IF NOT EXISTS %s TYPE RELATION IN %s OUT %s SCHEMAFULL;`, tb, r.InTable, r.OutTable) +
+		fmt.Sprintf(`DEFINE FIELD OVERWRITE %s ON %s TYPE record<%s>;`, RelationIn, tb, r.InTable) +
+		fmt.Sprintf(`DEFINE FIELD OVERWRITE %s ON %s TYPE record<%s>;`, RelationOut, tb, r.OutTable)
+}
+
+// isRelationEndpoint reports whether the field holds the endpoint of the edges of a relation table.
+func isRelationEndpoint(name, sdbType string) bool {
+	return (name == RelationIn || name == RelationOut) && strings.HasPrefix(sdbType, "record")
+}
+
+// Relation returns the columns identifying the records the edges relate, if the table is a relation table.
+func (t TableInfo) Relation() (in, out ColumnInfo, ok bool) {
+	var hasIn, hasOut bool
+	for _, c := range t.Columns {
+		switch c.Relation {
+		case RelationIn:
+			in, hasIn = c, true
+		case RelationOut:
+			out, hasOut = c, true
+		}
+	}
+	return in, out, hasIn && hasOut
+}
+
+// DefinedRelation returns the relation the table was defined as, or nil if it is not a relation table.
+func (t TableInfo) DefinedRelation() *Relation {
+	in, out, ok := t.Relation()
+	if !ok {
+		return nil
+	}
+	return &Relation{InColumn: in.Name, InTable: in.RelationTable, OutColumn: out.Name, OutTable: out.RelationTable}
+}
+
+// RelatedRecordID returns the ID of the record related by the edge whose relation column has the value,
+// which is already converted by ValueToSurrealType.
+func (c *ColumnInfo) RelatedRecordID(v any) models.RecordID {
+	return c.recordIDOf(c.RelationTable, v)
+}
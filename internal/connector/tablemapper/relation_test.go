@@ -0,0 +1,70 @@
+package tablemapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+func TestValidateRelation(t *testing.T) {
+	table := &pb.Table{
+		Name: "order_items",
+		Columns: []*pb.Column{
+			{Name: "order_id", Type: pb.DataType_LONG, PrimaryKey: true},
+			{Name: "product_id", Type: pb.DataType_STRING, PrimaryKey: true},
+			{Name: "quantity", Type: pb.DataType_INT},
+			{Name: "note", Type: pb.DataType_JSON, PrimaryKey: true},
+		},
+	}
+	r := &Relation{InColumn: "order_id", InTable: "orders", OutColumn: "product_id", OutTable: "products"}
+	require.NoError(t, validateRelation(table, r))
+
+	tests := []struct {
+		name     string
+		relation Relation
+		wantErr  string
+	}{
+		{name: "same columns", relation: Relation{InColumn: "order_id", InTable: "orders", OutColumn: "order_id", OutTable: "products"}, wantErr: "needs different in and out columns"},
+		{name: "missing column", relation: Relation{InColumn: "order_id", InTable: "orders", OutColumn: "sku", OutTable: "products"}, wantErr: "has no column sku"},
+		{name: "not a primary key", relation: Relation{InColumn: "order_id", InTable: "orders", OutColumn: "quantity", OutTable: "products"}, wantErr: "needs to be a primary key column"},
+		{name: "unsupported type", relation: Relation{InColumn: "order_id", InTable: "orders", OutColumn: "note", OutTable: "products"}, wantErr: "only integer and string columns can"},
+		{name: "invalid table", relation: Relation{InColumn: "order_id", InTable: "", OutColumn: "product_id", OutTable: "products"}, wantErr: "invalid table related by relation table order_items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateRelation(table, &tt.relation), tt.wantErr)
+		})
+	}
+
+	reserved := &pb.Table{Name: "order_items", Columns: append(table.Columns, &pb.Column{Name: "in", Type: pb.DataType_STRING})}
+	assert.ErrorContains(t, validateRelation(reserved, r), "can't have column in")
+}
+
+func TestDefineRelationTableQuery(t *testing.T) {
+	r := &Relation{InColumn: "order_id", InTable: "orders", OutColumn: "product_id", OutTable: "products"}
+	assert.Equal(t, "DEFINE TABLE IF NOT EXISTS order_items TYPE RELATION IN orders OUT products SCHEMAFULL;"+
+		"DEFINE FIELD OVERWRITE in ON order_items TYPE record<orders>;"+
+		"DEFINE FIELD OVERWRITE out ON order_items TYPE record<products>;",
+		defineRelationTableQuery("order_items", r))
+}
+
+func TestTableInfo_Relation(t *testing.T) {
+	info := TableInfo{Columns: []ColumnInfo{
+		{Name: "order_id", ColumnMeta: ColumnMeta{Relation: RelationIn, RelationTable: "orders"}},
+		{Name: "product_id", ColumnMeta: ColumnMeta{Relation: RelationOut, RelationTable: "products"}},
+		{Name: "quantity"},
+	}}
+	assert.Equal(t, &Relation{InColumn: "order_id", InTable: "orders", OutColumn: "product_id", OutTable: "products"}, info.DefinedRelation())
+
+	in, _, ok := info.Relation()
+	require.True(t, ok)
+	assert.Equal(t, "order_id", in.Name)
+
+	assert.Nil(t, TableInfo{Columns: info.Columns[1:]}.DefinedRelation(), "a relation needs both endpoints")
+
+	assert.True(t, isRelationEndpoint("in", "record<orders>"))
+	assert.False(t, isRelationEndpoint("in", "string"), "a plain column named in is not an endpoint")
+}
@@ -3,14 +3,17 @@ package tablemapper
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 
+	"github.com/surrealdb/fivetran-destination/internal/connector/introspect"
 	"github.com/surrealdb/fivetran-destination/internal/connector/log"
 	pb "github.com/surrealdb/fivetran-destination/internal/pb"
 	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/connection"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
 
 // TableMapper handles table definition and reading operations for SurrealDB tables.
@@ -47,9 +50,25 @@ func (tm *TableMapper) Debugging() bool {
 	return tm.logging.Debugging()
 }
 
+// DefineOptions are the options of DefineTable, which are decided by the connector configuration
+// rather than by the Fivetran schema.
+type DefineOptions struct {
+	// ScalarRecordIDs defines the table to use the primary key values as scalar record IDs,
+	// if the table has a single primary key column eligible for it. See ScalarRecordIDColumn.
+	ScalarRecordIDs bool
+	// Links maps the columns linking to other tables to the SurrealDB tables they link to.
+	// Columns missing in the table are ignored.
+	Links map[string]string
+	// Relation defines the table as a relation table, if not nil.
+	Relation *Relation
+}
+
 // TableInfo contains information about a table's structure.
 type TableInfo struct {
 	Columns []ColumnInfo
+	// Unmapped are the fields of a table not created by the connector,
+	// whose types have no corresponding Fivetran data type.
+	Unmapped []ColumnInfo
 }
 
 // ColumnInfo contains information about a column in a table.
@@ -58,15 +77,49 @@ type ColumnInfo struct {
 	SDBType  string
 	Optional bool
 	ColumnMeta
+
+	// LinkScalarRecordIDs indicates that the table the column links to, or relates, uses scalar record IDs.
+	// It is set by ResolveLinks, and is never persisted because the linked table decides it.
+	LinkScalarRecordIDs bool
 }
 
 // StrToSurrealType converts a string value to the appropriate SurrealDB type.
+//
+// The values of link columns are converted to the record IDs they link to.
 func (c *ColumnInfo) StrToSurrealType(v string) (interface{}, error) {
 	tpe := FindTypeMappingByColumnInfo(c)
 	if tpe == nil {
 		return nil, fmt.Errorf("converting value: unsupported data type for column %s: surrealdb type %s, fivetran type %s", c.Name, c.SDBType, c.FtType)
 	}
-	return tpe.SurrealType(v)
+	sv, err := tpe.SurrealType(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.link(sv), nil
+}
+
+// ValueToSurrealType converts a value read from a batch file to the appropriate SurrealDB type.
+//
+// Strings, which is what CSV batch files consist of, are converted using StrToSurrealType.
+// Typed values, like the ones read from Parquet batch files, are converted directly
+// without round-tripping through strings. A nil value, which is how Parquet represents
+// nulls, results in SurrealDB NONE.
+func (c *ColumnInfo) ValueToSurrealType(v any) (interface{}, error) {
+	switch t := v.(type) {
+	case nil:
+		return models.None, nil
+	case string:
+		return c.StrToSurrealType(t)
+	}
+	tpe := FindTypeMappingByColumnInfo(c)
+	if tpe == nil {
+		return nil, fmt.Errorf("converting value: unsupported data type for column %s: surrealdb type %s, fivetran type %s", c.Name, c.SDBType, c.FtType)
+	}
+	sv, err := tpe.SurrealTypeFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.link(sv), nil
 }
 
 // ColumnMeta is the metadata for a field in a table.
@@ -98,6 +151,22 @@ type ColumnMeta struct {
 	// DecimalScale is the scale (number of decimal places) for decimal types.
 	// It is only set when the FtType is pb.DataType_DECIMAL.
 	DecimalScale uint32 `json:"ft_decimal_scale,omitempty"`
+
+	// ScalarRecordID indicates that the value of this column, the only primary key column of the table,
+	// is used as the record ID as is, like users:42, instead of the array of the primary key values, like users:[42].
+	// It is decided when the table is created, so that changing the configuration never changes the layout of existing tables.
+	ScalarRecordID bool `json:"scalar_record_id,omitempty"`
+
+	// LinkTable is the SurrealDB table the column links to, if any.
+	// The field is defined as record<LinkTable>, and each value is written as the ID of the record it links to,
+	// so that the linked records can be traversed like `customer_id.name`.
+	// FtType stays the type of the column in the Fivetran schema.
+	LinkTable string `json:"link_table,omitempty"`
+
+	// Relation is RelationIn or RelationOut if the column identifies the record
+	// the edges of the relation table come in from, or go out to, which is a record of RelationTable.
+	Relation      string `json:"relation,omitempty"`
+	RelationTable string `json:"relation_table,omitempty"`
 }
 
 // ErrTableNotFound is returned when a table is not found.
@@ -105,59 +174,43 @@ var ErrTableNotFound = fmt.Errorf("table not found")
 
 // InfoForTable retrieves information about a table's structure.
 func (tm *TableMapper) InfoForTable(ctx context.Context, tableName string) (TableInfo, error) {
-	// the result is formatted like:
-	// {
-	// 	"events": {},
-	// 	"fields": {
-	// 		"name": "DEFINE FIELD name ON user TYPE string PERMISSIONS FULL"
-	// 	},
-	// 	"indexes": {},
-	// 	"lives": {},
-	// 	"tables": {}
-	// }
-	type InfoForTableResult struct {
-		Fields map[string]string `json:"fields"`
-	}
-
 	if err := ValidateTableName(tableName); err != nil {
 		return TableInfo{}, err
 	}
 
-	query := fmt.Sprintf(`INFO FOR TABLE %s;`, tableName)
-
-	info, err := surrealdb.Query[InfoForTableResult](ctx, tm.db, query, nil)
+	fields, err := introspect.Fields(ctx, tm.db, tableName)
+	if errors.Is(err, introspect.ErrNoTableInfo) {
+		return TableInfo{}, ErrTableNotFound
+	}
 	if err != nil {
 		return TableInfo{}, err
 	}
 
-	if len(*info) == 0 {
-		return TableInfo{}, ErrTableNotFound
-	}
-
-	first := (*info)[0]
-
-	fields := first.Result.Fields
-
 	if tm.Debugging() {
 		tm.LogDebug("INFO FOR TABLE", "table", tableName, "fields", fields)
 	}
 
 	columns := []ColumnInfo{}
+	// inferred are the columns without ColumnMeta, like the ones of tables not created by the connector
+	var inferred, unmapped []ColumnInfo
 
 	for _, field := range fields {
-		field = strings.TrimPrefix(field, "DEFINE FIELD ")
-		s := strings.Split(field, " ")
-		name := s[0]
-		rr := strings.Split(field, " TYPE ")
-		tpe := strings.Split(rr[1], " ")[0]
-
-		var meta ColumnMeta
-		if strings.Contains(field, "COMMENT '") {
-			comment := strings.Split(field, "COMMENT '")[1]
-			comment = strings.Split(comment, "'")[0]
-			err := json.Unmarshal([]byte(comment), &meta)
+		name := field.Name
+		// Fields defined without a type accept any value
+		tpe := field.Kind
+		if tpe == "" {
+			tpe = "any"
+		}
+
+		var (
+			meta    ColumnMeta
+			hasMeta bool
+		)
+		if field.Comment != "" {
+			hasMeta = true
+			err := json.Unmarshal([]byte(field.Comment), &meta)
 			if err != nil {
-				return TableInfo{}, fmt.Errorf("failed to unmarshal comment %s for field %s: %v", comment, name, err)
+				return TableInfo{}, fmt.Errorf("failed to unmarshal comment %s for field %s: %v", field.Comment, name, err)
 			}
 		}
 
@@ -166,13 +219,21 @@ func (tm *TableMapper) InfoForTable(ctx context.Context, tableName string) (Tabl
 		// upper[*] are for any nested fields in the `upper` object field
 		// and does not need to be mapped to a Fivetran column.
 		// What's why we skip them here.
+		// SurrealDB also defines them for the elements of typed arrays, like `tags[*]` of TYPE string
+		// for `tags` of TYPE array<string>, in tables not created by the connector.
 		if strings.HasSuffix(name, "[*]") {
-			if tpe != "any" {
+			if tpe != "any" && hasMeta {
 				return TableInfo{}, fmt.Errorf("unexpected type for field %s: %s", name, tpe)
 			}
 			continue
 		}
 
+		// The in and out fields of relation tables are the endpoints of the edges,
+		// which are derived from the relation columns rather than being columns of their own.
+		if isRelationEndpoint(name, tpe) && !hasMeta {
+			continue
+		}
+
 		var optional bool
 		if strings.HasPrefix(tpe, "option<") {
 			tpe = strings.TrimPrefix(tpe, "option<")
@@ -180,24 +241,59 @@ func (tm *TableMapper) InfoForTable(ctx context.Context, tableName string) (Tabl
 			optional = true
 		}
 
-		columns = append(columns, ColumnInfo{
-			Name:       strings.ReplaceAll(name, "`", ""),
+		column := ColumnInfo{
+			Name:       name,
 			SDBType:    tpe,
 			Optional:   optional,
 			ColumnMeta: meta,
-		})
-	}
+		}
 
-	if tm.Debugging() {
-		tm.LogDebug("Ran info for table", "table", tableName, "columns", columns)
+		if !hasMeta {
+			var ok bool
+			column.ColumnMeta, ok = InferColumnMeta(column.Name, column.SDBType)
+			if !ok {
+				unmapped = append(unmapped, column)
+				continue
+			}
+			inferred = append(inferred, column)
+			continue
+		}
+
+		columns = append(columns, column)
 	}
 
 	sort.Slice(columns, func(i, j int) bool {
 		return columns[i].FtIndex < columns[j].FtIndex
 	})
 
+	// The inferred columns follow the others, the id column first and the rest by name,
+	// so that DescribeTable reports the columns in a stable order.
+	sort.Slice(inferred, func(i, j int) bool {
+		if (inferred[i].Name == "id") != (inferred[j].Name == "id") {
+			return inferred[i].Name == "id"
+		}
+		return inferred[i].Name < inferred[j].Name
+	})
+	next := 0
+	if len(columns) > 0 {
+		next = columns[len(columns)-1].FtIndex + 1
+	}
+	for i := range inferred {
+		inferred[i].FtIndex = next + i
+	}
+	columns = append(columns, inferred...)
+
+	sort.Slice(unmapped, func(i, j int) bool {
+		return unmapped[i].Name < unmapped[j].Name
+	})
+
+	if tm.Debugging() {
+		tm.LogDebug("Ran info for table", "table", tableName, "columns", columns, "unmapped", unmapped)
+	}
+
 	return TableInfo{
-		Columns: columns,
+		Columns:  columns,
+		Unmapped: unmapped,
 	}, nil
 }
 
@@ -236,13 +332,21 @@ func DefineFivetranStartFieldIndex(tb string) (string, error) {
 }
 
 // DefineTable defines a table and its fields in SurrealDB.
-func (tm *TableMapper) DefineTable(ctx context.Context, table *pb.Table) error {
+//
+// The options decide how the table is defined on top of the Fivetran schema. See DefineOptions.
+func (tm *TableMapper) DefineTable(ctx context.Context, table *pb.Table, opts DefineOptions) error {
 	var rpcRes connection.RPCResponse[any]
 	if err := ValidateTableName(table.Name); err != nil {
 		return err
 	}
 	tb := table.Name
 	query := fmt.Sprintf(`DEFINE TABLE IF NOT EXISTS %s SCHEMAFULL;`, tb)
+	if opts.Relation != nil {
+		if err := validateRelation(table, opts.Relation); err != nil {
+			return err
+		}
+		query = defineRelationTableQuery(tb, opts.Relation)
+	}
 	if err := surrealdb.Send(ctx, tm.db, &rpcRes, "query", query); err != nil {
 		return err
 	}
@@ -262,7 +366,29 @@ func (tm *TableMapper) DefineTable(ctx context.Context, table *pb.Table) error {
 		}
 	}
 
+	var scalarID *pb.Column
+	if opts.ScalarRecordIDs {
+		scalarID, _ = ScalarRecordIDColumn(table)
+	}
+
 	for i, c := range table.Columns {
+		meta := NewColumnMeta(c, i)
+		meta.ScalarRecordID = c == scalarID
+		if link, ok := opts.Links[c.Name]; ok {
+			if err := validateLink(c, link); err != nil {
+				return err
+			}
+			meta.LinkTable = link
+		}
+		if r := opts.Relation; r != nil {
+			switch c.Name {
+			case r.InColumn:
+				meta.Relation, meta.RelationTable = RelationIn, r.InTable
+			case r.OutColumn:
+				meta.Relation, meta.RelationTable = RelationOut, r.OutTable
+			}
+		}
+
 		if c.Name == "id" {
 			if tm.Debugging() {
 				tm.LogDebug("Skipping id")
@@ -270,7 +396,15 @@ func (tm *TableMapper) DefineTable(ctx context.Context, table *pb.Table) error {
 			// We treat it specially since it's the primary key column in SurrealDB,
 			// which needs to be the primary id type itself when soft-delete(non-history) mode,
 			// while in history mode it can be a composite key of (the id type assuming its pk, _fivetran_start).
-			q, err := DefineFieldQueryForHistoryModeIDFromFt(tb, c, i)
+			var (
+				q   string
+				err error
+			)
+			if meta.ScalarRecordID {
+				q, err = defineFieldQueryForScalarID(tb, c, meta)
+			} else {
+				q, err = DefineFieldQueryForHistoryModeIDFromFt(tb, c, i)
+			}
 			if err != nil {
 				return err
 			}
@@ -286,7 +420,7 @@ func (tm *TableMapper) DefineTable(ctx context.Context, table *pb.Table) error {
 		if err := ValidateColumnName(c.Name); err != nil {
 			return err
 		}
-		q, err := DefineFieldQueryFromFt(tb, c, i)
+		q, err := defineFieldQuery(tb, c, meta)
 		if err != nil {
 			return err
 		}
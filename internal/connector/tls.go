@@ -0,0 +1,162 @@
+package connector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// TLSConfig are the PEM files of the certificates the gRPC server serves TLS with.
+type TLSConfig struct {
+	// CertFile and KeyFile are the certificate chain and private key of the server
+	CertFile string
+	KeyFile  string
+	// ClientCAFile are the CA certificates verifying client certificates, which are required if set (mutual TLS)
+	ClientCAFile string
+}
+
+// TLSConfigFromEnv returns the TLSConfig of the SURREAL_FIVETRAN_TLS_CERT, SURREAL_FIVETRAN_TLS_KEY
+// and SURREAL_FIVETRAN_TLS_CLIENT_CA environment variables.
+func TLSConfigFromEnv() TLSConfig {
+	return TLSConfig{
+		CertFile:     os.Getenv("SURREAL_FIVETRAN_TLS_CERT"),
+		KeyFile:      os.Getenv("SURREAL_FIVETRAN_TLS_KEY"),
+		ClientCAFile: os.Getenv("SURREAL_FIVETRAN_TLS_CLIENT_CA"),
+	}
+}
+
+// Enabled reports whether the server should serve TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.ClientCAFile != ""
+}
+
+// ServerConfig returns the tls.Config of the server, which fails if the files can't be loaded.
+//
+// The files are checked for changes on each handshake, and reloaded if modified,
+// so that renewed certificates are served without restarting the connector.
+// A reload failing, like when a certificate is written before its key, keeps the previous certificates.
+func (c TLSConfig) ServerConfig(logger zerolog.Logger) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+
+	r := &certReloader{cfg: c, logger: logger}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}, nil
+}
+
+// certReloader loads the files of a TLSConfig, and reloads them once modified.
+type certReloader struct {
+	cfg    TLSConfig
+	logger zerolog.Logger
+
+	mu sync.Mutex
+	// modTimes are the modification times of the loaded files
+	modTimes []time.Time
+	current  *tls.Config
+}
+
+// config returns the tls.Config of the current certificates, reloading them if modified.
+func (r *certReloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err == nil && !r.modified(modTimes) {
+		return r.current
+	}
+	if err == nil {
+		err = r.load(modTimes)
+	}
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("failed to reload TLS certificates, serving the previous ones")
+	} else {
+		r.logger.Info().Str("cert_file", r.cfg.CertFile).Msg("Reloaded TLS certificates")
+	}
+	return r.current
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	return r.load(modTimes)
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat TLS file: %w", err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *certReloader) modified(modTimes []time.Time) bool {
+	for i, t := range modTimes {
+		if !t.Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load loads the files, whose modification times are recorded so that they are only reloaded once modified again.
+func (r *certReloader) load(modTimes []time.Time) error {
+	// Record the modification times even on failure, so that invalid files are only reported once
+	r.modTimes = modTimes
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS client CA file %s", r.cfg.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.current = cfg
+	return nil
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"path"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+// UnaryServerInterceptor starts the root span of each RPC, like WriteBatch,
+// tagged with the schema and the table of the request, and the number of its batch files.
+//
+// The span continues the trace of the W3C trace context of the request metadata, if any.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		}
+
+		ctx, span := Tracer().Start(ctx, path.Base(info.FullMethod),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(requestAttributes(info.FullMethod, req)...),
+		)
+
+		resp, err := handler(ctx, req)
+		End(span, err)
+		return resp, err
+	}
+}
+
+// requestAttributes returns the attributes of the root span of the RPC of the request.
+func requestAttributes(method string, req any) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", method),
+	}
+
+	if r, ok := req.(interface{ GetSchemaName() string }); ok {
+		attrs = append(attrs, attribute.String("fivetran.schema", r.GetSchemaName()))
+	}
+	switch r := req.(type) {
+	case interface{ GetTableName() string }:
+		attrs = append(attrs, attribute.String("fivetran.table", r.GetTableName()))
+	case interface{ GetTable() *pb.Table }:
+		attrs = append(attrs, attribute.String("fivetran.table", r.GetTable().GetName()))
+	}
+
+	switch r := req.(type) {
+	case *pb.MigrateRequest:
+		attrs = append(attrs,
+			attribute.String("fivetran.schema", r.GetDetails().GetSchema()),
+			attribute.String("fivetran.table", r.GetDetails().GetTable()),
+		)
+	case *pb.WriteBatchRequest:
+		attrs = append(attrs,
+			attribute.Int("fivetran.replace_files", len(r.ReplaceFiles)),
+			attribute.Int("fivetran.update_files", len(r.UpdateFiles)),
+			attribute.Int("fivetran.delete_files", len(r.DeleteFiles)),
+		)
+	case *pb.WriteHistoryBatchRequest:
+		attrs = append(attrs,
+			attribute.Int("fivetran.earliest_start_files", len(r.EarliestStartFiles)),
+			attribute.Int("fivetran.replace_files", len(r.ReplaceFiles)),
+			attribute.Int("fivetran.update_files", len(r.UpdateFiles)),
+			attribute.Int("fivetran.delete_files", len(r.DeleteFiles)),
+		)
+	}
+
+	return attrs
+}
+
+// metadataCarrier reads the trace context from gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
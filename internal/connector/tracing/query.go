@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxQueryTextLength is the length the text of the queries is truncated to in spans.
+const maxQueryTextLength = 1024
+
+// Query runs the query like surrealdb.Query, in a span tagged with the kind of its statement,
+// like UPSERT or SELECT, and the record ID it writes or reads, if any.
+//
+// Only the text of the query is recorded, whose values are parameters, never the values of the parameters.
+func Query[T any](ctx context.Context, db *surrealdb.DB, query string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	kind := statementKind(query)
+	ctx, span := Tracer().Start(ctx, "surrealdb "+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(queryAttributes(kind, query, vars)...),
+	)
+
+	results, err := surrealdb.Query[T](ctx, db, query, vars)
+	End(span, err)
+	return results, err
+}
+
+// statementKind returns the keyword of the first statement of the query, like UPSERT,
+// or TRANSACTION for the queries running their statements in a transaction.
+func statementKind(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "EMPTY"
+	}
+	kind := strings.ToUpper(strings.TrimSuffix(fields[0], ";"))
+	if kind == "BEGIN" {
+		return "TRANSACTION"
+	}
+	return kind
+}
+
+// queryAttributes returns the attributes of the span of the query,
+// with the record ID, or the number of record IDs, of its parameters.
+func queryAttributes(kind, query string, vars map[string]any) []attribute.KeyValue {
+	text := strings.Join(strings.Fields(query), " ")
+	if len(text) > maxQueryTextLength {
+		text = text[:maxQueryTextLength] + "..."
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "surrealdb"),
+		attribute.String("db.operation.name", kind),
+		attribute.String("db.query.text", text),
+	}
+
+	var ids []models.RecordID
+	for _, v := range vars {
+		switch id := v.(type) {
+		case models.RecordID:
+			ids = append(ids, id)
+		case *models.RecordID:
+			if id != nil {
+				ids = append(ids, *id)
+			}
+		case []models.RecordID:
+			ids = append(ids, id...)
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+	case 1:
+		attrs = append(attrs, attribute.String("surrealdb.record_id", ids[0].String()))
+	default:
+		attrs = append(attrs, attribute.Int("surrealdb.record_count", len(ids)))
+	}
+	return attrs
+}
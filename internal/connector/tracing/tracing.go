@@ -0,0 +1,152 @@
+// Package tracing traces the RPCs of the connector, the batch files they read,
+// and the SurrealDB queries they run, with OpenTelemetry.
+//
+// Spans are only recorded once Init installs an exporter.
+// Until then, the global tracer provider of OpenTelemetry is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer of the connector.
+const instrumentationName = "github.com/surrealdb/fivetran-destination"
+
+// serviceName is the service.name of the spans, unless overridden by OTEL_SERVICE_NAME.
+const serviceName = "surrealdb-fivetran-destination"
+
+// The exporters of SURREAL_FIVETRAN_TRACES_EXPORTER.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Tracer returns the tracer of the connector.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs the exporter of the SURREAL_FIVETRAN_TRACES_EXPORTER environment variable:
+//   - otlp exports to the OTLP gRPC endpoint of OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,
+//     and is the default when either is set
+//   - stdout writes the spans as JSON to stdout
+//   - file writes the spans as JSON to the file of SURREAL_FIVETRAN_TRACES_FILE, which works offline
+//   - none, the default otherwise, records no spans
+//
+// The returned function flushes the pending spans and stops the exporter, and should be called on exit.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the attributes above
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the tracing resource: %w", err)
+	}
+
+	// The sampler follows OTEL_TRACES_SAMPLER, and samples every trace by default
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+// newExporter returns the exporter of SURREAL_FIVETRAN_TRACES_EXPORTER, or nil if tracing is off.
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	kind := strings.ToLower(os.Getenv("SURREAL_FIVETRAN_TRACES_EXPORTER"))
+	if kind == "" {
+		kind = ExporterNone
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			kind = ExporterOTLP
+		}
+	}
+
+	switch kind {
+	case ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		// The endpoint, headers and TLS settings follow the OTEL_EXPORTER_OTLP_* environment variables
+		exporter, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the OTLP trace exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterStdout:
+		return newWriterExporter(os.Stdout)
+	case ExporterFile:
+		path := os.Getenv("SURREAL_FIVETRAN_TRACES_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("the file trace exporter requires SURREAL_FIVETRAN_TRACES_FILE")
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the traces file: %w", err)
+		}
+		exporter, err := newWriterExporter(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, f: f}, nil
+	default:
+		return nil, fmt.Errorf("unknown SURREAL_FIVETRAN_TRACES_EXPORTER %q, expected %s, %s, %s or %s",
+			kind, ExporterOTLP, ExporterStdout, ExporterFile, ExporterNone)
+	}
+}
+
+func newWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the trace exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// fileExporter closes the file the spans are written to on shutdown.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// End ends the span, recording err as its status if set.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
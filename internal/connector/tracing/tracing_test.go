@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+
+	pb "github.com/surrealdb/fivetran-destination/internal/pb"
+)
+
+// recordSpans makes the global tracer provider record the spans of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestStatementKind(t *testing.T) {
+	assert.Equal(t, "UPSERT", statementKind("\n\tupsert $id CONTENT $content"))
+	assert.Equal(t, "TRANSACTION", statementKind("BEGIN TRANSACTION; UPSERT $id CONTENT $content; COMMIT TRANSACTION;"))
+	assert.Equal(t, "RETURN", statementKind("RETURN;"))
+	assert.Equal(t, "EMPTY", statementKind("  "))
+}
+
+func TestQueryAttributes(t *testing.T) {
+	attrs := attributes(queryAttributes("UPSERT", "UPSERT $id\n\tCONTENT $content", map[string]any{
+		"id":      models.NewRecordID("orders", []any{1}),
+		"content": map[string]any{"status": "shipped"},
+	}))
+	assert.Equal(t, "surrealdb", attrs["db.system"].AsString())
+	assert.Equal(t, "UPSERT", attrs["db.operation.name"].AsString())
+	assert.Equal(t, "UPSERT $id CONTENT $content", attrs["db.query.text"].AsString())
+	assert.Equal(t, "orders:[1]", attrs["surrealdb.record_id"].AsString())
+
+	attrs = attributes(queryAttributes("DELETE", "DELETE $ids RETURN NONE;", map[string]any{
+		"ids": []models.RecordID{models.NewRecordID("orders", 1), models.NewRecordID("orders", 2)},
+	}))
+	assert.Equal(t, int64(2), attrs["surrealdb.record_count"].AsInt64())
+	assert.NotContains(t, attrs, attribute.Key("surrealdb.record_id"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	recorder := recordSpans(t)
+
+	req := &pb.WriteBatchRequest{
+		SchemaName:   "sales",
+		Table:        &pb.Table{Name: "orders"},
+		ReplaceFiles: []string{"a.csv", "b.csv"},
+		DeleteFiles:  []string{"c.csv"},
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/fivetran_sdk.v2.DestinationConnector/WriteBatch"}
+	failed := errors.New("failed")
+	_, err := UnaryServerInterceptor()(context.Background(), req, info, func(ctx context.Context, req any) (any, error) {
+		// The spans of the handler are children of the root span of the RPC
+		_, child := Tracer().Start(ctx, "batch file")
+		child.End()
+		return nil, failed
+	})
+	require.ErrorIs(t, err, failed)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, root := spans[0], spans[1]
+	assert.Equal(t, "WriteBatch", root.Name())
+	assert.Equal(t, root.SpanContext().SpanID(), child.Parent().SpanID())
+	assert.Equal(t, codes.Error, root.Status().Code)
+
+	attrs := attributes(root.Attributes())
+	assert.Equal(t, "sales", attrs["fivetran.schema"].AsString())
+	assert.Equal(t, "orders", attrs["fivetran.table"].AsString())
+	assert.Equal(t, int64(2), attrs["fivetran.replace_files"].AsInt64())
+	assert.Equal(t, int64(0), attrs["fivetran.update_files"].AsInt64())
+	assert.Equal(t, int64(1), attrs["fivetran.delete_files"].AsInt64())
+}
+
+func TestInit_File(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv("SURREAL_FIVETRAN_TRACES_EXPORTER", ExporterFile)
+	t.Setenv("SURREAL_FIVETRAN_TRACES_FILE", path)
+
+	shutdown, err := Init(context.Background())
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "WriteBatch")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	traces, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(traces), `"Name":"WriteBatch"`)
+	assert.Contains(t, string(traces), serviceName)
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	t.Setenv("SURREAL_FIVETRAN_TRACES_EXPORTER", "jaeger")
+
+	_, err := Init(context.Background())
+	assert.ErrorContains(t, err, `unknown SURREAL_FIVETRAN_TRACES_EXPORTER "jaeger"`)
+}
@@ -0,0 +1,17 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/surrealdb/fivetran-destination/internal/connector/server"
+)
+
+// Verify checks that the table of the schema satisfies the invariants the connector maintains,
+// connecting to SurrealDB with the connector configuration. See server.Server.Verify for the checks.
+func Verify(ctx context.Context, logger zerolog.Logger, configuration map[string]string, schema, table string) (*server.VerifyReport, error) {
+	srv := server.New(logger)
+	defer srv.Close(ctx)
+
+	return srv.Verify(ctx, configuration, schema, table)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,17 +9,37 @@ import (
 	"net/http"
 	_ "net/http/pprof" // Add pprof HTTP endpoints
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/surrealdb/fivetran-destination/internal/connector"
+	"github.com/surrealdb/fivetran-destination/internal/connector/metrics"
+	"github.com/surrealdb/fivetran-destination/internal/connector/tracing"
 	_ "google.golang.org/grpc/encoding/gzip" // Register the gzip compressor
 )
 
 var (
-	port      = flag.Int("port", 50052, "The server port")
-	pprofPort = flag.Int("pprof-port", 6060, "The pprof server port")
+	port         = flag.Int("port", 50052, "The server port")
+	pprofPort    = flag.Int("pprof-port", 6060, "The port of the HTTP server exposing pprof and Prometheus /metrics endpoints")
+	drainTimeout = flag.Duration("drain-timeout", drainTimeoutFromEnv(), "How long to wait for in-flight RPCs on SIGTERM or SIGINT before canceling them. Defaults to SURREAL_FIVETRAN_DRAIN_TIMEOUT")
+
+	healthCheckURL      = flag.String("health-check-url", os.Getenv("SURREAL_FIVETRAN_HEALTH_CHECK_URL"), "The URL of a SurrealDB instance, like wss://your.surrealdb.instance/rpc, whose reachability the readiness reported by the gRPC health service requires. Defaults to SURREAL_FIVETRAN_HEALTH_CHECK_URL")
+	healthCheckInterval = flag.Duration("health-check-interval", connector.DefaultHealthCheckInterval, "How often the SurrealDB instance of -health-check-url is checked")
+	grpcReflection      = flag.Bool("grpc-reflection", os.Getenv("SURREAL_FIVETRAN_GRPC_REFLECTION") == "true", "Register the gRPC server reflection service, for debugging with grpcurl. Defaults to SURREAL_FIVETRAN_GRPC_REFLECTION")
+
+	// The TLS flags default to the SURREAL_FIVETRAN_TLS_* environment variables
+	tlsEnv          = connector.TLSConfigFromEnv()
+	tlsCertFile     = flag.String("tls-cert", tlsEnv.CertFile, "The PEM certificate chain file of the server, serving TLS if set")
+	tlsKeyFile      = flag.String("tls-key", tlsEnv.KeyFile, "The PEM private key file of the server certificate")
+	tlsClientCAFile = flag.String("tls-client-ca", tlsEnv.ClientCAFile, "The PEM CA certificates file verifying client certificates, which are then required (mutual TLS)")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(verify(os.Args[2:]))
+	}
+
 	logger, err := connector.LoggerFromEnv()
 	if err != nil {
 		log, jsonErr := json.Marshal(map[string]interface{}{
@@ -38,8 +59,11 @@ func main() {
 	flag.Parse()
 
 	if *pprofPort > 0 {
+		// Cumulative connector metrics, in the Prometheus text format
+		http.Handle("/metrics", metrics.Handler())
+
 		go func() {
-			logger.Info().Int("pprof-port", *pprofPort).Msg("Starting pprof server")
+			logger.Info().Int("pprof-port", *pprofPort).Msg("Starting pprof and metrics server")
 			if err := http.ListenAndServe(fmt.Sprintf(":%d", *pprofPort), nil); err != nil {
 				logger.Error().Err(err).Msg("pprof server failed")
 			}
@@ -52,8 +76,59 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger.Info().Int("port", *port).Msg("Starting SurrealDB destination connector")
-	if err := connector.Serve(lis, logger); err != nil {
+	// Trace the RPCs to the exporter of SURREAL_FIVETRAN_TRACES_EXPORTER, if any
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to initialize tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		// Flush the spans of the drained RPCs
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error().Err(err).Msg("failed to flush traces")
+		}
+	}()
+
+	// Stop accepting RPCs and drain the in-flight ones on SIGTERM, like when the container is stopped, or SIGINT
+	shutdown, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	opts := []connector.ServeOption{
+		connector.WithGracefulShutdown(shutdown, *drainTimeout),
+		connector.WithHealthCheck(*healthCheckURL, *healthCheckInterval),
+	}
+	if *grpcReflection {
+		opts = append(opts, connector.WithReflection())
+	}
+	tlsConfig := connector.TLSConfig{
+		CertFile:     *tlsCertFile,
+		KeyFile:      *tlsKeyFile,
+		ClientCAFile: *tlsClientCAFile,
+	}
+	if tlsConfig.Enabled() {
+		serverTLS, err := tlsConfig.ServerConfig(logger)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to configure TLS")
+			os.Exit(1)
+		}
+		opts = append(opts, connector.WithTLS(serverTLS))
+	}
+
+	logger.Info().Int("port", *port).Bool("tls", tlsConfig.Enabled()).Bool("mtls", tlsConfig.ClientCAFile != "").Msg("Starting SurrealDB destination connector")
+	if err := connector.Serve(lis, logger, opts...); err != nil {
 		logger.Error().Err(err).Msg("failed to serve")
+		return
+	}
+	logger.Info().Msg("Stopped SurrealDB destination connector")
+}
+
+// drainTimeoutFromEnv returns the duration of the SURREAL_FIVETRAN_DRAIN_TIMEOUT environment variable,
+// or connector.DefaultDrainTimeout if unset or invalid.
+func drainTimeoutFromEnv() time.Duration {
+	if v := os.Getenv("SURREAL_FIVETRAN_DRAIN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
 	}
+	return connector.DefaultDrainTimeout
 }
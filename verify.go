@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/surrealdb/fivetran-destination/internal/connector"
+)
+
+// Exit codes of the verify subcommand
+const (
+	verifyOK = iota
+	verifyViolations
+	verifyFailed
+)
+
+// verify runs the verify subcommand, which checks a table for the invariants the connector maintains,
+// and prints the report as JSON to stdout, logging to stderr.
+//
+// It exits with verifyViolations if any check fails, and with verifyFailed if the table can't be verified.
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := fs.String("config", "", `The path of a JSON file with the connector configuration, like {"url": "wss://...", "ns": "...", "user": "...", "pass": "..."}`)
+	schema := fs.String("schema", "", "The schema of the table")
+	table := fs.String("table", "", "The table to verify")
+	_ = fs.Parse(args)
+
+	if *configPath == "" || *schema == "" || *table == "" {
+		fmt.Fprintln(os.Stderr, "usage: verify -config <file> -schema <schema> -table <table>")
+		fs.PrintDefaults()
+		return verifyFailed
+	}
+
+	logger, err := connector.LoggerFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
+		return verifyFailed
+	}
+	logger = logger.Output(os.Stderr)
+
+	b, err := os.ReadFile(*configPath)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read configuration")
+		return verifyFailed
+	}
+	var configuration map[string]string
+	if err := json.Unmarshal(b, &configuration); err != nil {
+		logger.Error().Err(err).Msg("failed to parse configuration")
+		return verifyFailed
+	}
+
+	report, err := connector.Verify(context.Background(), logger, configuration, *schema, *table)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to verify table")
+		return verifyFailed
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		logger.Error().Err(err).Msg("failed to write report")
+		return verifyFailed
+	}
+
+	if !report.OK {
+		return verifyViolations
+	}
+	return verifyOK
+}